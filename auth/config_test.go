@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes data to name in a temporary directory.
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fileVersion returns the top-level version field of the config file.
+func fileVersion(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields.Version
+}
+
+func TestLoadConfigMigratesVersion0(t *testing.T) {
+	original := `{"client_id": "123", "client_secret": "secret", "refresh_token": "refresh", "access_token": "access", "expires_at": 1700000000}`
+	path := writeFile(t, "config.json", original)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Migrated() {
+		t.Errorf("Migrated() = false for a file without a version")
+	}
+	if config.ClientID != "123" || config.RefreshToken != "refresh" || config.ExpiresAt != 1700000000 {
+		t.Errorf("got %+v, want the values from the file", config)
+	}
+
+	// The original is backed up before anything is rewritten
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatalf("no backup: %v", err)
+	}
+	if string(backup) != original {
+		t.Errorf("backup = %s, want the original file", backup)
+	}
+	if fileVersion(t, path) != 0 {
+		t.Errorf("loading rewrote the file")
+	}
+
+	if err := SaveConfig(path, config); err != nil {
+		t.Fatal(err)
+	}
+	if v := fileVersion(t, path); v != ConfigVersion {
+		t.Errorf("saved version %d, want %d", v, ConfigVersion)
+	}
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Migrated() {
+		t.Errorf("Migrated() = true after saving the upgraded file")
+	}
+	if reloaded.ClientSecret != "secret" || reloaded.AccessToken != "access" {
+		t.Errorf("got %+v after the upgrade, want the original values", reloaded)
+	}
+}
+
+func TestLoadProfileMigratesVersion0(t *testing.T) {
+	path := writeFile(t, "config.json", `{
+		"default_profile": "me",
+		"profiles": {
+			"me": {"client_id": "1", "client_secret": "s1", "refresh_token": "r1"},
+			"kid": {"client_id": "2", "client_secret": "s2", "refresh_token": "r2"}
+		}
+	}`)
+
+	config, err := LoadProfile(path, "kid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Migrated() || config.Profile != "kid" || config.RefreshToken != "r2" {
+		t.Errorf("got %+v (migrated %v), want profile kid, migrated", config, config.Migrated())
+	}
+
+	config.AccessToken = "new"
+	if err := SaveConfig(path, config); err != nil {
+		t.Fatal(err)
+	}
+	if v := fileVersion(t, path); v != ConfigVersion {
+		t.Errorf("saved version %d, want %d", v, ConfigVersion)
+	}
+	other, err := LoadProfile(path, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Migrated() || other.RefreshToken != "r1" {
+		t.Errorf("other profile = %+v (migrated %v), want it kept and upgraded", other, other.Migrated())
+	}
+}
+
+func TestLoadConfigRejectsNewerVersion(t *testing.T) {
+	path := writeFile(t, "config.json", `{"version": 99, "client_id": "123"}`)
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "newer than this tool supports") {
+		t.Fatalf("err = %v, want a newer version error", err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("a backup was written for a file that can't be migrated")
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TokenError is returned (wrapped) by RefreshToken when the token endpoint
+// rejects the request, e.g. because the refresh token was revoked. It is
+// the token endpoint's counterpart to strava.APIError.
+type TokenError struct {
+	StatusCode int
+	Status     string // e.g. "400 Bad Request"
+	Message    string // Strava's error message, or the raw response body
+
+	// Fields are the fields Strava said it rejected, e.g.
+	// refreshtoken.refresh_token: invalid.
+	Fields []FieldError
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("%s - %s", e.Status, ErrorResponse{Message: e.Message, Errors: e.Fields})
+}
+
+// newTokenError builds an error from a failed token response's body,
+// redacting secrets so they can't leak into logs.
+func newTokenError(statusCode int, status string, body []byte, secrets ...string) *TokenError {
+	tokenErr := &TokenError{
+		StatusCode: statusCode,
+		Status:     status,
+		Message:    Redact(strings.TrimSpace(string(body)), secrets...),
+	}
+	if resp, ok := ParseErrorResponse(body); ok {
+		tokenErr.Message = Redact(resp.Message, secrets...)
+		tokenErr.Fields = resp.Errors
+	}
+	return tokenErr
+}
+
+// FieldError is one entry of the errors array in a Strava error response,
+// such as {"resource": "Activity", "field": "sport_type", "code":
+// "invalid"}.
+type FieldError struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+}
+
+// String formats the entry as e.g. "activity.sport_type: invalid", leaving
+// out whichever of the resource and field Strava didn't give.
+func (e FieldError) String() string {
+	name := e.Field
+	if e.Resource != "" {
+		name = strings.ToLower(e.Resource) + "." + e.Field
+		name = strings.TrimSuffix(name, ".")
+	}
+	if name == "" {
+		return e.Code
+	}
+	return name + ": " + e.Code
+}
+
+// ErrorResponse is the body Strava sends with a failed request, from the
+// API and the token endpoint alike.
+type ErrorResponse struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+// ParseErrorResponse decodes a failed response's body, reporting false
+// when it isn't Strava's error JSON (e.g. an HTML page from a proxy).
+// Entries of the errors array with neither a field nor a code are dropped.
+func ParseErrorResponse(body []byte) (ErrorResponse, bool) {
+	var resp ErrorResponse
+	if json.Unmarshal(body, &resp) != nil || (resp.Message == "" && len(resp.Errors) == 0) {
+		return ErrorResponse{}, false
+	}
+	errs := resp.Errors[:0]
+	for _, e := range resp.Errors {
+		if e.Field != "" || e.Code != "" {
+			errs = append(errs, e)
+		}
+	}
+	resp.Errors = errs
+	return resp, true
+}
+
+// String formats the response as e.g. "Bad Request: activity.sport_type:
+// invalid; activity.name: missing".
+func (r ErrorResponse) String() string {
+	if len(r.Errors) == 0 {
+		return r.Message
+	}
+	fields := make([]string, len(r.Errors))
+	for i, e := range r.Errors {
+		fields[i] = e.String()
+	}
+	if r.Message == "" {
+		return strings.Join(fields, "; ")
+	}
+	return r.Message + ": " + strings.Join(fields, "; ")
+}
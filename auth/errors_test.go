@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFieldErrorString(t *testing.T) {
+	tests := []struct {
+		err  FieldError
+		want string
+	}{
+		{FieldError{Resource: "Activity", Field: "sport_type", Code: "invalid"}, "activity.sport_type: invalid"},
+		{FieldError{Resource: "RefreshToken", Field: "refresh_token", Code: "invalid"}, "refreshtoken.refresh_token: invalid"},
+		{FieldError{Field: "name", Code: "missing"}, "name: missing"},
+		{FieldError{Resource: "Activity", Code: "invalid"}, "activity: invalid"},
+		{FieldError{Code: "invalid"}, "invalid"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+		want string
+	}{
+		{"field errors", `{"message": "Bad Request", "errors": [{"resource": "Activity", "field": "sport_type", "code": "invalid"}, {"resource": "Activity", "field": "name", "code": "missing"}]}`,
+			true, "Bad Request: activity.sport_type: invalid; activity.name: missing"},
+		{"message only", `{"message": "Authorization Error", "errors": []}`, true, "Authorization Error"},
+		{"empty entries dropped", `{"message": "Bad Request", "errors": [{"resource": "Activity"}]}`, true, "Bad Request"},
+		{"html", `<html><body>502 Bad Gateway</body></html>`, false, ""},
+		{"unrelated json", `{"id": 1}`, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, ok := ParseErrorResponse([]byte(tt.body))
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if got := resp.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReturnsTokenError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantFields []FieldError
+		wantError  string
+	}{
+		{"revoked refresh token", http.StatusBadRequest,
+			`{"message": "Bad Request", "errors": [{"resource": "RefreshToken", "field": "refresh_token", "code": "invalid"}]}`,
+			[]FieldError{{Resource: "RefreshToken", Field: "refresh_token", Code: "invalid"}},
+			"failed to refresh token: 400 Bad Request - Bad Request: refreshtoken.refresh_token: invalid"},
+		{"wrong client secret", http.StatusUnauthorized,
+			`{"message": "Authorization Error", "errors": [{"resource": "Application", "field": "client_secret", "code": "invalid"}]}`,
+			[]FieldError{{Resource: "Application", Field: "client_secret", Code: "invalid"}},
+			"failed to refresh token: 401 Unauthorized - Authorization Error: application.client_secret: invalid"},
+		{"proxy error page", http.StatusBadGateway, "<html>Bad Gateway</html>\n", nil,
+			"failed to refresh token: 502 Bad Gateway - <html>Bad Gateway</html>"},
+		{"echoed secret", http.StatusBadRequest, `{"message": "invalid secret 4f1c9e0d7ab2"}`, nil,
+			"failed to refresh token: 400 Bad Request - invalid secret ***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			err := RefreshToken(&StravaConfig{ClientID: "123", ClientSecret: "4f1c9e0d7ab2", RefreshToken: "refresh"})
+			var tokenErr *TokenError
+			if !errors.As(err, &tokenErr) {
+				t.Fatalf("err = %v, want a *TokenError", err)
+			}
+			if tokenErr.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", tokenErr.StatusCode, tt.status)
+			}
+			if len(tokenErr.Fields) != len(tt.wantFields) {
+				t.Fatalf("Fields = %+v, want %+v", tokenErr.Fields, tt.wantFields)
+			}
+			for i := range tt.wantFields {
+				if tokenErr.Fields[i] != tt.wantFields[i] {
+					t.Errorf("Fields[%d] = %+v, want %+v", i, tokenErr.Fields[i], tt.wantFields[i])
+				}
+			}
+			if err.Error() != tt.wantError {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantError)
+			}
+			if strings.Contains(err.Error(), "4f1c9e0d7ab2") {
+				t.Errorf("Error() leaks the client secret: %q", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LockConfig takes an exclusive lock on the config file, waiting for any
+// other process holding it. Hold it from loading the config until the
+// refreshed token is saved: Strava may replace the refresh token on every
+// refresh, so two tools refreshing at once can otherwise leave the file
+// with a token that no longer works.
+//
+// The lock is held on filename + ".lock", which is left in place. It is
+// released by calling unlock, or by the OS if the process dies.
+func LockConfig(filename string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filename+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock config: %w", err)
+	}
+
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
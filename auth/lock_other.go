@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package auth
+
+import "os"
+
+// Platforms without file locking run unlocked.
+
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
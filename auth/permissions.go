@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"os"
+	"runtime"
+)
+
+// ConfigPermissions returns the permission bits of the config file at
+// filename and whether it is private to its owner, as SaveConfig creates
+// it. The file holds the client secret and tokens, so anything readable by
+// the group or other users deserves a warning. On Windows, where the bits
+// don't reflect who can read the file, it is always reported as private.
+func ConfigPermissions(filename string) (perm os.FileMode, private bool, err error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, false, err
+	}
+	perm = info.Mode().Perm()
+	return perm, runtime.GOOS == "windows" || perm&0077 == 0, nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Patterns for credentials that may end up in a URL, a response body or
+// an error message: bearer tokens, and the values of the OAuth parameters.
+var (
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[^\s",;]+`)
+	paramPattern  = regexp.MustCompile(`(?i)\b(access_token|refresh_token|client_secret|code|hub\.verify_token)=[^&\s"]+`)
+	fieldPattern  = regexp.MustCompile(`(?i)"(access_token|refresh_token|client_secret)"\s*:\s*"[^"]*"`)
+)
+
+// Redact replaces credentials in s, and any of the given secrets wherever
+// they appear, with ***, so that logs and error messages can't leak them.
+// An Authorization header becomes "Bearer ***".
+func Redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "***")
+		}
+	}
+	s = bearerPattern.ReplaceAllString(s, "Bearer ***")
+	s = paramPattern.ReplaceAllString(s, "$1=***")
+	return fieldPattern.ReplaceAllString(s, `"$1": "***"`)
+}
+
+// RedactError returns err with the URL of a failed request redacted, since
+// that URL is part of the message.
+func RedactError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = Redact(urlErr.URL)
+	}
+	return err
+}
@@ -1,21 +1,105 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
+// LegacyConfigFile is the config file name looked up in the working
+// directory, kept for backward compatibility with older setups.
+const LegacyConfigFile = "strava_config.json"
+
+// DefaultConfigDir returns the per-user config directory for the tools,
+// e.g. $XDG_CONFIG_HOME/strava-activity-updater on Linux.
+func DefaultConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "strava-activity-updater"), nil
+}
+
+// ResolveConfigPath picks the config file to use when none was given
+// explicitly: config.json in configDir (DefaultConfigDir when empty) if it
+// exists, else LegacyConfigFile in the working directory if that exists,
+// else config.json in configDir so that it's created there on save.
+func ResolveConfigPath(configDir string) string {
+	if configDir == "" {
+		dir, err := DefaultConfigDir()
+		if err != nil {
+			return LegacyConfigFile
+		}
+		configDir = dir
+	}
+
+	path := filepath.Join(configDir, "config.json")
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	if _, err := os.Stat(LegacyConfigFile); err == nil {
+		return LegacyConfigFile
+	}
+	return path
+}
+
+// ConfigVersion is the version of the config file layout written by
+// SaveConfig. Older files are upgraded when loaded; see configMigrations.
+const ConfigVersion = 1
+
+// configMigrations[i] upgrades a config file from version i to i+1. Each
+// works on the file's top-level JSON object, which is either a single
+// account or a profiles file.
+var configMigrations = []func(fields map[string]json.RawMessage) error{
+	// 0 -> 1: files gain a version field, the layout is unchanged
+	func(fields map[string]json.RawMessage) error { return nil },
+}
+
 type StravaConfig struct {
+	// Version is the layout version of a single-account config file.
+	// Multi-profile files keep it at the top level instead.
+	Version int `json:"version,omitempty"`
+
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	RefreshToken string `json:"refresh_token"`
 	AccessToken  string `json:"access_token"`
 	ExpiresAt    int64  `json:"expires_at"`
+
+	// Scope is the comma-separated list of scopes granted to the token, as
+	// reported by the token endpoint. It is empty when the server didn't
+	// say, which is usual for refreshes.
+	Scope string `json:"scope,omitempty"`
+
+	// Profile is the name of the profile this config was loaded from in a
+	// multi-profile file. It is empty for the legacy single-account format.
+	Profile string `json:"-"`
+
+	// migrated is set when the file was in an older layout, which the
+	// next SaveConfig upgrades.
+	migrated bool
+}
+
+// Migrated reports whether the config was loaded from a file in an older
+// layout, so it should be saved even if nothing else changed.
+func (c *StravaConfig) Migrated() bool {
+	return c.migrated
+}
+
+// profilesFile is the on-disk layout of a config file holding several
+// named accounts.
+type profilesFile struct {
+	Version        int                      `json:"version,omitempty"`
+	DefaultProfile string                   `json:"default_profile,omitempty"`
+	Profiles       map[string]*StravaConfig `json:"profiles"`
 }
 
 type TokenResponse struct {
@@ -24,6 +108,21 @@ type TokenResponse struct {
 	ExpiresAt    int64  `json:"expires_at"`
 	ExpiresIn    int    `json:"expires_in"`
 	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope,omitempty"` // only sent by some grants
+}
+
+// MissingScope reports whether the config's recorded scopes are known and
+// do not include scope. Unknown scopes are not treated as missing.
+func (c *StravaConfig) MissingScope(scope string) bool {
+	if c.Scope == "" {
+		return false
+	}
+	for _, granted := range strings.Split(c.Scope, ",") {
+		if strings.TrimSpace(granted) == scope {
+			return false
+		}
+	}
+	return true
 }
 
 func EnsureValidToken(config *StravaConfig) error {
@@ -33,6 +132,25 @@ func EnsureValidToken(config *StravaConfig) error {
 	return nil
 }
 
+// UserAgent, when set, is sent with token requests. The CLIs set it to the
+// same value as the API client's.
+var UserAgent string
+
+// DefaultTokenURL is Strava's OAuth token endpoint.
+const DefaultTokenURL = "https://www.strava.com/oauth/token"
+
+// TokenURL is where RefreshToken sends its requests. Like the API client's
+// BaseURL, point it at a local fake for testing or at a proxy.
+var TokenURL = DefaultTokenURL
+
+// Timeout bounds each token request, like the API client's Timeout. The
+// CLIs set it from -timeout.
+var Timeout = 10 * time.Second
+
+// HTTPClient sends token requests. The CLIs set it to the API client's, so
+// that a refresh reuses its pooled connections to www.strava.com.
+var HTTPClient = http.DefaultClient
+
 func RefreshToken(config *StravaConfig) error {
 	if config.ClientID == "" || config.ClientSecret == "" {
 		return fmt.Errorf("client ID and client secret must be set in the config file")
@@ -44,15 +162,27 @@ func RefreshToken(config *StravaConfig) error {
 	data.Set("refresh_token", config.RefreshToken)
 	data.Set("grant_type", "refresh_token")
 
-	resp, err := http.PostForm("https://www.strava.com/oauth/token", data)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return fmt.Errorf("failed to request token: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if UserAgent != "" {
+		req.Header.Set("User-Agent", UserAgent)
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request token: %w", RedactError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to refresh token: %s - %s", resp.Status, string(body))
+		return fmt.Errorf("failed to refresh token: %w", newTokenError(resp.StatusCode, resp.Status, body, config.ClientSecret, config.RefreshToken))
 	}
 
 	var tokenResp TokenResponse
@@ -63,29 +193,164 @@ func RefreshToken(config *StravaConfig) error {
 	config.AccessToken = tokenResp.AccessToken
 	config.RefreshToken = tokenResp.RefreshToken
 	config.ExpiresAt = tokenResp.ExpiresAt
+	if tokenResp.Scope != "" {
+		config.Scope = tokenResp.Scope
+	}
 
 	return nil
 }
 
+// LoadConfig loads the config file, selecting the default profile when the
+// file holds multiple accounts.
 func LoadConfig(filename string) (*StravaConfig, error) {
+	return LoadProfile(filename, "")
+}
+
+// LoadProfile loads the named profile from the config file. Both the legacy
+// single-account format and the multi-profile format are accepted. An empty
+// profile selects the file's default_profile, or its only profile.
+func LoadProfile(filename, profile string) (*StravaConfig, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	var config StravaConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	data, migrated, err := migrateConfig(filename, data)
+	if err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	var file profilesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	if file.Profiles == nil {
+		if profile != "" {
+			return nil, fmt.Errorf("config file %s does not contain profiles, cannot select profile %q", filename, profile)
+		}
+
+		var config StravaConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		config.migrated = migrated
+		return &config, nil
+	}
+
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	if profile == "" && len(file.Profiles) == 1 {
+		for name := range file.Profiles {
+			profile = name
+		}
+	}
+	if profile == "" {
+		return nil, fmt.Errorf("config file %s contains multiple profiles (%s), select one with -profile",
+			filename, profileNames(file.Profiles))
+	}
+
+	config, ok := file.Profiles[profile]
+	if !ok || config == nil {
+		return nil, fmt.Errorf("profile %q not found in %s (available: %s)",
+			profile, filename, profileNames(file.Profiles))
+	}
+	config.Profile = profile
+	config.migrated = migrated
+
+	return config, nil
 }
 
+// SaveConfig writes the config back to disk, creating its directory if
+// needed. Configs loaded from a profile are written into that profile,
+// leaving the file's other profiles intact.
 func SaveConfig(filename string, config *StravaConfig) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	if config.Profile == "" {
+		config.Version = ConfigVersion
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(filename, data, 0600)
+	}
+
+	// Re-read the file so we don't clobber the other profiles
+	var file profilesFile
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if file.Profiles == nil {
+		file.Profiles = make(map[string]*StravaConfig)
+	}
+	file.Profiles[config.Profile] = config
+	file.Version = ConfigVersion
+
+	data, err = json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return err
 	}
 
 	return os.WriteFile(filename, data, 0600)
 }
+
+// migrateConfig upgrades config file data to ConfigVersion in memory,
+// reporting whether it changed anything; the upgraded layout is written by
+// the next SaveConfig. Before the first migration of a file, the original
+// is copied to filename.bak.
+func migrateConfig(filename string, data []byte) ([]byte, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false, err
+	}
+
+	version := 0
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, false, fmt.Errorf("invalid config version: %w", err)
+		}
+	}
+	if version == ConfigVersion {
+		return data, false, nil
+	}
+	if version > ConfigVersion {
+		return nil, false, fmt.Errorf("config file %s has version %d, newer than this tool supports (%d); please update the tools",
+			filename, version, ConfigVersion)
+	}
+
+	backup := filename + ".bak"
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		if err := os.WriteFile(backup, data, 0600); err != nil {
+			return nil, false, fmt.Errorf("failed to back up config before migrating: %w", err)
+		}
+	}
+
+	for ; version < ConfigVersion; version++ {
+		if err := configMigrations[version](fields); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate config from version %d: %w", version, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(ConfigVersion))
+
+	data, err := json.Marshal(fields)
+	return data, true, err
+}
+
+func profileNames(profiles map[string]*StravaConfig) string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// withTokenServer points TokenURL at a test server running handler for the
+// duration of the test.
+func withTokenServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	saved := TokenURL
+	TokenURL = server.URL + "/oauth/token"
+	t.Cleanup(func() { TokenURL = saved })
+}
+
+func TestRefreshTokenUsesTokenURL(t *testing.T) {
+	withTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/oauth/token" {
+			t.Errorf("got %s %s, want POST /oauth/token", r.Method, r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		for field, want := range map[string]string{
+			"client_id":     "123",
+			"client_secret": "secret",
+			"refresh_token": "old-refresh",
+			"grant_type":    "refresh_token",
+		} {
+			if got := r.PostForm.Get(field); got != want {
+				t.Errorf("%s = %q, want %q", field, got, want)
+			}
+		}
+		w.Write([]byte(`{"token_type": "Bearer", "access_token": "new-access", "expires_at": 1700000000, "expires_in": 21600, "refresh_token": "new-refresh"}`))
+	})
+
+	config := &StravaConfig{ClientID: "123", ClientSecret: "secret", RefreshToken: "old-refresh"}
+	if err := RefreshToken(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.AccessToken != "new-access" || config.RefreshToken != "new-refresh" || config.ExpiresAt != 1700000000 {
+		t.Errorf("got %+v, want the tokens from the response", config)
+	}
+}
+
+func TestRefreshTokenHonorsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	withTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	defer close(release)
+
+	saved := Timeout
+	Timeout = 50 * time.Millisecond
+	defer func() { Timeout = saved }()
+
+	start := time.Now()
+	err := RefreshToken(&StravaConfig{ClientID: "123", ClientSecret: "secret", RefreshToken: "refresh"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want a deadline exceeded error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RefreshToken took %s, want it to give up after the 50ms timeout", elapsed)
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRefreshTokenUsesHTTPClient(t *testing.T) {
+	withTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "new-access", "expires_at": 1700000000, "refresh_token": "refresh"}`))
+	})
+	transport := &countingTransport{}
+	saved := HTTPClient
+	HTTPClient = &http.Client{Transport: transport}
+	defer func() { HTTPClient = saved }()
+
+	if err := RefreshToken(&StravaConfig{ClientID: "123", ClientSecret: "secret", RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("HTTPClient sent %d requests, want 1", transport.requests)
+	}
+}
@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"strava-activity-updater/auth"
+	"strava-activity-updater/strava"
+)
+
+// Environment variables that supply credentials, overriding the config file.
+const (
+	envClientID     = "STRAVA_CLIENT_ID"
+	envClientSecret = "STRAVA_CLIENT_SECRET"
+	envRefreshToken = "STRAVA_REFRESH_TOKEN"
+)
+
+// AuthFlags holds the credential flags shared by every tool. Credentials
+// are resolved with the precedence flags > environment > config file.
+type AuthFlags struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	APIKey       string // deprecated alias for RefreshToken
+	ConfigFile   string
+	ConfigDir    string
+	Profile      string
+
+	// RefreshTokenStdin reads the refresh token from stdin, so it doesn't
+	// show up in the process list like -refresh-token does.
+	RefreshTokenStdin bool
+
+	// StrictPermissions refuses a config file other users can read, and
+	// FixPermissions makes it private instead of warning about it.
+	StrictPermissions bool
+	FixPermissions    bool
+}
+
+// RegisterAuthFlags registers the credential and config flags on the
+// default flag set. Call it before flag.Parse.
+func RegisterAuthFlags() *AuthFlags {
+	f := &AuthFlags{}
+	flag.StringVar(&f.ClientID, "client-id", "", "Strava client ID (overrides $"+envClientID+", which overrides the config file)")
+	flag.StringVar(&f.ClientSecret, "client-secret", "", "Strava client secret (overrides $"+envClientSecret+", which overrides the config file)")
+	flag.StringVar(&f.RefreshToken, "refresh-token", "", "Strava refresh token (overrides $"+envRefreshToken+", which overrides the config file)")
+	flag.StringVar(&f.APIKey, "api-key", "", "Deprecated: use -refresh-token")
+	flag.BoolVar(&f.RefreshTokenStdin, "refresh-token-stdin", false, "Read the refresh token from the first line of stdin instead of -refresh-token, keeping it out of the process list")
+	flag.StringVar(&f.ConfigFile, "config", "", "Path to config file (default: config.json in -config-dir, falling back to ./"+auth.LegacyConfigFile+")")
+	flag.StringVar(&f.ConfigDir, "config-dir", "", "Directory holding config.json (default: the OS user config dir, e.g. $XDG_CONFIG_HOME/strava-activity-updater)")
+	flag.StringVar(&f.Profile, "profile", "", "Config profile to use when the config file holds multiple accounts")
+	flag.BoolVar(&f.StrictPermissions, "strict-permissions", false, "Refuse to use a config file that other users can read, instead of warning")
+	flag.BoolVar(&f.FixPermissions, "fix-permissions", false, "Make a config file that other users can read private to you (mode 0600)")
+	return f
+}
+
+// ConfigPath returns the config file to use: -config if given, otherwise
+// the file resolved from -config-dir and the legacy location.
+func (f *AuthFlags) ConfigPath() string {
+	if f.ConfigFile != "" {
+		return f.ConfigFile
+	}
+	return auth.ResolveConfigPath(f.ConfigDir)
+}
+
+// ApplyOverrides replaces config values with any credentials given in the
+// environment or on the command line. Each of the client ID, client secret
+// and refresh token is resolved separately: a flag beats its environment
+// variable, which beats the config file, and empty values don't count.
+// -api-key is used only without -refresh-token. It reports whether any
+// value came from the environment.
+func (f *AuthFlags) ApplyOverrides(config *auth.StravaConfig) (fromEnv bool) {
+	if f.RefreshTokenStdin {
+		if f.RefreshToken != "" || f.APIKey != "" {
+			log.Fatalf("-refresh-token-stdin can't be combined with -refresh-token or -api-key")
+		}
+		token, err := readRefreshToken(os.Stdin)
+		if err != nil {
+			log.Fatalf("Failed to read refresh token from stdin: %v", err)
+		}
+		// Stdin can only be read once, so later calls use the flag
+		f.RefreshToken = token
+		f.RefreshTokenStdin = false
+	}
+
+	override := func(field *string, env, flagValue string) {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+			fromEnv = true
+		}
+		if flagValue != "" {
+			*field = flagValue
+		}
+	}
+
+	refreshToken := f.RefreshToken
+	if refreshToken == "" && f.APIKey != "" {
+		log.Printf("Warning: -api-key is deprecated, use -refresh-token instead")
+		refreshToken = f.APIKey
+	}
+
+	override(&config.ClientID, envClientID, f.ClientID)
+	override(&config.ClientSecret, envClientSecret, f.ClientSecret)
+	saved := config.RefreshToken
+	override(&config.RefreshToken, envRefreshToken, refreshToken)
+	if config.RefreshToken != saved {
+		// The recorded scopes belong to the token from the file
+		config.Scope = ""
+	}
+
+	return fromEnv
+}
+
+// readRefreshToken reads a refresh token from the first line of r.
+func readRefreshToken(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("no refresh token on the first line")
+	}
+	return token, nil
+}
+
+// checkPermissions warns when the config file can be read by other users,
+// or with -strict-permissions exits, unless -fix-permissions makes it
+// private first.
+func (f *AuthFlags) checkPermissions(configPath string) {
+	perm, private, err := auth.ConfigPermissions(configPath)
+	if err != nil {
+		log.Printf("Warning: Failed to check config file permissions: %v", err)
+		return
+	}
+	if private {
+		return
+	}
+
+	if f.FixPermissions {
+		if err := os.Chmod(configPath, 0600); err != nil {
+			log.Fatalf("Failed to fix config file permissions: %v", err)
+		}
+		Infof("Made %s private (mode %04o -> 0600)", configPath, perm)
+		return
+	}
+	if f.StrictPermissions {
+		log.Fatalf("Config file %s can be read by other users (mode %04o) and holds your client secret and tokens. Run chmod 600 %s, or rerun with -fix-permissions", configPath, perm, configPath)
+	}
+	log.Printf("Warning: config file %s can be read by other users (mode %04o) and holds your client secret and tokens. Run chmod 600 %s, or rerun with -fix-permissions", configPath, perm, configPath)
+}
+
+// WarnMissingWriteScope warns before applying changes when the scopes
+// recorded for the token show that Strava will reject updates.
+func WarnMissingWriteScope(config *auth.StravaConfig) {
+	if config.MissingScope("activity:write") {
+		log.Printf("Warning: the token was only granted %q, so updates will fail. Re-authorize with scope=read,activity:read_all,activity:write", config.Scope)
+	}
+}
+
+// Authenticate loads the selected config profile, applies credential
+// overrides, ensures the access token is valid and saves any refreshed token
+// back to the config file. The config stays locked throughout, so tools
+// started at the same time take turns refreshing. It exits the program when
+// no usable credentials can be found.
+func (f *AuthFlags) Authenticate() *auth.StravaConfig {
+	configPath := f.ConfigPath()
+	unlock, err := auth.LockConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to lock config: %v", err)
+	}
+	defer unlock()
+
+	// Load configuration
+	config, err := auth.LoadProfile(configPath, f.Profile)
+	fileExists := err == nil
+	if os.IsNotExist(err) {
+		config = &auth.StravaConfig{Profile: f.Profile}
+	} else if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if fileExists {
+		f.checkPermissions(configPath)
+	}
+	loaded := *config
+
+	fromEnv := f.ApplyOverrides(config)
+	overridden := *config
+	if !customUserAgent && config.ClientID != "" {
+		setUserAgent(strava.UserAgent(toolName(), config.ClientID))
+	}
+	if !fileExists && !fromEnv {
+		Infof("Could not load config file, will attempt to create it")
+	}
+
+	if config.RefreshToken == "" {
+		log.Fatalf("No refresh token provided. Please specify one via the config file, $%s or the -refresh-token flag", envRefreshToken)
+	}
+
+	// Ensure we have a valid access token
+	if err := auth.EnsureValidToken(config); err != nil {
+		log.Fatalf("Failed to obtain valid token: %v", err)
+	}
+
+	saveRefreshed(configPath, loaded, overridden, *config, fileExists, fromEnv)
+	return config
+}
+
+// Refresh is Authenticate for tools that run for hours, such as the webhook
+// daemon: they outlive access tokens, and other tools may replace the
+// refresh token in the file while they run. It re-reads the config profile
+// under the lock, applies the overrides to it and refreshes the token if
+// needed, saving it by the same rules as Authenticate. It returns errors
+// instead of exiting, and when the file can't be read carries on with
+// previous, the config in use so far.
+func (f *AuthFlags) Refresh(previous *auth.StravaConfig) (*auth.StravaConfig, error) {
+	configPath := f.ConfigPath()
+	unlock, err := auth.LockConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	config, err := auth.LoadProfile(configPath, f.Profile)
+	fileExists := err == nil
+	canSave := fileExists || os.IsNotExist(err)
+	if err != nil {
+		current := *previous
+		config = &current
+	}
+	loaded := *config
+
+	fromEnv := f.ApplyOverrides(config)
+	overridden := *config
+	if err := auth.EnsureValidToken(config); err != nil {
+		return nil, fmt.Errorf("failed to obtain valid token: %w", err)
+	}
+
+	if canSave {
+		saveRefreshed(configPath, loaded, overridden, *config, fileExists, fromEnv)
+	}
+	return config, nil
+}
+
+// saveRefreshed writes what configToSave returns to the config file. A
+// still valid token leaves nothing to write, whatever the overrides, so the
+// file is only rewritten on a refresh.
+func saveRefreshed(configPath string, loaded, overridden, refreshed auth.StravaConfig, fileExists, fromEnv bool) {
+	if save := configToSave(loaded, overridden, refreshed, fileExists, fromEnv); save != nil {
+		if err := auth.SaveConfig(configPath, save); err != nil {
+			log.Printf("Warning: Failed to save config: %v", err)
+		}
+	}
+}
+
+// configToSave returns what Authenticate and Refresh write to the config
+// file, or nil
+// if there is nothing to write. loaded is the config as read from the file,
+// overridden the same after ApplyOverrides and refreshed after
+// EnsureValidToken.
+//
+// Credentials given in the environment or as flags stay out of an existing
+// file: it gains only refreshed tokens, and only when they were refreshed
+// with the file's own refresh token. Without a file, one is created from
+// flags but never from the environment, so containers keep secrets off
+// disk.
+func configToSave(loaded, overridden, refreshed auth.StravaConfig, fileExists, fromEnv bool) *auth.StravaConfig {
+	if !fileExists {
+		if fromEnv {
+			return nil
+		}
+		return &refreshed
+	}
+
+	save := loaded
+	if refreshed != overridden && overridden.RefreshToken == loaded.RefreshToken {
+		save.AccessToken = refreshed.AccessToken
+		save.RefreshToken = refreshed.RefreshToken
+		save.ExpiresAt = refreshed.ExpiresAt
+		save.Scope = refreshed.Scope
+	}
+	if save == loaded && !loaded.Migrated() {
+		return nil
+	}
+	return &save
+}
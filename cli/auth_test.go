@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"strava-activity-updater/auth"
+)
+
+// clearCredentialEnv unsets the credential variables for the test, so that
+// the developer's own don't leak in.
+func clearCredentialEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{envClientID, envClientSecret, envRefreshToken} {
+		t.Setenv(env, "")
+	}
+}
+
+// writeConfig saves config to a new file in a temporary directory.
+func writeConfig(t *testing.T, config auth.StravaConfig) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := auth.SaveConfig(path, &config); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readConfig(t *testing.T, path string) *auth.StravaConfig {
+	t.Helper()
+	config, err := auth.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestConfigToSave(t *testing.T) {
+	file := auth.StravaConfig{Version: auth.ConfigVersion, ClientID: "1", ClientSecret: "file-secret", RefreshToken: "file-refresh", AccessToken: "old", ExpiresAt: 100}
+	refreshedFrom := func(c auth.StravaConfig) auth.StravaConfig {
+		c.AccessToken, c.RefreshToken, c.ExpiresAt = "new", "rotated", 200
+		return c
+	}
+	withSecret := file
+	withSecret.ClientSecret = "env-secret"
+	withToken := file
+	withToken.RefreshToken = "env-refresh"
+	withToken.Scope = ""
+
+	tests := []struct {
+		name                string
+		overridden          auth.StravaConfig
+		refreshed           auth.StravaConfig
+		fileExists, fromEnv bool
+		want                *auth.StravaConfig
+	}{
+		{"valid token", file, file, true, false, nil},
+		{"valid token, secret from env", withSecret, withSecret, true, true, nil},
+		{"refreshed", file, refreshedFrom(file), true, false,
+			&auth.StravaConfig{Version: auth.ConfigVersion, ClientID: "1", ClientSecret: "file-secret", RefreshToken: "rotated", AccessToken: "new", ExpiresAt: 200}},
+		{"refreshed, secret from env", withSecret, refreshedFrom(withSecret), true, true,
+			&auth.StravaConfig{Version: auth.ConfigVersion, ClientID: "1", ClientSecret: "file-secret", RefreshToken: "rotated", AccessToken: "new", ExpiresAt: 200}},
+		{"refreshed with a token from env", withToken, refreshedFrom(withToken), true, true, nil},
+		{"no file, from env", withSecret, refreshedFrom(withSecret), false, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := configToSave(file, tt.overridden, tt.refreshed, tt.fileExists, tt.fromEnv)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("configToSave() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Without a file, flags are how one is first created
+	fromFlags := refreshedFrom(withSecret)
+	if got := configToSave(auth.StravaConfig{}, withSecret, fromFlags, false, false); got == nil || *got != fromFlags {
+		t.Errorf("configToSave() without a file = %+v, want the config from the flags", got)
+	}
+}
+
+func TestAuthenticateKeepsEnvSecretsOutOfFile(t *testing.T) {
+	clearCredentialEnv(t)
+	t.Setenv(envClientSecret, "env-secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if got := r.PostForm.Get("client_secret"); got != "env-secret" {
+			t.Errorf("client_secret = %q, want the one from the environment", got)
+		}
+		w.Write([]byte(`{"access_token": "new-access", "expires_at": 4102444800, "refresh_token": "new-refresh"}`))
+	}))
+	defer server.Close()
+	saved := auth.TokenURL
+	auth.TokenURL = server.URL
+	defer func() { auth.TokenURL = saved }()
+
+	path := writeConfig(t, auth.StravaConfig{ClientID: "1", ClientSecret: "file-secret", RefreshToken: "file-refresh"})
+	config := (&AuthFlags{ConfigFile: path}).Authenticate()
+	if config.AccessToken != "new-access" || config.ClientSecret != "env-secret" {
+		t.Errorf("Authenticate() = %+v, want the refreshed token and the secret from the environment", config)
+	}
+
+	onDisk := readConfig(t, path)
+	if onDisk.ClientSecret != "file-secret" {
+		t.Errorf("saved client secret %q, want the file's own", onDisk.ClientSecret)
+	}
+	if onDisk.AccessToken != "new-access" || onDisk.RefreshToken != "new-refresh" {
+		t.Errorf("saved tokens %q and %q, want the refreshed ones", onDisk.AccessToken, onDisk.RefreshToken)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "env-secret") {
+		t.Errorf("config file contains the secret from the environment:\n%s", data)
+	}
+}
+
+func TestRefreshKeepsOverridesOutOfFile(t *testing.T) {
+	clearCredentialEnv(t)
+	t.Setenv(envClientSecret, "env-secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		token := r.PostForm.Get("refresh_token")
+		w.Write([]byte(`{"access_token": "access-from-` + token + `", "expires_at": 4102444800, "refresh_token": "rotated-` + token + `"}`))
+	}))
+	defer server.Close()
+	saved := auth.TokenURL
+	auth.TokenURL = server.URL
+	defer func() { auth.TokenURL = saved }()
+
+	// The daemon's token expired since it started, and the secret still
+	// comes from the environment
+	path := writeConfig(t, auth.StravaConfig{ClientID: "1", ClientSecret: "file-secret", RefreshToken: "file-refresh", AccessToken: "expired", ExpiresAt: 1})
+	flags := &AuthFlags{ConfigFile: path}
+	config, err := flags.Refresh(&auth.StravaConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AccessToken != "access-from-file-refresh" || config.ClientSecret != "env-secret" {
+		t.Errorf("Refresh() = %+v, want the refreshed token and the secret from the environment", config)
+	}
+	onDisk := readConfig(t, path)
+	if onDisk.ClientSecret != "file-secret" || onDisk.AccessToken != "access-from-file-refresh" || onDisk.RefreshToken != "rotated-file-refresh" {
+		t.Errorf("saved %+v, want the refreshed tokens with the file's own secret", onDisk)
+	}
+
+	// A refresh token given as a flag is used, but neither it nor what it
+	// was exchanged for goes into the file
+	expired := auth.StravaConfig{ClientID: "1", ClientSecret: "file-secret", RefreshToken: "file-refresh", AccessToken: "expired", ExpiresAt: 1}
+	if err := auth.SaveConfig(path, &expired); err != nil {
+		t.Fatal(err)
+	}
+	flags.RefreshToken = "flag-refresh"
+	config, err = flags.Refresh(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.AccessToken != "access-from-flag-refresh" {
+		t.Errorf("Refresh() = %+v, want a token refreshed with the flag's refresh token", config)
+	}
+	data, _ := os.ReadFile(path)
+	for _, leaked := range []string{"env-secret", "flag-refresh"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("config file contains %q:\n%s", leaked, data)
+		}
+	}
+}
+
+func TestAuthenticateDoesNotWriteValidToken(t *testing.T) {
+	clearCredentialEnv(t)
+	t.Setenv(envClientSecret, "env-secret")
+
+	// Any token request fails the test: the token is still valid
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected token refresh")
+	}))
+	defer server.Close()
+	saved := auth.TokenURL
+	auth.TokenURL = server.URL
+	defer func() { auth.TokenURL = saved }()
+
+	path := writeConfig(t, auth.StravaConfig{ClientID: "1", ClientSecret: "file-secret", RefreshToken: "file-refresh",
+		AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	before, _ := os.ReadFile(path)
+	past := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	config := (&AuthFlags{ConfigFile: path, ClientID: "flag-id"}).Authenticate()
+	if config.ClientID != "flag-id" || config.ClientSecret != "env-secret" {
+		t.Errorf("Authenticate() = %+v, want the overrides applied", config)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("config file was rewritten at %s although the token is still valid", info.ModTime())
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("config file changed:\n%s\nwant:\n%s", after, before)
+	}
+}
+
+func TestApplyOverridesPrecedence(t *testing.T) {
+	file := auth.StravaConfig{ClientID: "file-id", ClientSecret: "file-secret", RefreshToken: "file-refresh", Scope: "read"}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		flags   AuthFlags
+		want    auth.StravaConfig
+		fromEnv bool
+	}{
+		{"file only", nil, AuthFlags{}, file, false},
+		{"env beats file", map[string]string{envClientID: "env-id", envClientSecret: "env-secret"}, AuthFlags{},
+			auth.StravaConfig{ClientID: "env-id", ClientSecret: "env-secret", RefreshToken: "file-refresh", Scope: "read"}, true},
+		{"flag beats file", nil, AuthFlags{ClientSecret: "flag-secret"},
+			auth.StravaConfig{ClientID: "file-id", ClientSecret: "flag-secret", RefreshToken: "file-refresh", Scope: "read"}, false},
+		{"flag beats env", map[string]string{envClientID: "env-id", envRefreshToken: "env-refresh"}, AuthFlags{ClientID: "flag-id", RefreshToken: "flag-refresh"},
+			auth.StravaConfig{ClientID: "flag-id", ClientSecret: "file-secret", RefreshToken: "flag-refresh"}, true},
+		{"each field separately", map[string]string{envClientSecret: "env-secret"}, AuthFlags{ClientID: "flag-id"},
+			auth.StravaConfig{ClientID: "flag-id", ClientSecret: "env-secret", RefreshToken: "file-refresh", Scope: "read"}, true},
+		{"api key alias", nil, AuthFlags{APIKey: "key-refresh"},
+			auth.StravaConfig{ClientID: "file-id", ClientSecret: "file-secret", RefreshToken: "key-refresh"}, false},
+		{"refresh token beats api key", nil, AuthFlags{RefreshToken: "flag-refresh", APIKey: "key-refresh"},
+			auth.StravaConfig{ClientID: "file-id", ClientSecret: "file-secret", RefreshToken: "flag-refresh"}, false},
+		{"same refresh token keeps scope", map[string]string{envRefreshToken: "file-refresh"}, AuthFlags{}, file, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCredentialEnv(t)
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			config := file
+			fromEnv := tt.flags.ApplyOverrides(&config)
+			if config != tt.want {
+				t.Errorf("got %+v, want %+v", config, tt.want)
+			}
+			if fromEnv != tt.fromEnv {
+				t.Errorf("fromEnv = %v, want %v", fromEnv, tt.fromEnv)
+			}
+		})
+	}
+}
+
+func TestReadRefreshToken(t *testing.T) {
+	token, err := readRefreshToken(strings.NewReader("  abc123  \nsecond line\n"))
+	if err != nil || token != "abc123" {
+		t.Errorf("got %q, %v; want abc123", token, err)
+	}
+	if _, err := readRefreshToken(strings.NewReader("\n")); err == nil {
+		t.Errorf("got no error for an empty first line")
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// backupTimeFormat names snapshots so that they sort by the time they were
+// taken.
+const backupTimeFormat = "20060102-150405"
+
+// WriteBackup saves activities to a timestamped JSON file in dir, creating
+// the directory if needed, and returns the file's path. The file is
+// readable by strava.ReadActivitiesJSON. Snapshots hold private notes and
+// private activities, so like the config file they are readable only by
+// you (mode 0600).
+func WriteBackup(dir string, activities []strava.Activity) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(dir, "activities-"+time.Now().Format(backupTimeFormat)+".json")
+	out, err := createOutput(path, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer out.Discard()
+
+	if err := strava.WriteActivitiesJSON(out, activities, true); err != nil {
+		return "", err
+	}
+	if err := out.Commit(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// backUp appends current to the batch's -backup-dir snapshot before it is
+// updated, opening the snapshot on first use. Each activity is written as
+// one line and unbuffered, so an interrupted batch still has a snapshot
+// of everything it changed. Like WriteBackup's, the snapshot is private.
+func (b *Batch) backUp(current strava.Activity) error {
+	if b.flags.BackupDir == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.backup == nil {
+		if err := os.MkdirAll(b.flags.BackupDir, 0700); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		path := filepath.Join(b.flags.BackupDir, "batch-"+b.started.Format(backupTimeFormat)+".json")
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open backup: %w", err)
+		}
+		b.backup = file
+	}
+
+	data, err := json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to back up activity: %w", err)
+	}
+	if _, err := b.backup.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to back up activity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// checkPrivate fails the test unless path is readable only by its owner.
+func checkPrivate(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("%s has mode %04o, want it private to its owner", path, perm)
+	}
+}
+
+func TestWriteBackupIsPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	path, err := WriteBackup(dir, []strava.Activity{{ID: 1, Name: "Morning Run", PrivateNote: "knee hurt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPrivate(t, path)
+	checkPrivate(t, dir)
+}
+
+func TestBatchBackupIsPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	b := &Batch{flags: &BatchFlags{BackupDir: dir}, started: time.Now()}
+	if err := b.backUp(strava.Activity{ID: 1, Name: "Morning Run", Visibility: "only_me"}); err != nil {
+		t.Fatal(err)
+	}
+	defer b.backup.Close()
+	checkPrivate(t, b.backup.Name())
+	checkPrivate(t, dir)
+}
@@ -0,0 +1,442 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// BatchFlags holds the flags that control how a batch of updates is applied.
+type BatchFlags struct {
+	FailFast     bool
+	Force        bool
+	Yes          bool
+	ProgressFile string
+	FailuresFile string
+	SummaryJSON  string
+	BackupDir    string
+	AllowBulk    bool
+	BulkLimit    int
+
+	// LogTemplate is the parsed -log-template, or nil for the tools' own
+	// log lines.
+	LogTemplate *template.Template
+}
+
+// RegisterBatchFlags registers the batch flags on the default flag set.
+// Call it before flag.Parse.
+func RegisterBatchFlags() *BatchFlags {
+	f := &BatchFlags{}
+	flag.BoolVar(&f.FailFast, "fail-fast", false, "Stop at the first failed update instead of continuing")
+	flag.BoolVar(&f.Force, "force", false, "Send updates even when the activity already has the desired values")
+	flag.StringVar(&f.ProgressFile, "progress-file", "", "Record updated activity IDs in this file so an interrupted run resumes where it left off; removed once the batch completes")
+	flag.StringVar(&f.FailuresFile, "failures-file", "", "Record failed updates in this file, for strava-activity-failures.go retry-failures")
+	flag.StringVar(&f.SummaryJSON, "summary-json", "", `Also write the batch summary as JSON to this file ("-" for stdout)`)
+	flag.StringVar(&f.BackupDir, "backup-dir", "", "Before each update, save the activity as it was to a timestamped snapshot in this directory")
+	flag.BoolVar(&f.AllowBulk, "allow-bulk", false, "Allow applying changes to more than -bulk-limit activities")
+	flag.IntVar(&f.BulkLimit, "bulk-limit", 100, "Refuse to apply changes to more activities than this without -allow-bulk (0 for no limit)")
+	flag.BoolVar(&f.Yes, "yes", false, "Apply changes without asking for confirmation (required when stdin is not a terminal)")
+	flag.Func("log-template", "Go template for the line logged for each updated activity, e.g. '{{.ID}} {{.Old.name}} -> {{.New.name}}' (fields: ID, Name, Changes, Old, New, Rules)", func(value string) error {
+		tmpl, err := parseLogTemplate(value)
+		if err != nil {
+			return err
+		}
+		f.LogTemplate = tmpl
+		return nil
+	})
+	return f
+}
+
+// Confirm asks the user to approve applying count changes and reports
+// whether to go ahead. With -yes it approves without asking. When stdin is
+// not a terminal there is nobody to ask, so it exits instead of hanging.
+// It also exits when count exceeds -bulk-limit without -allow-bulk, which
+// usually means a filter was looser than intended.
+func (f *BatchFlags) Confirm(count int) bool {
+	if f.BulkLimit > 0 && count > f.BulkLimit && !f.AllowBulk {
+		log.Fatalf("Refusing to apply changes to %d activities, more than the bulk limit of %d. Narrow the filter, or run with -allow-bulk if this is intended", count, f.BulkLimit)
+	}
+
+	if f.Yes {
+		return true
+	}
+
+	if !isTerminal(os.Stdin) {
+		log.Fatalf("Refusing to apply %d changes without confirmation: stdin is not a terminal, run with -yes", count)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nApply %d changes? [y/N] ", count)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+
+	log.Printf("Aborted, no changes were made")
+	return false
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Batch applies a series of activity updates and keeps the tally used for
+// the final summary and exit status. Create it just before applying changes
+// and defer Finish so the summary is printed however the batch ends.
+type Batch struct {
+	mu           sync.Mutex
+	flags        *BatchFlags
+	total        int
+	succeeded    int
+	unchanged    int
+	failed       []int64
+	exhausted    bool  // the API call budget ran out
+	exhaustedErr error // why, for the summary
+	fields       map[string]int
+	started      time.Time
+	progress     *Progress
+	signals      chan os.Signal
+
+	// done holds the IDs recorded in -progress-file by earlier runs, and
+	// resumed counts the ones skipped in this run. doneFile is the open
+	// progress file that new successes are appended to.
+	done     map[int64]bool
+	resumed  int
+	doneFile *os.File
+
+	// failures are the entries of -failures-file: failed updates from
+	// this run and earlier ones that haven't succeeded since.
+	failures []Failure
+
+	// backup is the -backup-dir snapshot of this batch, opened before the
+	// first update.
+	backup *os.File
+}
+
+// NewBatch starts a batch of total updates. If the process is interrupted
+// while the batch is running, the summary is printed before exiting.
+func NewBatch(total int, flags *BatchFlags, verbose bool) *Batch {
+	b := &Batch{
+		flags:    flags,
+		total:    total,
+		fields:   make(map[string]int),
+		started:  time.Now(),
+		progress: NewProgress(total, verbose),
+		signals:  make(chan os.Signal, 1),
+	}
+
+	if flags.ProgressFile != "" {
+		if err := b.openProgressFile(flags.ProgressFile); err != nil {
+			log.Fatalf("Failed to open progress file: %v", err)
+		}
+	}
+	if flags.FailuresFile != "" {
+		if err := b.openFailuresFile(flags.FailuresFile); err != nil {
+			log.Fatalf("Failed to open failures file: %v", err)
+		}
+	}
+
+	signal.Notify(b.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if sig, ok := <-b.signals; ok {
+			log.Printf("Interrupted (%s)", sig)
+			b.summarize()
+			os.Exit(130)
+		}
+	}()
+
+	return b
+}
+
+// Update sends one update and records whether it succeeded. Unless -force
+// is given, the request is skipped when current already has the desired
+// values (e.g. on a rerun after a partial batch), in which case Update
+// returns false.
+func (b *Batch) Update(accessToken string, current strava.Activity, update strava.ActivityUpdate) (bool, error) {
+	return b.UpdateWithRules(accessToken, current, update, nil)
+}
+
+// UpdateWithRules is Update for an update produced by rules, which are
+// passed on to -log-template.
+func (b *Batch) UpdateWithRules(accessToken string, current strava.Activity, update strava.ActivityUpdate, rules []string) (bool, error) {
+	if b.done[current.ID] {
+		Infof("Skipping activity ID %d: already updated by a previous run", current.ID)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.resumed++
+		b.progress.Step()
+		return false, nil
+	}
+
+	if !b.flags.Force && update.IsNoop(current) {
+		Infof("Skipping activity ID %d: already up to date", current.ID)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.unchanged++
+		b.clearFailure(current.ID)
+		b.progress.Step()
+		return false, nil
+	}
+
+	if err := b.backUp(current); err != nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.failed = append(b.failed, current.ID)
+		b.progress.Step()
+		return false, err
+	}
+
+	err := strava.UpdateActivity(accessToken, current.ID, update)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, strava.ErrCallBudgetExhausted) {
+		// Not a failure of this activity; it simply wasn't attempted
+		b.exhausted = true
+		b.exhaustedErr = err
+		return false, err
+	}
+	if err != nil {
+		b.failed = append(b.failed, current.ID)
+		b.recordFailure(current.ID, update, err)
+	} else {
+		b.succeeded++
+		for _, change := range changes(current, update) {
+			b.fields[change.Field]++
+		}
+		b.recordDone(current.ID)
+		b.clearFailure(current.ID)
+		b.logTemplate(current, update, rules)
+	}
+	b.progress.Step()
+
+	return err == nil, err
+}
+
+// logTemplate logs the -log-template line for a successful update.
+func (b *Batch) logTemplate(current strava.Activity, update strava.ActivityUpdate, rules []string) {
+	if b.flags.LogTemplate == nil {
+		return
+	}
+	line, err := renderUpdateLog(b.flags.LogTemplate, newUpdateLog(current, update, rules))
+	if err != nil {
+		log.Printf("Warning: Failed to render -log-template for activity ID %d: %v", current.ID, err)
+		Infof("Successfully updated activity ID %d", current.ID)
+		return
+	}
+	Infof("%s", line)
+}
+
+// LogSuccess logs a tool's own line for a successful update, unless
+// -log-template replaces it.
+func (b *Batch) LogSuccess(format string, args ...any) {
+	if b.flags.LogTemplate == nil {
+		Infof(format, args...)
+	}
+}
+
+// ShouldStop reports whether the batch should stop after an update
+// returned err: on any error with -fail-fast, when the API call budget is
+// exhausted, or when the token lacks the scope needed to write.
+func (b *Batch) ShouldStop(err error) bool {
+	// A missing scope fails every remaining update the same way
+	return b.flags.FailFast || errors.Is(err, strava.ErrCallBudgetExhausted) || strava.IsMissingScope(err)
+}
+
+// Complete reports whether every update in the batch was attempted and
+// none failed.
+func (b *Batch) Complete() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.failed) == 0 && b.succeeded+b.unchanged+b.resumed == b.total
+}
+
+// openProgressFile loads the IDs recorded by earlier runs and opens the
+// file for appending new ones.
+func (b *Batch) openProgressFile(filename string) error {
+	b.done = make(map[int64]bool)
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Fields(string(data)) {
+		id, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid activity ID %q in %s", line, filename)
+		}
+		b.done[id] = true
+	}
+	if len(b.done) > 0 {
+		Infof("Resuming: %d activities were already updated by a previous run", len(b.done))
+	}
+
+	b.doneFile, err = os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+// recordDone appends id to the progress file, if there is one. Writes are
+// unbuffered, so an interrupted run keeps every recorded success.
+func (b *Batch) recordDone(id int64) {
+	if b.doneFile == nil {
+		return
+	}
+	if _, err := fmt.Fprintln(b.doneFile, id); err != nil {
+		log.Printf("Warning: Failed to record progress: %v", err)
+	}
+}
+
+// ExitBudgetExhausted is the exit status when a batch stopped because the
+// API call budget ran out (EX_TEMPFAIL: rerun later to continue).
+const ExitBudgetExhausted = 75
+
+// Finish prints the summary and exits with status 1 if any update failed,
+// or ExitBudgetExhausted if the API call budget ran out.
+func (b *Batch) Finish() {
+	signal.Stop(b.signals)
+	close(b.signals)
+
+	if b.backup != nil {
+		b.backup.Close()
+		Infof("Activities as they were before this batch are saved in %s", b.backup.Name())
+	}
+	if b.doneFile != nil {
+		b.doneFile.Close()
+		if b.Complete() {
+			// Nothing left to resume
+			os.Remove(b.flags.ProgressFile)
+		}
+	}
+
+	failed := b.summarize()
+	if failed > 0 {
+		os.Exit(1)
+	}
+	if b.exhausted {
+		os.Exit(ExitBudgetExhausted)
+	}
+}
+
+// BatchSummary is what a batch did, as written by -summary-json.
+type BatchSummary struct {
+	Total        int     `json:"total"`
+	Succeeded    int     `json:"succeeded"`
+	Failed       int     `json:"failed"`
+	Unchanged    int     `json:"unchanged"`
+	Resumed      int     `json:"resumed"`
+	NotAttempted int     `json:"not_attempted"`
+	FailedIDs    []int64 `json:"failed_ids"`
+
+	// Fields counts the successful updates that changed each field
+	Fields map[string]int `json:"fields"`
+
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	APICalls       int64   `json:"api_calls"`
+}
+
+// summarize logs the succeeded/failed/unchanged counts, writes
+// -summary-json if requested and returns the failure count.
+func (b *Batch) summarize() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	summary := BatchSummary{
+		Total:          b.total,
+		Succeeded:      b.succeeded,
+		Failed:         len(b.failed),
+		Unchanged:      b.unchanged,
+		Resumed:        b.resumed,
+		NotAttempted:   b.total - b.succeeded - len(b.failed) - b.unchanged - b.resumed,
+		FailedIDs:      append([]int64{}, b.failed...),
+		Fields:         b.fields,
+		ElapsedSeconds: time.Since(b.started).Seconds(),
+		APICalls:       strava.DefaultClient.Calls(),
+	}
+
+	log.Printf("\nSummary: %d succeeded, %d failed, %d skipped (unchanged)",
+		b.succeeded, len(b.failed), b.unchanged)
+	if b.resumed > 0 {
+		log.Printf("  %d already updated by a previous run", b.resumed)
+	}
+	if summary.NotAttempted > 0 {
+		log.Printf("  %d not attempted", summary.NotAttempted)
+	}
+	if len(b.fields) > 0 {
+		var fields []string
+		for field, count := range b.fields {
+			fields = append(fields, fmt.Sprintf("%s %d", field, count))
+		}
+		sort.Strings(fields)
+		log.Printf("  Fields changed: %s", strings.Join(fields, ", "))
+	}
+	log.Printf("  Took %s and %d API calls in total", time.Since(b.started).Round(time.Second), summary.APICalls)
+	if len(b.failed) > 0 {
+		log.Printf("  Failed activity IDs: %v", b.failed)
+		if b.flags.FailuresFile != "" {
+			log.Printf("  Failed updates were saved to %s; retry them with strava-activity-failures.go retry-failures -failures-file=%s",
+				b.flags.FailuresFile, b.flags.FailuresFile)
+		}
+	}
+	var rateLimitErr *strava.RateLimitError
+	if errors.As(b.exhaustedErr, &rateLimitErr) {
+		log.Printf("  Stopped after %d API calls: %v; rerun then to continue with the remaining activities",
+			strava.DefaultClient.Calls(), rateLimitErr)
+	} else if b.exhausted {
+		log.Printf("  Stopped after %d API calls (-max-api-calls); rerun to continue with the remaining activities",
+			strava.DefaultClient.Calls())
+	}
+	if limiter := strava.DefaultClient.RateLimiter; limiter != nil {
+		if shortTerm, daily := limiter.Remaining(); shortTerm >= 0 || daily >= 0 {
+			log.Printf("  API requests remaining: %s in this 15-minute window, %s today",
+				formatRemaining(shortTerm), formatRemaining(daily))
+		}
+	}
+
+	if b.flags.SummaryJSON != "" {
+		if err := writeSummary(b.flags.SummaryJSON, summary); err != nil {
+			log.Printf("Warning: Failed to write summary: %v", err)
+		}
+	}
+
+	return len(b.failed)
+}
+
+func writeSummary(path string, summary BatchSummary) error {
+	out, err := CreateOutput(path)
+	if err != nil {
+		return err
+	}
+	defer out.Discard()
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		return err
+	}
+	return out.Commit()
+}
+
+// formatRemaining formats a RateLimiter.Remaining count, where -1 means no
+// limit.
+func formatRemaining(n int) string {
+	if n < 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
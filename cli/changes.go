@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+
+	"strava-activity-updater/strava"
+)
+
+// ExitChangesPending is the exit status of a -report-json dry run that
+// found changes to make, so pipelines can tell it apart from a clean run
+// (0) and from failures (1).
+const ExitChangesPending = 3
+
+// changeTimeLayout is the format of start times in a Change.
+const changeTimeLayout = "2006-01-02T15:04:05"
+
+// Change is one field a dry run would change on an activity.
+type Change struct {
+	ID    int64  `json:"id"`
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ChangeReport collects the changes proposed during a dry run and, with
+// -report-json, prints them as a JSON array on stdout. With -save-plan the
+// same array is written to a file, for strava-activity-plan.go to apply.
+type ChangeReport struct {
+	JSON     bool
+	PlanFile string
+	changes  []Change
+}
+
+// RegisterChangeReport registers the -report-json and -save-plan flags on
+// the default flag set. Call it before flag.Parse.
+func RegisterChangeReport() *ChangeReport {
+	r := &ChangeReport{}
+	flag.BoolVar(&r.JSON, "report-json", false, "In a dry run, print the proposed changes as JSON on stdout and exit with status 3 if there are any")
+	flag.StringVar(&r.PlanFile, "save-plan", "", "In a dry run, save the proposed changes to this file, to review and apply later with strava-activity-plan.go")
+	return r
+}
+
+// Add records the fields update would change on current.
+func (r *ChangeReport) Add(current strava.Activity, update strava.ActivityUpdate) {
+	r.changes = append(r.changes, changes(current, update)...)
+}
+
+// changes lists the fields update would change on current.
+func changes(current strava.Activity, update strava.ActivityUpdate) []Change {
+	var changes []Change
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, Change{ID: current.ID, Field: field, From: from, To: to})
+		}
+	}
+
+	if update.Name != "" {
+		add("name", current.Name, update.Name)
+	}
+	if update.SportType != "" {
+		add("sport_type", current.SportType, update.SportType)
+	}
+	if update.Type != "" {
+		add("type", current.Type, update.Type)
+	}
+	if update.Description != "" {
+		add("description", current.Description, update.Description)
+	}
+	if !update.StartDateLocal.IsZero() {
+		add("start_date_local", current.StartDateLocal.Format(changeTimeLayout), update.StartDateLocal.Format(changeTimeLayout))
+	}
+	if update.PrivateNote != nil {
+		add("private_note", current.PrivateNote, *update.PrivateNote)
+	}
+	if update.HideFromHome != nil {
+		add("hide_from_home", strconv.FormatBool(current.HideFromHome), strconv.FormatBool(*update.HideFromHome))
+	}
+	if update.Commute != nil {
+		add("commute", strconv.FormatBool(current.Commute), strconv.FormatBool(*update.Commute))
+	}
+	if update.Trainer != nil {
+		add("trainer", strconv.FormatBool(current.Trainer), strconv.FormatBool(*update.Trainer))
+	}
+	if update.Visibility != "" {
+		add("visibility", current.ActivityVisibility(), update.Visibility)
+	}
+	if update.WorkoutType != nil {
+		var from string
+		if current.WorkoutType != nil {
+			from = strconv.Itoa(*current.WorkoutType)
+		}
+		add("workout_type", from, strconv.Itoa(*update.WorkoutType))
+	}
+	if update.PerceivedExertion != nil {
+		var from string
+		if current.PerceivedExertion != nil {
+			from = strconv.FormatFloat(*current.PerceivedExertion, 'f', -1, 64)
+		}
+		add("perceived_exertion", from, strconv.Itoa(*update.PerceivedExertion))
+	}
+	return changes
+}
+
+// Finish ends a dry run. With -save-plan it saves the collected changes,
+// and with -report-json it prints them and exits with ExitChangesPending if
+// there are any; otherwise it does nothing.
+func (r *ChangeReport) Finish() {
+	r.printEstimate()
+
+	changes := r.changes
+	if changes == nil {
+		changes = []Change{}
+	}
+	if r.PlanFile != "" {
+		if err := savePlan(r.PlanFile, changes); err != nil {
+			log.Fatalf("Failed to save plan: %v", err)
+		}
+		Infof("Saved the plan to %s. To apply it, run strava-activity-plan.go -plan %s -apply", r.PlanFile, r.PlanFile)
+	}
+	if !r.JSON {
+		return
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(changes); err != nil {
+		log.Fatalf("Failed to write change report: %v", err)
+	}
+
+	if len(changes) > 0 {
+		os.Exit(ExitChangesPending)
+	}
+}
+
+// printEstimate logs what applying the proposed changes would cost: the
+// fetches this dry run made, which the real run repeats, plus one update
+// per activity, and how that fits in the rate limits and -max-api-calls.
+func (r *ChangeReport) printEstimate() {
+	ids := make(map[int64]bool)
+	for _, change := range r.changes {
+		ids[change.ID] = true
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	client := strava.DefaultClient
+	fetches := int(client.Calls())
+	calls := fetches + len(ids)
+	Infof("\nApplying would take about %d API calls (%d to fetch, %d updates)", calls, fetches, len(ids))
+
+	if client.MaxCalls > 0 && int64(calls) > client.MaxCalls {
+		log.Printf("Warning: That is more than -max-api-calls=%d; the batch will stop early and can be resumed with -progress-file", client.MaxCalls)
+	}
+	if client.RateLimiter == nil {
+		return
+	}
+	estimate := client.RateLimiter.Estimate(calls)
+	if estimate.Windows > 1 {
+		log.Printf("Warning: That spans %d 15-minute rate limit windows, so it can't finish before %s. The batch stops at the limit; rerun it with -progress-file once the window resets",
+			estimate.Windows, estimate.Finish.Local().Format("15:04"))
+	}
+	if estimate.ExceedsDaily {
+		_, daily := client.RateLimiter.Remaining()
+		log.Printf("Warning: That is more than the %d calls left in today's limit; split the job, e.g. with -max-api-calls, -until or -before", daily)
+	}
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"strava-activity-updater/auth"
+	"strava-activity-updater/strava"
+)
+
+// ClientFlags holds the flags that tune how the tools talk to the API.
+type ClientFlags struct {
+	Timeout          time.Duration
+	FetchConcurrency int
+	MaxAPICalls      int64
+	CacheFile        string
+	RateLimit        string
+	SlowdownAt       float64
+	UserAgent        string
+}
+
+// customUserAgent records whether -user-agent was given, in which case
+// Authenticate leaves it alone.
+var customUserAgent bool
+
+// RegisterClientFlags registers the API client flags on the default flag
+// set. Call it before flag.Parse.
+func RegisterClientFlags() *ClientFlags {
+	f := &ClientFlags{}
+	flag.DurationVar(&f.Timeout, "timeout", strava.DefaultTimeout, "Timeout for each API or token request (not the whole run)")
+	flag.Int64Var(&f.MaxAPICalls, "max-api-calls", 0, "Stop once this many API requests have been made (0 for no limit)")
+	flag.IntVar(&f.FetchConcurrency, "fetch-concurrency", 1, "Number of activity pages to fetch in parallel (1 fetches sequentially)")
+	flag.StringVar(&f.RateLimit, "rate-limit", fmt.Sprintf("%d,%d", strava.DefaultShortTermLimit, strava.DefaultDailyLimit), "Requests allowed per 15 minutes and per day, as SHORT,DAILY (0 disables a limit)")
+	flag.Float64Var(&f.SlowdownAt, "slowdown-at", 80, "Once Strava reports this percentage of a rate limit used, spread the remaining requests over the rest of the window (0 disables)")
+	flag.StringVar(&f.UserAgent, "user-agent", "", "User-Agent to send instead of the default, which names the tool, its version and the app's client ID")
+	flag.StringVar(&f.CacheFile, "cache-file", "", "Cache activity list pages in this file and revalidate them with conditional requests")
+
+	// Loaded while parsing, so that every later flag and file is validated
+	// against the extended list
+	flag.Func("sport-types", "Add the sport types listed in this file (one per line, -Type removes one) to the known list; repeatable", strava.LoadSportTypes)
+	return f
+}
+
+// Configure applies the flags to strava.DefaultClient.
+func (f *ClientFlags) Configure() {
+	strava.DefaultClient.Timeout = f.Timeout
+	auth.Timeout = f.Timeout
+	auth.HTTPClient = strava.DefaultClient.HTTPClient
+	strava.DefaultClient.FetchConcurrency = f.FetchConcurrency
+	strava.DefaultClient.MaxCalls = f.MaxAPICalls
+	customUserAgent = f.UserAgent != ""
+	if customUserAgent {
+		setUserAgent(f.UserAgent)
+	} else {
+		setUserAgent(strava.UserAgent(toolName(), ""))
+	}
+	strava.DefaultClient.Warnf = func(format string, args ...any) {
+		log.Printf("Warning: "+format, args...)
+	}
+
+	var shortTerm, daily int
+	if _, err := fmt.Sscanf(f.RateLimit, "%d,%d", &shortTerm, &daily); err != nil {
+		log.Fatalf("Invalid -rate-limit %q, expected SHORT,DAILY: %v", f.RateLimit, err)
+	}
+	if f.SlowdownAt < 0 || f.SlowdownAt > 100 {
+		log.Fatalf("Invalid -slowdown-at %g, expected a percentage from 0 to 100", f.SlowdownAt)
+	}
+	strava.DefaultClient.RateLimiter = strava.NewRateLimiter(shortTerm, daily)
+	strava.DefaultClient.RateLimiter.SlowdownAt = f.SlowdownAt / 100
+
+	if f.CacheFile != "" {
+		cache, err := strava.OpenFileCache(f.CacheFile)
+		if err != nil {
+			log.Fatalf("Failed to open cache: %v", err)
+		}
+		strava.DefaultClient.Cache = cache
+	}
+}
+
+// setUserAgent sets the User-Agent for both API and token requests.
+func setUserAgent(userAgent string) {
+	strava.DefaultClient.UserAgent = userAgent
+	auth.UserAgent = userAgent
+}
+
+// toolName returns the name of the running tool. With "go run", the
+// binary is named after the source file, e.g. "strava-activity-renamer".
+func toolName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"flag"
+
+	"strava-activity-updater/strava"
+)
+
+// RegisterDescriptionModeFlag registers -description-mode, which selects
+// how a tool writes descriptions (see strava.ApplyDescription). Call it
+// before flag.Parse.
+func RegisterDescriptionModeFlag(defaultMode strava.DescriptionMode) *strava.DescriptionMode {
+	mode := defaultMode
+	flag.Func("description-mode", "How to write descriptions: replace, append, prepend or append-if-missing (default "+string(defaultMode)+")", func(value string) error {
+		parsed, err := strava.ParseDescriptionMode(value)
+		mode = parsed
+		return err
+	})
+	return &mode
+}
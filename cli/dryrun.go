@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"flag"
+	"log"
+)
+
+// DryRunFlags holds -apply and the -dry-run flag it replaces. Tools only
+// show what they would change unless -apply is given.
+type DryRunFlags struct {
+	// DryRun is the resolved mode; it is set by Configure.
+	DryRun bool
+
+	apply  bool
+	dryRun bool
+}
+
+// RegisterDryRunFlags registers -apply and the deprecated -dry-run on the
+// default flag set. Call it before flag.Parse, and Configure after.
+func RegisterDryRunFlags() *DryRunFlags {
+	f := &DryRunFlags{}
+	flag.BoolVar(&f.apply, "apply", false, "Make the changes; without it, only show what would be changed")
+	flag.BoolVar(&f.dryRun, "dry-run", true, "Deprecated: use -apply (-dry-run=false is the same as -apply)")
+	return f
+}
+
+// Configure resolves the mode and logs which one is active. -dry-run=false
+// still applies changes, with a deprecation warning; asking for both
+// -apply and an explicit -dry-run is a contradiction and exits.
+func (f *DryRunFlags) Configure() {
+	dryRunSet := false
+	flag.Visit(func(fl *flag.Flag) {
+		if fl.Name == "dry-run" {
+			dryRunSet = true
+		}
+	})
+
+	switch {
+	case f.apply && dryRunSet && f.dryRun:
+		log.Fatalf("-apply and -dry-run contradict each other; use -apply to make changes")
+	case !f.apply && dryRunSet && !f.dryRun:
+		log.Printf("Warning: -dry-run=false is deprecated, use -apply instead")
+		f.apply = true
+	}
+
+	f.DryRun = !f.apply
+	if f.DryRun {
+		Infof("Mode: dry run, no changes will be made (use -apply to make them)")
+	} else {
+		Infof("Mode: apply, changes will be made")
+	}
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"strava-activity-updater/strava"
+)
+
+// Failure is an update that failed, as recorded in -failures-file.
+type Failure struct {
+	ID     int64                 `json:"id"`
+	Update strava.ActivityUpdate `json:"update"`
+	Error  string                `json:"error"`
+}
+
+// LoadFailures reads a failures file. A missing file holds no failures.
+func LoadFailures(filename string) ([]Failure, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var failures []Failure
+	if err := json.Unmarshal(data, &failures); err != nil {
+		return nil, fmt.Errorf("invalid failures file %s: %w", filename, err)
+	}
+	return failures, nil
+}
+
+// openFailuresFile loads the failures recorded by earlier runs, so that
+// they are kept unless this batch updates the same activities.
+func (b *Batch) openFailuresFile(filename string) error {
+	failures, err := LoadFailures(filename)
+	if err != nil {
+		return err
+	}
+	b.failures = failures
+	return nil
+}
+
+// recordFailure replaces any earlier failure of the same activity with
+// this one and saves the failures file, if there is one.
+func (b *Batch) recordFailure(id int64, update strava.ActivityUpdate, err error) {
+	if b.flags.FailuresFile == "" {
+		return
+	}
+	b.removeFailure(id)
+	b.failures = append(b.failures, Failure{ID: id, Update: update, Error: err.Error()})
+	b.saveFailures()
+}
+
+// clearFailure drops an earlier failure of an activity that has now been
+// updated, so the failures file only lists what is still outstanding.
+func (b *Batch) clearFailure(id int64) {
+	if b.flags.FailuresFile == "" {
+		return
+	}
+	if b.removeFailure(id) {
+		b.saveFailures()
+	}
+}
+
+func (b *Batch) removeFailure(id int64) bool {
+	for i, failure := range b.failures {
+		if failure.ID == id {
+			b.failures = append(b.failures[:i], b.failures[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// saveFailures rewrites the failures file. It goes through Output, so an
+// interrupted write can't lose the failures recorded so far.
+func (b *Batch) saveFailures() {
+	failures := b.failures
+	if failures == nil {
+		failures = []Failure{}
+	}
+
+	out, err := CreateOutput(b.flags.FailuresFile)
+	if err == nil {
+		defer out.Discard()
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(failures); err == nil {
+			err = out.Commit()
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to save failures: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// dateLayout is the format accepted by the date filter flags. Dates are
+// compared with each activity's local start date, i.e. the day it was on
+// where it took place.
+const dateLayout = "2006-01-02"
+
+// NameFilterFlags holds the flags that scope a tool to activities by
+// part of their name or by their visibility. Every tool that modifies
+// activities registers them, either directly or as part of FilterFlags.
+type NameFilterFlags struct {
+	NameContains    string
+	NameRegex       string
+	CaseInsensitive bool
+	Visibility      string
+	IDs             string
+	ExcludeSports   StringList
+}
+
+// visibilityAliases maps the friendlier -visibility values to the API's.
+var visibilityAliases = map[string]string{
+	"public":    "everyone",
+	"followers": "followers_only",
+	"private":   "only_me",
+}
+
+// ParseVisibility accepts one of strava.Visibilities or its friendlier
+// alias (public, followers, private) and returns the API's value.
+func ParseVisibility(value string) (string, error) {
+	visibility := value
+	if alias, ok := visibilityAliases[visibility]; ok {
+		visibility = alias
+	}
+	if !strava.IsValidVisibility(visibility) {
+		return "", fmt.Errorf("invalid visibility %q, expected one of %v or public, followers, private", value, strava.Visibilities)
+	}
+	return visibility, nil
+}
+
+// RegisterNameFilterFlags registers -name-contains, -name-regex, -ci,
+// -visibility, -exclude-sport and -ids on the default flag set. Call it
+// before flag.Parse.
+func RegisterNameFilterFlags() *NameFilterFlags {
+	f := &NameFilterFlags{}
+	flag.StringVar(&f.NameContains, "name-contains", "", "Only include activities whose name contains this text")
+	flag.StringVar(&f.NameRegex, "name-regex", "", "Only include activities whose name matches this regular expression")
+	flag.BoolVar(&f.CaseInsensitive, "ci", false, "Ignore case in -name-contains and -name-regex")
+	flag.StringVar(&f.Visibility, "visibility", "", `Only include activities visible to "everyone" (or "public"), "followers_only" (or "followers") or "only_me" (or "private")`)
+	flag.Var(&f.ExcludeSports, "exclude-sport", "Skip activities with this sport type, e.g. VirtualRide; comma-separated or repeatable")
+	flag.StringVar(&f.IDs, "ids", "", "Only include these activities, fetched individually instead of listing every activity: comma-separated IDs or a file of IDs")
+	return f
+}
+
+// FetchActivities returns the activities given with -ids, or else every
+// activity, or with incremental (which may be nil) only those it selects.
+// -ids can't be combined with -since-last-run, since the watermark would
+// then skip activities that were never processed.
+func (f *NameFilterFlags) FetchActivities(accessToken string, incremental *IncrementalFlags) ([]strava.Activity, error) {
+	if f.IDs == "" {
+		if incremental != nil {
+			return incremental.FetchActivities(accessToken)
+		}
+		return strava.GetAllActivities(accessToken)
+	}
+
+	if incremental != nil && (incremental.SinceLastRun || incremental.ResetWatermark) {
+		return nil, fmt.Errorf("-ids can't be used with -since-last-run or -reset-watermark")
+	}
+	ids, err := parseIDs(f.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return fetchByID(accessToken, ids)
+}
+
+// Filters converts the flags into strava filters.
+func (f *NameFilterFlags) Filters() ([]strava.Filter, error) {
+	var filters []strava.Filter
+
+	if f.NameContains != "" {
+		filters = append(filters, strava.ByNameContains(f.NameContains, f.CaseInsensitive))
+	}
+	if f.NameRegex != "" {
+		pattern := f.NameRegex
+		if f.CaseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -name-regex: %w", err)
+		}
+		filters = append(filters, strava.ByNameMatching(re))
+	}
+	if f.Visibility != "" {
+		visibility, err := ParseVisibility(f.Visibility)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -visibility: %w", err)
+		}
+		filters = append(filters, strava.ByVisibility(visibility))
+	}
+	var excluded []string
+	for _, value := range f.ExcludeSports {
+		for _, sportType := range strings.Split(value, ",") {
+			sportType = strings.TrimSpace(sportType)
+			if !strava.IsValidSportType(sportType) {
+				return nil, fmt.Errorf("invalid -exclude-sport: unknown sport type %q", sportType)
+			}
+			excluded = append(excluded, sportType)
+		}
+	}
+	if len(excluded) > 0 {
+		filters = append(filters, strava.ByNotSportType(excluded...))
+	}
+	if f.IDs != "" {
+		ids, err := parseIDs(f.IDs)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, strava.ByIDs(ids...))
+	}
+
+	return filters, nil
+}
+
+// FilterFlags holds the flags that narrow down which activities a tool
+// operates on.
+type FilterFlags struct {
+	*NameFilterFlags
+
+	Name      string
+	SportType string
+	After     string
+	Before    string
+	Since     string
+	Photos    string
+
+	since time.Time // -since resolved against the time it was first read
+}
+
+// RegisterFilterFlags registers the activity filter flags on the default
+// flag set. Call it before flag.Parse.
+func RegisterFilterFlags() *FilterFlags {
+	f := &FilterFlags{NameFilterFlags: RegisterNameFilterFlags()}
+	flag.StringVar(&f.Name, "name", "", "Only include activities with exactly this name")
+	flag.StringVar(&f.SportType, "sport-type", "", "Only include activities with this sport type")
+	flag.StringVar(&f.After, "after", "", "Only include activities started on or after this date (YYYY-MM-DD, in the activity's local time)")
+	flag.StringVar(&f.Before, "before", "", "Only include activities started before this date (YYYY-MM-DD, in the activity's local time)")
+	flag.StringVar(&f.Since, "since", "", "Only include activities started within this long before now: a Go duration (72h) or a number of days, weeks, months or years (30d, 2w, 6mo, 1y)")
+	flag.StringVar(&f.Photos, "photos", "", `Only include activities "with" or "without" photos`)
+	return f
+}
+
+// Filters converts the flags into strava filters.
+func (f *FilterFlags) Filters() ([]strava.Filter, error) {
+	filters, err := f.NameFilterFlags.Filters()
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Name != "" {
+		filters = append(filters, strava.ByName(f.Name))
+	}
+	if f.SportType != "" {
+		filters = append(filters, strava.BySportType(f.SportType))
+	}
+	if f.After != "" {
+		after, err := time.Parse(dateLayout, f.After)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -after date: %w", err)
+		}
+		filters = append(filters, strava.ByLocalStartAfter(after))
+	}
+	if f.Before != "" {
+		before, err := time.Parse(dateLayout, f.Before)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -before date: %w", err)
+		}
+		filters = append(filters, strava.ByLocalStartBefore(before))
+	}
+	if f.Since != "" {
+		if f.After != "" {
+			return nil, fmt.Errorf("-since and -after can't be used together")
+		}
+		since, err := f.sinceTime()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, strava.ByStartedAfter(since))
+	}
+
+	switch f.Photos {
+	case "":
+	case "with":
+		filters = append(filters, strava.ByHasPhotos(true))
+	case "without":
+		filters = append(filters, strava.ByHasPhotos(false))
+	default:
+		return nil, fmt.Errorf(`invalid -photos value %q, expected "with" or "without"`, f.Photos)
+	}
+
+	return filters, nil
+}
+
+// FetchActivities returns every activity, or with -since only those started
+// since then, which the API filters so older pages are never fetched. With
+// -ids only those activities are fetched (and -since filters them).
+func (f *FilterFlags) FetchActivities(accessToken string) ([]strava.Activity, error) {
+	if f.Since == "" || f.IDs != "" {
+		return f.NameFilterFlags.FetchActivities(accessToken, nil)
+	}
+	since, err := f.sinceTime()
+	if err != nil {
+		return nil, err
+	}
+	return strava.GetActivitiesInRange(accessToken, since, time.Time{})
+}
+
+// sinceTime resolves -since the first time it is called, so the filter and
+// the fetch agree on the cutoff.
+func (f *FilterFlags) sinceTime() (time.Time, error) {
+	if f.since.IsZero() {
+		since, err := parseSince(f.Since, time.Now())
+		if err != nil {
+			return time.Time{}, err
+		}
+		f.since = since
+	}
+	return f.since, nil
+}
+
+// sinceUnits maps the -since shorthand units to the calendar offset of one.
+var sinceUnits = map[string]func(t time.Time, n int) time.Time{
+	"d":  func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -n) },
+	"w":  func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -7*n) },
+	"mo": func(t time.Time, n int) time.Time { return t.AddDate(0, -n, 0) },
+	"y":  func(t time.Time, n int) time.Time { return t.AddDate(-n, 0, 0) },
+}
+
+var sinceShorthand = regexp.MustCompile(`^(\d+)(d|w|mo|y)$`)
+
+// parseSince returns the time value before now. Days, months and years
+// are calendar units as in time.AddDate rather than fixed numbers of hours,
+// so "6mo" lands on the same time of day across a DST change.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if m := sinceShorthand.FindStringSubmatch(value); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && n > 0 {
+			return sinceUnits[m[2]](now, n), nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since value %q, expected a duration like 30d, 2w, 6mo, 1y or 72h", value)
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"strava-activity-updater/strava"
+)
+
+// parseIDs parses the value of -ids: a comma-separated list of activity
+// IDs, or else the name of a file listing them separated by commas or
+// whitespace, with # starting a comment.
+func parseIDs(value string) ([]int64, error) {
+	if ids, err := splitIDs(value); err == nil {
+		return ids, nil
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -ids: %q is neither a list of IDs nor a readable file: %w", value, err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		lines = append(lines, line)
+	}
+	ids, err := splitIDs(strings.Join(lines, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -ids file %s: %w", value, err)
+	}
+	return ids, nil
+}
+
+// splitIDs parses IDs separated by commas or whitespace, dropping
+// duplicates.
+func splitIDs(s string) ([]int64, error) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	for _, field := range fields {
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid activity ID %q", field)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no activity IDs given")
+	}
+	return ids, nil
+}
+
+// fetchByID fetches each of ids individually, which for a handful of
+// activities is much cheaper than listing the whole history. IDs that
+// don't exist, or belong to another athlete, are reported and skipped.
+func fetchByID(accessToken string, ids []int64) ([]strava.Activity, error) {
+	athlete, err := strava.GetAthlete(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	Infof("Fetching %d activities by ID...", len(ids))
+	var activities []strava.Activity
+	for _, id := range ids {
+		activity, err := strava.GetActivityByID(accessToken, id)
+		if strava.IsNotFound(err) {
+			log.Printf("Warning: Activity ID %d does not exist, skipping", id)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get activity ID %d: %w", id, err)
+		}
+		if activity.Athlete.ID != athlete.ID {
+			log.Printf("Warning: Activity ID %d belongs to another athlete, skipping", id)
+			continue
+		}
+		activities = append(activities, *activity)
+	}
+	return activities, nil
+}
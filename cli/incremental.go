@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// runState is persisted between runs in the state file.
+type runState struct {
+	// Watermark is the start time of the newest activity processed by the
+	// last fully successful run.
+	Watermark time.Time `json:"watermark"`
+	// LastID is the ID of the newest activity processed at the
+	// watermark, so activities sharing that start time are told apart.
+	LastID int64 `json:"last_id,omitempty"`
+	// Empty records a cursor without a watermark, for an account that had
+	// no activities yet: every activity is newer than it.
+	Empty bool `json:"empty,omitempty"`
+}
+
+// processed reports whether activity is at or before the cursor.
+func (s *runState) processed(activity strava.Activity) bool {
+	if activity.StartDate.Equal(s.Watermark) {
+		return activity.ID <= s.LastID
+	}
+	return activity.StartDate.Before(s.Watermark)
+}
+
+// IncrementalFlags holds the flags for processing only activities newer
+// than the last successful run.
+type IncrementalFlags struct {
+	SinceLastRun   bool
+	StateFile      string
+	ResetWatermark bool
+	Until          string
+}
+
+// RegisterIncrementalFlags registers the incremental-mode flags on the
+// default flag set. Call it before flag.Parse.
+func RegisterIncrementalFlags() *IncrementalFlags {
+	f := &IncrementalFlags{}
+	flag.BoolVar(&f.SinceLastRun, "since-last-run", false, "Only process activities newer than the last successful run")
+	flag.StringVar(&f.StateFile, "state", "strava_state.json", "Path to the file recording the last successful run")
+	flag.BoolVar(&f.ResetWatermark, "reset-watermark", false, "Forget the last successful run and process the full history")
+	flag.StringVar(&f.Until, "until", "", "With -since-last-run, only process activities started before this date (YYYY-MM-DD, UTC)")
+	return f
+}
+
+// FetchActivities returns every activity, or with -since-last-run only the
+// activities after the recorded cursor, capped by -until. Like the cursor,
+// -until compares UTC start times: a cap on each activity's local time
+// could leave out an activity that started earlier than one it lets
+// through, which the watermark would then skip for good.
+//
+// The API's after parameter has one second resolution, so the fetch starts
+// a second early and activities at the watermark are then skipped by ID.
+// That way an activity sharing its start time with the newest processed
+// one is neither lost nor processed twice.
+func (f *IncrementalFlags) FetchActivities(accessToken string) ([]strava.Activity, error) {
+	if !f.SinceLastRun {
+		return strava.GetAllActivities(accessToken)
+	}
+
+	var until time.Time
+	if f.Until != "" {
+		var err error
+		until, err = time.Parse(dateLayout, f.Until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -until date: %w", err)
+		}
+	}
+
+	state := &runState{}
+	if !f.ResetWatermark {
+		var err error
+		if state, err = f.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	var after time.Time
+	if !state.Watermark.IsZero() {
+		Infof("Processing activities newer than %s", state.Watermark.Local().Format(time.RFC3339))
+		after = state.Watermark.Add(-time.Second)
+	}
+
+	activities, err := strava.GetActivitiesInRange(accessToken, after, until)
+	if err != nil {
+		return nil, err
+	}
+
+	var remaining []strava.Activity
+	for _, activity := range activities {
+		if !state.processed(activity) {
+			remaining = append(remaining, activity)
+		}
+	}
+	return remaining, nil
+}
+
+// Advance moves the cursor to the newest activity among activities, by start
+// time and then ID. Call it only after every activity was processed
+// successfully.
+func (f *IncrementalFlags) Advance(activities []strava.Activity) {
+	if !f.SinceLastRun && !f.ResetWatermark {
+		return
+	}
+
+	state, err := f.load()
+	if err != nil || f.ResetWatermark {
+		state = &runState{}
+	}
+	for _, activity := range activities {
+		if !state.processed(activity) {
+			state.Watermark = activity.StartDate
+			state.LastID = activity.ID
+			state.Empty = false
+		}
+	}
+	f.save(state)
+}
+
+// StartEmpty records a cursor for an account without activities, so that
+// every activity it gets from now on counts as new.
+func (f *IncrementalFlags) StartEmpty() {
+	f.save(&runState{Empty: true})
+}
+
+func (f *IncrementalFlags) save(state *runState) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = os.WriteFile(f.StateFile, data, 0600)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to save state: %v", err)
+	}
+}
+
+// HasWatermark reports whether the state file records a cursor yet,
+// including one set by StartEmpty.
+func (f *IncrementalFlags) HasWatermark() (bool, error) {
+	state, err := f.load()
+	if err != nil {
+		return false, err
+	}
+	return !state.Watermark.IsZero() || state.Empty, nil
+}
+
+func (f *IncrementalFlags) load() (*runState, error) {
+	state := &runState{}
+
+	data, err := os.ReadFile(f.StateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// withAPIServer points strava.DefaultClient at a test server running
+// handler for the duration of the test.
+func withAPIServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	saved := strava.DefaultClient.BaseURL
+	strava.DefaultClient.BaseURL = server.URL
+	t.Cleanup(func() { strava.DefaultClient.BaseURL = saved })
+}
+
+func TestUntilIsIndependentOfTimeZone(t *testing.T) {
+	var before string
+	withAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		before = r.URL.Query().Get("before")
+		w.Write([]byte(`[]`))
+	})
+	saved := time.Local
+	defer func() { time.Local = saved }()
+
+	for _, zone := range []*time.Location{time.UTC, time.FixedZone("PST", -8*3600), time.FixedZone("JST", 9*3600)} {
+		time.Local = zone
+		f := &IncrementalFlags{SinceLastRun: true, StateFile: filepath.Join(t.TempDir(), "state.json"), Until: "2024-03-09"}
+		if _, err := f.FetchActivities("access"); err != nil {
+			t.Fatal(err)
+		}
+		if before != "1709942400" {
+			t.Errorf("in %s, before = %s, want 1709942400 (2024-03-09 00:00 UTC)", zone, before)
+		}
+	}
+}
+
+func TestEmptyCursorTakesEveryActivity(t *testing.T) {
+	var after string
+	withAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		after = r.URL.Query().Get("after")
+		w.Write([]byte(`[{"id": 7, "start_date": "2024-03-09T07:30:00Z"}]`))
+	})
+
+	f := &IncrementalFlags{SinceLastRun: true, StateFile: filepath.Join(t.TempDir(), "state.json")}
+	if recorded, err := f.HasWatermark(); err != nil || recorded {
+		t.Fatalf("HasWatermark() = %v, %v before any run, want false", recorded, err)
+	}
+	f.StartEmpty()
+	if recorded, err := f.HasWatermark(); err != nil || !recorded {
+		t.Fatalf("HasWatermark() = %v, %v after StartEmpty, want true", recorded, err)
+	}
+
+	// The first activity recorded afterwards is new, whenever it started
+	activities, err := f.FetchActivities("access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 1 || activities[0].ID != 7 || after != "" {
+		t.Fatalf("got %+v with after=%q, want activity 7 from the whole history", activities, after)
+	}
+
+	f.Advance(activities)
+	state, err := f.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Empty || state.LastID != 7 {
+		t.Errorf("state after processing = %+v, want the cursor at activity 7", state)
+	}
+}
+
+func TestCursorSkipsProcessedActivitiesAtWatermark(t *testing.T) {
+	start := time.Date(2024, 3, 9, 7, 30, 0, 0, time.UTC)
+	activities := []strava.Activity{
+		{ID: 1, StartDate: start.Add(-time.Hour)},
+		{ID: 2, StartDate: start},
+		{ID: 3, StartDate: start},
+		{ID: 4, StartDate: start.Add(time.Hour)},
+	}
+	withAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": 3, "start_date": "2024-03-09T07:30:00Z"}, {"id": 4, "start_date": "2024-03-09T08:30:00Z"}]`))
+	})
+
+	f := &IncrementalFlags{SinceLastRun: true, StateFile: filepath.Join(t.TempDir(), "state.json")}
+	f.Advance(activities[:2])
+
+	remaining, err := f.FetchActivities("access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 || remaining[0].ID != 3 || remaining[1].ID != 4 {
+		t.Errorf("got %+v, want activities 3 and 4 (3 shares the watermark's start time)", remaining)
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"strava-activity-updater/strava"
+)
+
+// quiet suppresses Infof output; it is set by LogFlags.Configure.
+var quiet bool
+
+// LogFlags holds the flags that control how much the tools log.
+type LogFlags struct {
+	Verbose bool
+	Quiet   bool
+}
+
+// RegisterLogFlags registers -verbose and -quiet on the default flag set.
+// Call it before flag.Parse, and Configure after.
+func RegisterLogFlags() *LogFlags {
+	f := &LogFlags{}
+	flag.BoolVar(&f.Verbose, "verbose", false, "Enable verbose logging")
+	flag.BoolVar(&f.Quiet, "quiet", false, "Only log warnings and errors")
+	return f
+}
+
+// Configure sends the log to stderr, keeping stdout for results such as
+// reports and JSON, and applies -verbose and -quiet.
+func (f *LogFlags) Configure() {
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.Ldate | log.Ltime)
+	if f.Verbose {
+		strava.DefaultClient.Logf = log.Printf
+	}
+	quiet = f.Quiet
+}
+
+// Infof logs an informational message, which -quiet suppresses. Use
+// log.Printf for warnings and errors so they are always shown.
+func Infof(format string, args ...any) {
+	if !quiet {
+		log.Printf(format, args...)
+	}
+}
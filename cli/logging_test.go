@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected to files,
+// returning what was written to each.
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	dir := t.TempDir()
+	outFile, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedOut, savedErr, savedQuiet := os.Stdout, os.Stderr, quiet
+	os.Stdout, os.Stderr = outFile, errFile
+	defer func() {
+		os.Stdout, os.Stderr, quiet = savedOut, savedErr, savedQuiet
+		log.SetOutput(os.Stderr)
+	}()
+	fn()
+	outFile.Close()
+	errFile.Close()
+
+	outData, _ := os.ReadFile(outFile.Name())
+	errData, _ := os.ReadFile(errFile.Name())
+	return string(outData), string(errData)
+}
+
+// writeReport logs the way a tool does around writing its payload.
+func writeReport(t *testing.T, flags *LogFlags, payload string) {
+	flags.Configure()
+	Infof("Fetched %d activities", 3)
+	out, err := CreateOutput("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(out, payload)
+	if err := out.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("Warning: %s", "gear g1 not found")
+}
+
+func TestQuietStdoutHoldsOnlyThePayload(t *testing.T) {
+	payload := `{"names": [{"name": "Morning Run", "count": 3}]}` + "\n"
+	stdout, stderr := captureOutput(t, func() { writeReport(t, &LogFlags{Quiet: true}, payload) })
+
+	if stdout != payload {
+		t.Errorf("stdout = %q, want only the payload %q", stdout, payload)
+	}
+	if strings.Contains(stderr, "Fetched") {
+		t.Errorf("stderr has an informational message in quiet mode: %q", stderr)
+	}
+	if !strings.Contains(stderr, "Warning: gear g1 not found") {
+		t.Errorf("stderr = %q, want the warning kept in quiet mode", stderr)
+	}
+}
+
+func TestLogsGoToStderr(t *testing.T) {
+	payload := "kind,value,count\nname,Morning Run,3\n"
+	stdout, stderr := captureOutput(t, func() { writeReport(t, &LogFlags{}, payload) })
+
+	if stdout != payload {
+		t.Errorf("stdout = %q, want only the payload %q", stdout, payload)
+	}
+	if !strings.Contains(stderr, "Fetched 3 activities") || !strings.Contains(stderr, "Warning: gear g1 not found") {
+		t.Errorf("stderr = %q, want the informational message and the warning", stderr)
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"text/template"
+
+	"strava-activity-updater/strava"
+)
+
+// UpdateLog is what -log-template is evaluated against for each activity a
+// batch updates. Old and New map each changed field (as named in Change)
+// to its value before and after, e.g. {{.Old.name}} -> {{.New.name}}.
+type UpdateLog struct {
+	ID      int64
+	Name    string
+	Changes []Change
+	Old     map[string]string
+	New     map[string]string
+
+	// Rules are the rules that produced the update, for tools that apply
+	// rules; empty otherwise.
+	Rules []string
+}
+
+// parseLogTemplate parses a -log-template, with a join function for lists
+// such as Rules, and tries it on an example update, so that a mistake such
+// as an unknown field fails at startup rather than on the first update of
+// a batch.
+func parseLogTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("log-template").
+		Option("missingkey=zero").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	example := newUpdateLog(strava.Activity{ID: 1, Name: "Morning Ride"},
+		strava.ActivityUpdate{Name: "Commute"}, []string{"rule 1"})
+	if err := tmpl.Execute(io.Discard, example); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func newUpdateLog(current strava.Activity, update strava.ActivityUpdate, rules []string) UpdateLog {
+	entry := UpdateLog{
+		ID:      current.ID,
+		Name:    current.Name,
+		Changes: changes(current, update),
+		Old:     make(map[string]string),
+		New:     make(map[string]string),
+		Rules:   rules,
+	}
+	for _, change := range entry.Changes {
+		entry.Old[change.Field] = change.From
+		entry.New[change.Field] = change.To
+	}
+	return entry
+}
+
+// renderUpdateLog evaluates tmpl for an update, as a single log line.
+func renderUpdateLog(tmpl *template.Template, entry UpdateLog) (string, error) {
+	var line strings.Builder
+	if err := tmpl.Execute(&line, entry); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line.String(), "\n"), nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RegisterOutputFlag registers the -output flag shared by the reporting
+// tools. Call it before flag.Parse.
+func RegisterOutputFlag() *string {
+	return flag.String("output", "", `Write the report to this file instead of stdout ("-" for stdout)`)
+}
+
+// Output is the destination of a report. When writing to a file, the
+// report goes to a temporary file next to it that only replaces the target
+// on Commit, so a crash never leaves a partial report behind.
+type Output struct {
+	io.Writer
+	file *os.File // temporary file, nil when writing to stdout
+	path string
+}
+
+// CreateOutput opens path for writing, or stdout when path is empty or "-".
+func CreateOutput(path string) (*Output, error) {
+	return createOutput(path, 0644)
+}
+
+// createOutput is CreateOutput for a file created with mode perm.
+func createOutput(path string, perm os.FileMode) (*Output, error) {
+	if path == "" || path == "-" {
+		return &Output{Writer: os.Stdout}, nil
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	return &Output{Writer: file, file: file, path: path}, nil
+}
+
+// Commit finishes the report, replacing the target file with it.
+func (o *Output) Commit() error {
+	if o.file == nil {
+		return nil
+	}
+
+	if err := o.file.Close(); err != nil {
+		os.Remove(o.file.Name())
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(o.file.Name(), o.path); err != nil {
+		os.Remove(o.file.Name())
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	o.file = nil
+	return nil
+}
+
+// Discard abandons an uncommitted report. It is a no-op after Commit, so
+// it can be deferred.
+func (o *Output) Discard() {
+	if o.file == nil {
+		return
+	}
+
+	o.file.Close()
+	os.Remove(o.file.Name())
+	o.file = nil
+}
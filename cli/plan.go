@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+// PlannedUpdate is the update a plan makes to one activity, built from
+// the plan's changes to it.
+type PlannedUpdate struct {
+	ID      int64
+	Update  strava.ActivityUpdate
+	Changes []Change
+}
+
+// LoadPlan reads a plan saved by a dry run with -save-plan: the same JSON
+// array of changes that -report-json prints. The changes are grouped into
+// one update per activity, in the order the activities first appear.
+func LoadPlan(filename string) ([]PlannedUpdate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", filename, err)
+	}
+
+	var plan []PlannedUpdate
+	index := make(map[int64]int)
+	for _, change := range changes {
+		i, ok := index[change.ID]
+		if !ok {
+			i = len(plan)
+			index[change.ID] = i
+			plan = append(plan, PlannedUpdate{ID: change.ID})
+		}
+		if err := setField(&plan[i].Update, change.Field, change.To); err != nil {
+			return nil, fmt.Errorf("invalid plan %s: activity ID %d: %w", filename, change.ID, err)
+		}
+		plan[i].Changes = append(plan[i].Changes, change)
+	}
+	for _, planned := range plan {
+		if err := planned.Update.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid plan %s: activity ID %d: %w", filename, planned.ID, err)
+		}
+	}
+	return plan, nil
+}
+
+// savePlan writes changes to filename, replacing it only once complete.
+func savePlan(filename string, changes []Change) error {
+	out, err := CreateOutput(filename)
+	if err != nil {
+		return err
+	}
+	defer out.Discard()
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(changes); err != nil {
+		return err
+	}
+	return out.Commit()
+}
+
+// setField sets the field of update named as in a Change to value, the
+// inverse of changes.
+func setField(update *strava.ActivityUpdate, field, value string) error {
+	var err error
+	switch field {
+	case "name":
+		update.Name = value
+	case "sport_type":
+		update.SportType = value
+	case "type":
+		update.Type = value
+	case "description":
+		update.Description = value
+	case "start_date_local":
+		update.StartDateLocal, err = time.Parse(changeTimeLayout, value)
+	case "private_note":
+		update.PrivateNote = &value
+	case "hide_from_home":
+		var hide bool
+		hide, err = strconv.ParseBool(value)
+		update.HideFromHome = &hide
+	case "visibility":
+		update.Visibility = value
+	case "commute":
+		var commute bool
+		commute, err = strconv.ParseBool(value)
+		update.Commute = &commute
+	case "trainer":
+		var trainer bool
+		trainer, err = strconv.ParseBool(value)
+		update.Trainer = &trainer
+	case "workout_type":
+		var workoutType int
+		workoutType, err = strconv.Atoi(value)
+		update.WorkoutType = &workoutType
+	case "perceived_exertion":
+		var perceivedExertion int
+		perceivedExertion, err = strconv.Atoi(value)
+		update.PerceivedExertion = &perceivedExertion
+	default:
+		return fmt.Errorf("unknown field %q", field)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+	return nil
+}
+
+// Drift compares current with the values the plan expected to change
+// from. It returns a description of each field that has since changed to
+// something else; fields already at the planned value are not drift.
+func (p PlannedUpdate) Drift(current strava.Activity) []string {
+	now := make(map[string]string)
+	for _, change := range changes(current, p.Update) {
+		now[change.Field] = change.From
+	}
+
+	var drift []string
+	for _, change := range p.Changes {
+		value, pending := now[change.Field]
+		if pending && value != change.From {
+			drift = append(drift, fmt.Sprintf("%s is '%s', planned from '%s'", change.Field, value, change.From))
+		}
+	}
+	return drift
+}
+
+// Pending returns the part of the update that current doesn't have yet.
+func (p PlannedUpdate) Pending(current strava.Activity) strava.ActivityUpdate {
+	var update strava.ActivityUpdate
+	for _, change := range changes(current, p.Update) {
+		// The values came from the plan, so they parse
+		setField(&update, change.Field, change.To)
+	}
+	return update
+}
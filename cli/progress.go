@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"log"
+	"time"
+)
+
+// Progress logs "updated X/Y" lines while a batch of updates is applied,
+// with an ETA based on the average latency observed so far.
+type Progress struct {
+	total   int
+	done    int
+	start   time.Time
+	enabled bool
+}
+
+func NewProgress(total int, enabled bool) *Progress {
+	return &Progress{total: total, start: time.Now(), enabled: enabled}
+}
+
+// Step records one finished request and logs the running progress.
+func (p *Progress) Step() {
+	p.done++
+	if !p.enabled {
+		return
+	}
+
+	perRequest := time.Since(p.start) / time.Duration(p.done)
+	eta := perRequest * time.Duration(p.total-p.done)
+	log.Printf("Updated %d/%d (ETA %s)", p.done, p.total, eta.Round(time.Second))
+}
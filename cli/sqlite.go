@@ -0,0 +1,15 @@
+//go:build sqlite
+
+package cli
+
+import (
+	"database/sql"
+
+	// The pure Go driver, so that -tags sqlite builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite opens (creating it if needed) the SQLite database at path.
+func OpenSQLite(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", path)
+}
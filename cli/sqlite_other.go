@@ -0,0 +1,14 @@
+//go:build !sqlite
+
+package cli
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// OpenSQLite fails in builds without the sqlite tag, which leave out the
+// driver so that the other tools don't compile it.
+func OpenSQLite(path string) (*sql.DB, error) {
+	return nil, errors.New("this build has no SQLite support: pass -tags sqlite to go run")
+}
@@ -0,0 +1,61 @@
+//go:build sqlite
+
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"strava-activity-updater/strava"
+)
+
+func TestWriteActivitiesSQLiteUpserts(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "activities.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	race := 1
+	start := time.Date(2024, 3, 9, 7, 30, 0, 0, time.UTC)
+	activities := []strava.Activity{
+		{ID: 1, Name: "Morning Run", SportType: "Run", StartDate: start, StartDateLocal: start, Distance: 10000, WorkoutType: &race},
+		{ID: 2, Name: "Commute", SportType: "Ride", StartDate: start, StartDateLocal: start, Commute: true},
+	}
+	if err := strava.WriteActivitiesSQLite(db, activities); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exporting again replaces the rows rather than failing on the IDs
+	activities[0].Name = "Parkrun"
+	activities[0].WorkoutType = nil
+	if err := strava.WriteActivitiesSQLite(db, activities[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM activities").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %d rows, want 2", count)
+	}
+
+	var name, startLocal string
+	var workoutType *int
+	if err := db.QueryRow("SELECT name, start_date_local, workout_type FROM activities WHERE id = 1").Scan(&name, &startLocal, &workoutType); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Parkrun" || startLocal != "2024-03-09T07:30:00" || workoutType != nil {
+		t.Errorf("got %q, %q, %v; want the second export's values", name, startLocal, workoutType)
+	}
+
+	var commute bool
+	if err := db.QueryRow("SELECT commute FROM activities WHERE id = 2").Scan(&commute); err != nil {
+		t.Fatal(err)
+	}
+	if !commute {
+		t.Errorf("commute = false, want true")
+	}
+}
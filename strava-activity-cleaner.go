@@ -1,206 +1,355 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"os"
+	"regexp"
 	"strings"
-	"time"
 
-	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
 )
 
-type Activity struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	SportType   string    `json:"sport_type"`
-	StartDate   time.Time `json:"start_date"`
-	Description string    `json:"description"`
-}
-
-type ActivityUpdate struct {
-	Name        string `json:"name,omitempty"`
-	SportType   string `json:"sport_type,omitempty"`
-	Description string `json:"description,omitempty"`
-}
-
 func main() {
 	// Parse command line arguments
-	apiKeyPtr := flag.String("api-key", "", "Strava API key")
-	configFilePtr := flag.String("config", "strava_config.json", "Path to config file")
-	dryRunPtr := flag.Bool("dry-run", true, "Show what would be changed without making changes")
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	incrementalFlags := cli.RegisterIncrementalFlags()
+	glitchNamePtr := flag.String("glitch-name", "", "Rename activities shorter than -glitch-max-distance (likely GPS glitches) to this name")
+	glitchMaxDistancePtr := flag.Float64("glitch-max-distance", 100, "Maximum distance in meters for an activity to count as a GPS glitch")
+	var stripPrefixes, stripSuffixes cli.StringList
+	flag.Var(&stripPrefixes, "strip-prefix", "Remove this prefix from names; wrap in slashes for a regex (repeatable)")
+	flag.Var(&stripSuffixes, "strip-suffix", "Remove this suffix from names; wrap in slashes for a regex (repeatable)")
+	unicodePtr := flag.String("unicode", "", "Comma-separated Unicode cleanups to apply: mojibake (repair UTF-8 misread as Latin-1), nfc (Unicode NFC normalization), nbsp (non-breaking spaces), quotes (straighten curly quotes), or all")
+	renamePlaceholdersPtr := flag.Bool("rename-placeholders", false, "Rename activities with an empty or placeholder name (e.g. 'Afternoon Activity') after their start time and sport type, e.g. 'Afternoon Ride'")
+	var placeholders cli.StringList
+	flag.Var(&placeholders, "placeholder", "Additional name to treat as a placeholder with -rename-placeholders (repeatable)")
+	normalizeCasePtr := flag.Bool("normalize-case", false, "Rename names that differ only by case to their most used spelling")
+	var caseExceptions cli.StringList
+	flag.Var(&caseExceptions, "case-exception", "Word whose casing is intentional, e.g. HIIT or CrossFit, kept as given by -normalize-case (repeatable)")
 	flag.Parse()
 
 	// Set up logging
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.Ldate | log.Ltime)
+	logFlags.Configure()
+	dryRunFlags.Configure()
 
-	// Load configuration
-	config, err := auth.LoadConfig(*configFilePtr)
+	nameFilters, err := nameFilterFlags.Filters()
 	if err != nil {
-		log.Printf("Could not load config file, will attempt to create it")
-		config = &auth.StravaConfig{}
+		log.Fatalf("Invalid filter: %v", err)
 	}
 
-	// Set API key from command line if provided
-	if *apiKeyPtr != "" {
-		config.RefreshToken = *apiKeyPtr
+	rules := cleanRules{glitchName: *glitchNamePtr}
+	if *renamePlaceholdersPtr {
+		rules.placeholders = make(map[string]bool)
+		for _, name := range append(strava.DefaultPlaceholderNames, placeholders...) {
+			rules.placeholders[strings.ToLower(strings.TrimSpace(name))] = true
+		}
 	}
-
-	if config.RefreshToken == "" {
-		log.Fatalf("No refresh token provided. Please specify either via config file or -api-key flag")
+	if rules.glitchName != "" {
+		rules.isGlitch = strava.All(hasDistance, strava.ByMaxDistance(*glitchMaxDistancePtr))
 	}
-
-	// Ensure we have a valid access token
-	if err := auth.EnsureValidToken(config); err != nil {
-		log.Fatalf("Failed to obtain valid token: %v", err)
+	for _, prefix := range stripPrefixes {
+		re, err := compileStripRule(prefix, "^(?:%s)")
+		if err != nil {
+			log.Fatalf("Invalid -strip-prefix %q: %v", prefix, err)
+		}
+		rules.stripPrefixes = append(rules.stripPrefixes, re)
+	}
+	for _, suffix := range stripSuffixes {
+		re, err := compileStripRule(suffix, "(?:%s)$")
+		if err != nil {
+			log.Fatalf("Invalid -strip-suffix %q: %v", suffix, err)
+		}
+		rules.stripSuffixes = append(rules.stripSuffixes, re)
 	}
 
-	// Save updated config
-	if err := auth.SaveConfig(*configFilePtr, config); err != nil {
-		log.Printf("Warning: Failed to save config: %v", err)
+	transforms, err := parseUnicodeTransforms(*unicodePtr)
+	if err != nil {
+		log.Fatalf("Invalid -unicode: %v", err)
 	}
+	rules.unicode = transforms
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
 
 	// Get all activities
-	activities, err := getAllActivities(config.AccessToken)
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, incrementalFlags)
 	if err != nil {
 		log.Fatalf("Failed to get activities: %v", err)
 	}
 
-	// Find activities with leading/trailing spaces
-	var activitiesToUpdate []Activity
-	for _, activity := range activities {
-		trimmedName := strings.TrimSpace(activity.Name)
-		if trimmedName != activity.Name {
+	if *normalizeCasePtr {
+		groups := rules.caseGroups(activities, caseExceptions)
+		rules.canonicalCase = make(map[string]string)
+		for _, group := range groups {
+			rules.canonicalCase[strings.ToLower(group.Canonical)] = group.Canonical
+		}
+		printCaseGroups(groups)
+	}
+
+	// Find activities whose names need cleaning. Case groups above still
+	// count every activity, so the dominant spelling reflects the history.
+	var activitiesToUpdate []strava.Activity
+	for _, activity := range strava.FilterActivities(activities, nameFilters...) {
+		if rules.cleanName(activity) != activity.Name {
 			activitiesToUpdate = append(activitiesToUpdate, activity)
 		}
 	}
 
 	if len(activitiesToUpdate) == 0 {
-		log.Printf("No activities found with leading or trailing spaces")
+		cli.Infof("No activities found that need cleaning")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		} else {
+			incrementalFlags.Advance(activities)
+		}
 		return
 	}
 
 	// Print what would be changed
-	log.Printf("Found %d activities with leading or trailing spaces:", len(activitiesToUpdate))
+	cli.Infof("Found %d activities that need cleaning:", len(activitiesToUpdate))
 	for _, activity := range activitiesToUpdate {
-		trimmedName := strings.TrimSpace(activity.Name)
-		log.Printf("  ID: %d", activity.ID)
-		log.Printf("    From: '%s'", activity.Name)
-		log.Printf("    To:   '%s'", trimmedName)
+		cleanedName := rules.cleanName(activity)
+		cli.Infof("  ID: %d", activity.ID)
+		cli.Infof("    From: '%s'", activity.Name)
+		cli.Infof("    To:   '%s'", cleanedName)
+		changeReport.Add(activity, strava.ActivityUpdate{Name: cleanedName})
+		if applied := rules.unicodeChanges(activity.Name); len(applied) > 0 {
+			cli.Infof("    Unicode: %s", strings.Join(applied, ", "))
+		}
+		if highlighted := rules.highlight(activity); strings.Contains(highlighted, "«") {
+			cli.Infof("    Strip: '%s'", highlighted)
+		}
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
 	}
 
-	if *dryRunPtr {
-		log.Printf("\nThis was a dry run. To apply changes, run with -dry-run=false")
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
 		return
 	}
+	cli.WarnMissingWriteScope(config)
 
 	// Apply changes
-	log.Printf("\nApplying changes...")
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
 	for _, activity := range activitiesToUpdate {
-		trimmedName := strings.TrimSpace(activity.Name)
-		update := ActivityUpdate{
-			Name: trimmedName,
+		cleanedName := rules.cleanName(activity)
+		update := strava.ActivityUpdate{
+			Name: cleanedName,
 		}
 
-		if err := updateActivity(config.AccessToken, activity.ID, update); err != nil {
+		updated, err := batch.Update(config.AccessToken, activity, update)
+		if err != nil {
 			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
 			continue
 		}
 
-		log.Printf("Successfully updated activity ID %d: '%s' -> '%s'",
-			activity.ID, activity.Name, trimmedName)
+		batch.LogSuccess("Successfully updated activity ID %d: '%s' -> '%s'",
+			activity.ID, activity.Name, cleanedName)
+	}
+
+	if batch.Complete() {
+		incrementalFlags.Advance(activities)
 	}
 }
 
-func getAllActivities(accessToken string) ([]Activity, error) {
-	var allActivities []Activity
-	page := 1
-	perPage := 200 // Maximum allowed by Strava API
+// cleanRules describes how the cleaner rewrites activity names.
+type cleanRules struct {
+	// glitchName replaces the name of activities matched by isGlitch.
+	glitchName string
+	isGlitch   strava.Filter
 
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	// placeholders holds the lowercased names (besides the empty name) that
+	// are replaced by strava.DefaultName; it is only set with
+	// -rename-placeholders.
+	placeholders map[string]bool
 
-		url := fmt.Sprintf("https://www.strava.com/api/v3/athlete/activities?per_page=%d&page=%d", perPage, page)
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
+	// unicode are the Unicode cleanups applied before anything else.
+	unicode []strava.NameTransform
 
-		req.Header.Add("Authorization", "Bearer "+accessToken)
+	// stripPrefixes and stripSuffixes are anchored patterns removed from
+	// names after whitespace trimming.
+	stripPrefixes []*regexp.Regexp
+	stripSuffixes []*regexp.Regexp
 
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get activities: %w", err)
-		}
+	// canonicalCase maps lowercased names to the spelling they are
+	// normalized to; it is only set with -normalize-case.
+	canonicalCase map[string]string
+}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to get activities: %s - %s", resp.Status, string(body))
-		}
+// cleanName returns the cleaned-up name for an activity, which is the
+// current name when nothing needs to change.
+func (r cleanRules) cleanName(activity strava.Activity) string {
+	if r.isGlitch != nil && r.isGlitch(activity) {
+		return r.glitchName
+	}
 
-		var activities []Activity
-		if err := json.NewDecoder(resp.Body).Decode(&activities); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to decode activities: %w", err)
-		}
-		resp.Body.Close()
+	cleaned, _ := r.strip(activity.Name)
+	if r.isPlaceholder(cleaned) {
+		return strava.DefaultName(activity)
+	}
+	if canonical, ok := r.canonicalCase[strings.ToLower(cleaned)]; ok {
+		return canonical
+	}
+	return cleaned
+}
 
-		if len(activities) == 0 {
-			break
+// caseGroups groups the stripped names of all non-glitch activities that
+// differ only by case.
+func (r cleanRules) caseGroups(activities []strava.Activity, exceptions []string) []strava.CaseGroup {
+	nameCounts := make(map[string]int)
+	for _, activity := range activities {
+		if r.isGlitch != nil && r.isGlitch(activity) {
+			continue
 		}
+		cleaned, _ := r.strip(activity.Name)
+		nameCounts[cleaned]++
+	}
+	return strava.GroupByCase(nameCounts, exceptions)
+}
 
-		allActivities = append(allActivities, activities...)
-		page++
-
-		// If we got fewer activities than requested, we've reached the end
-		if len(activities) < perPage {
-			break
+// printCaseGroups lists each case group's spellings and the canonical form
+// they will be renamed to.
+func printCaseGroups(groups []strava.CaseGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	cli.Infof("Found %d names that differ only by case:", len(groups))
+	for _, group := range groups {
+		cli.Infof("  '%s':", group.Canonical)
+		for _, name := range group.Spellings() {
+			cli.Infof("    '%s' (%d)", name, group.Counts[name])
 		}
 	}
+}
+
+// highlight returns the trimmed name with stripped parts marked, or the
+// cleaned name when stripping doesn't apply.
+func (r cleanRules) highlight(activity strava.Activity) string {
+	if r.isGlitch != nil && r.isGlitch(activity) {
+		return r.glitchName
+	}
 
-	return allActivities, nil
+	_, highlighted := r.strip(activity.Name)
+	return highlighted
 }
 
-func updateActivity(accessToken string, activityID int64, update ActivityUpdate) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// isPlaceholder reports whether the cleaned name says nothing about the
+// activity and should be replaced with -rename-placeholders.
+func (r cleanRules) isPlaceholder(cleaned string) bool {
+	if r.placeholders == nil {
+		return false
+	}
+	return cleaned == "" || r.placeholders[strings.ToLower(cleaned)]
+}
 
-	// Convert update to JSON
-	updateJSON, err := json.Marshal(update)
-	if err != nil {
-		return fmt.Errorf("failed to marshal update: %w", err)
+// unicodeChanges returns the names of the Unicode cleanups that change
+// name.
+func (r cleanRules) unicodeChanges(name string) []string {
+	var applied []string
+	for _, transform := range r.unicode {
+		if transformed := transform.Apply(name); transformed != name {
+			applied = append(applied, transform.Name)
+			name = transformed
+		}
 	}
+	return applied
+}
 
-	// Create request
-	url := fmt.Sprintf("https://www.strava.com/api/v3/activities/%d", activityID)
-	req, err := http.NewRequestWithContext(ctx, "PUT", url,
-		strings.NewReader(string(updateJSON)))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// strip applies the Unicode cleanups, trims whitespace and removes the
+// configured prefixes and suffixes, re-trimming after each removal so none
+// is left behind. It also returns the trimmed name with each removed part
+// marked as «removed».
+func (r cleanRules) strip(name string) (cleaned, highlighted string) {
+	for _, transform := range r.unicode {
+		name = transform.Apply(name)
 	}
+	name = strings.TrimSpace(name)
 
-	req.Header.Add("Authorization", "Bearer "+accessToken)
-	req.Header.Add("Content-Type", "application/json")
+	var removedPrefix, removedSuffix string
+	for _, re := range r.stripPrefixes {
+		if loc := re.FindStringIndex(name); loc != nil && loc[1] > 0 {
+			removedPrefix += "«" + name[:loc[1]] + "»"
+			name = strings.TrimSpace(name[loc[1]:])
+		}
+	}
+	for _, re := range r.stripSuffixes {
+		if loc := re.FindStringIndex(name); loc != nil && loc[0] < len(name) {
+			removedSuffix = "«" + name[loc[0]:] + "»" + removedSuffix
+			name = strings.TrimSpace(name[:loc[0]])
+		}
+	}
 
-	// Send request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to update activity: %w", err)
+	return name, removedPrefix + name + removedSuffix
+}
+
+// parseUnicodeTransforms parses the -unicode flag into the matching
+// transforms, kept in their canonical order.
+func parseUnicodeTransforms(value string) ([]strava.NameTransform, error) {
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			for _, transform := range strava.UnicodeTransforms {
+				selected[transform.Name] = true
+			}
+			continue
+		}
+		known := false
+		for _, transform := range strava.UnicodeTransforms {
+			known = known || transform.Name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown cleanup %q", name)
+		}
+		selected[name] = true
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update activity: %s - %s", resp.Status, string(body))
+	var transforms []strava.NameTransform
+	for _, transform := range strava.UnicodeTransforms {
+		if selected[transform.Name] {
+			transforms = append(transforms, transform)
+		}
 	}
+	return transforms, nil
+}
+
+// compileStripRule compiles a -strip-prefix/-strip-suffix value into an
+// anchored regexp. Values wrapped in slashes are regexes; anything else is
+// matched literally.
+func compileStripRule(value, anchor string) (*regexp.Regexp, error) {
+	pattern := regexp.QuoteMeta(value)
+	if len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		pattern = value[1 : len(value)-1]
+	}
+	return regexp.Compile(fmt.Sprintf(anchor, pattern))
+}
 
-	return nil
+// hasDistance matches activities with a recorded distance, excluding manual
+// and indoor entries that report zero.
+func hasDistance(a strava.Activity) bool {
+	return a.Distance > 0
 }
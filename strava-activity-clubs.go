@@ -0,0 +1,52 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	logFlags := cli.RegisterLogFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	clubs, err := strava.GetAthleteClubs(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get clubs: %v", err)
+	}
+	if len(clubs) == 0 {
+		cli.Infof("You are not a member of any clubs")
+		return
+	}
+
+	fmt.Printf("%-10s %-40s %-10s %8s  %s\n", "ID", "Name", "Sport", "Members", "Location")
+	for _, club := range clubs {
+		location := club.City
+		if club.Country != "" {
+			if location != "" {
+				location += ", "
+			}
+			location += club.Country
+		}
+		name := club.Name
+		if club.Private {
+			name += " (private)"
+		}
+		fmt.Printf("%-10d %-40s %-10s %8d  %s\n", club.ID, name, club.SportType, club.MemberCount, location)
+	}
+}
@@ -0,0 +1,286 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+// commuteThresholds decide which rides look like commutes: short weekday
+// rides of a sport in SportTypes that start at about the same time of day,
+// and cover about the same distance, as at least MinSimilar other rides.
+type commuteThresholds struct {
+	SportTypes        []string
+	MinDistance       float64 // meters
+	MaxDistance       float64 // meters
+	TimeWindow        time.Duration
+	DistanceTolerance float64 // fraction of the distance
+	MinSimilar        int
+}
+
+// trainerThresholds decide which activities look like indoor trainer
+// sessions: recorded (not manual) activities of a sport in SportTypes with
+// no GPS route, lasting at least MinMovingTime.
+type trainerThresholds struct {
+	SportTypes    []string
+	MinMovingTime time.Duration
+}
+
+// inferCommutes returns the evidence for each activity in activities that
+// looks like a commute and isn't flagged as one yet.
+func inferCommutes(activities []strava.Activity, t commuteThresholds) map[int64]string {
+	var candidates []strava.Activity
+	for _, activity := range activities {
+		if !hasSport(activity, t.SportTypes) || activity.Trainer || activity.Manual {
+			continue
+		}
+		day := activity.StartDateLocal.Weekday()
+		if day == time.Saturday || day == time.Sunday {
+			continue
+		}
+		if activity.Distance < t.MinDistance || activity.Distance > t.MaxDistance {
+			continue
+		}
+		candidates = append(candidates, activity)
+	}
+
+	evidence := make(map[int64]string)
+	for _, activity := range candidates {
+		if activity.Commute {
+			continue
+		}
+		similar := 0
+		for _, other := range candidates {
+			if other.ID != activity.ID && similarRide(activity, other, t) {
+				similar++
+			}
+		}
+		if similar < t.MinSimilar {
+			continue
+		}
+		evidence[activity.ID] = fmt.Sprintf("%s ride of %.1f km at %s, like %d other weekday rides",
+			activity.StartDateLocal.Weekday(), activity.Distance/1000,
+			activity.StartDateLocal.Format("15:04"), similar)
+	}
+	return evidence
+}
+
+// similarRide reports whether b starts within the time window of a's time
+// of day and covers about the same distance.
+func similarRide(a, b strava.Activity, t commuteThresholds) bool {
+	if clockDiff(a.StartDateLocal, b.StartDateLocal) > t.TimeWindow {
+		return false
+	}
+	diff := a.Distance - b.Distance
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= a.Distance*t.DistanceTolerance
+}
+
+// clockDiff returns how far apart a and b are as times of day, ignoring
+// the date.
+func clockDiff(a, b time.Time) time.Duration {
+	seconds := func(t time.Time) int {
+		return t.Hour()*3600 + t.Minute()*60 + t.Second()
+	}
+	diff := seconds(a) - seconds(b)
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > 12*3600 {
+		diff = 24*3600 - diff
+	}
+	return time.Duration(diff) * time.Second
+}
+
+// inferTrainer returns the evidence that activity was recorded on an
+// indoor trainer, if it looks like it was and isn't flagged as such yet.
+func inferTrainer(activity strava.Activity, t trainerThresholds) (evidence string, ok bool) {
+	if activity.Trainer || activity.Manual || activity.Map.SummaryPolyline != "" {
+		return "", false
+	}
+	if !hasSport(activity, t.SportTypes) {
+		return "", false
+	}
+	movingTime := time.Duration(activity.MovingTime) * time.Second
+	if movingTime < t.MinMovingTime {
+		return "", false
+	}
+	return fmt.Sprintf("recorded %s with no GPS route", movingTime), true
+}
+
+func hasSport(activity strava.Activity, sportTypes []string) bool {
+	for _, sportType := range sportTypes {
+		if activity.SportType == sportType {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSports splits a comma-separated list of sport types, checking each.
+func splitSports(value, flagName string) []string {
+	var sports []string
+	for _, sport := range strings.Split(value, ",") {
+		sport = strings.TrimSpace(sport)
+		if sport == "" {
+			continue
+		}
+		if !strava.IsValidSportType(sport) {
+			log.Fatalf("Unknown sport type %q in -%s", sport, flagName)
+		}
+		sports = append(sports, sport)
+	}
+	return sports
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	commutePtr := flag.Bool("commute", true, "Propose setting the commute flag on likely commutes")
+	trainerPtr := flag.Bool("trainer", true, "Propose setting the trainer flag on likely indoor sessions")
+	commuteSportsPtr := flag.String("commute-sports", "Ride,EBikeRide", "Comma-separated sport types that can be commutes")
+	minKmPtr := flag.Float64("min-km", 1, "Shortest ride, in km, that can be a commute")
+	maxKmPtr := flag.Float64("max-km", 30, "Longest ride, in km, that can be a commute")
+	timeWindowPtr := flag.Duration("time-window", 30*time.Minute, "How close in time of day rides must start to count as similar")
+	distanceTolerancePtr := flag.Float64("distance-tolerance", 0.2, "How close in distance, as a fraction, rides must be to count as similar")
+	minSimilarPtr := flag.Int("min-similar", 3, "How many similar weekday rides a ride needs to be a commute")
+	trainerSportsPtr := flag.String("trainer-sports", "Ride,Run", "Comma-separated sport types that can be trainer sessions")
+	minTrainerTimePtr := flag.Duration("min-trainer-time", 10*time.Minute, "Shortest moving time for a trainer session")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	nameFilters, err := nameFilterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	if !*commutePtr && !*trainerPtr {
+		log.Fatalf("Nothing to infer. Please leave -commute or -trainer enabled")
+	}
+	if *minKmPtr < 0 || *maxKmPtr < *minKmPtr {
+		log.Fatalf("Invalid distance range %g to %g km", *minKmPtr, *maxKmPtr)
+	}
+	if *distanceTolerancePtr < 0 || *minSimilarPtr < 1 {
+		log.Fatalf("-distance-tolerance must not be negative and -min-similar must be at least 1")
+	}
+	commute := commuteThresholds{
+		SportTypes:        splitSports(*commuteSportsPtr, "commute-sports"),
+		MinDistance:       *minKmPtr * 1000,
+		MaxDistance:       *maxKmPtr * 1000,
+		TimeWindow:        *timeWindowPtr,
+		DistanceTolerance: *distanceTolerancePtr,
+		MinSimilar:        *minSimilarPtr,
+	}
+	trainer := trainerThresholds{
+		SportTypes:    splitSports(*trainerSportsPtr, "trainer-sports"),
+		MinMovingTime: *minTrainerTimePtr,
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, nil)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+	activities = strava.FilterActivities(activities, nameFilters...)
+
+	// Infer the flags, keeping the evidence for each
+	commuteEvidence := make(map[int64]string)
+	if *commutePtr {
+		commuteEvidence = inferCommutes(activities, commute)
+	}
+	var activitiesToUpdate []strava.Activity
+	updates := make(map[int64]strava.ActivityUpdate)
+	trainerEvidence := make(map[int64]string)
+	flagged := true
+	for _, activity := range activities {
+		var update strava.ActivityUpdate
+		if _, ok := commuteEvidence[activity.ID]; ok {
+			update.Commute = &flagged
+		}
+		if *trainerPtr {
+			if why, ok := inferTrainer(activity, trainer); ok {
+				update.Trainer = &flagged
+				trainerEvidence[activity.ID] = why
+			}
+		}
+		if update.IsNoop(activity) {
+			continue
+		}
+		activitiesToUpdate = append(activitiesToUpdate, activity)
+		updates[activity.ID] = update
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found that look like unflagged commutes or trainer sessions")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities that look like unflagged commutes or trainer sessions:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		if why, ok := commuteEvidence[activity.ID]; ok {
+			cli.Infof("    Commute: false -> true (%s)", why)
+		}
+		if why, ok := trainerEvidence[activity.ID]; ok {
+			cli.Infof("    Trainer: false -> true (%s)", why)
+		}
+		changeReport.Add(activity, updates[activity.ID])
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		updated, err := batch.Update(config.AccessToken, activity, updates[activity.ID])
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d", activity.ID)
+	}
+}
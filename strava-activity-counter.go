@@ -1,114 +1,287 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 //lint:ignore U1000 This is a main program file
 import (
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"os"
-	"sort"
+	"strconv"
 	"strings"
 
-	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
 	"strava-activity-updater/strava"
 )
 
 func main() {
 	// Parse command line arguments
-	apiKeyPtr := flag.String("api-key", "", "Strava API key")
-	configFilePtr := flag.String("config", "strava_config.json", "Path to config file")
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	logFlags := cli.RegisterLogFlags()
+	outputPtr := cli.RegisterOutputFlag()
+	var caseExceptions cli.StringList
+	flag.Var(&caseExceptions, "case-exception", "Word whose casing is intentional, e.g. HIIT or CrossFit, used when picking the canonical spelling (repeatable)")
+	formatPtr := flag.String("format", "table", `Output format: "table", "csv" or "json"`)
+	byPtr := flag.String("by", "name,sport_type", "Comma-separated counts to report: "+strings.Join(strava.AggregatorKinds, ", "))
+	minCountPtr := flag.Int("min-count", 0, "Only list values that appear at least this many times")
+	topPtr := flag.Int("top", 0, "List at most this many values per count, 0 for all")
 	flag.Parse()
 
 	// Set up logging
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.Ldate | log.Ltime)
+	logFlags.Configure()
 
-	// Load configuration
-	config, err := auth.LoadConfig(*configFilePtr)
+	switch *formatPtr {
+	case "table", "csv", "json":
+	default:
+		log.Fatalf("Invalid -format %q, expected table, csv or json", *formatPtr)
+	}
+	aggregators, err := strava.ParseAggregators(*byPtr)
 	if err != nil {
-		log.Printf("Could not load config file, will attempt to create it")
-		config = &auth.StravaConfig{}
+		log.Fatalf("Invalid -by: %v", err)
 	}
-
-	// Set API key from command line if provided
-	if *apiKeyPtr != "" {
-		config.RefreshToken = *apiKeyPtr
+	if *minCountPtr < 0 || *topPtr < 0 {
+		log.Fatalf("-min-count and -top must not be negative")
 	}
 
-	if config.RefreshToken == "" {
-		log.Fatalf("No refresh token provided. Please specify either via config file or -api-key flag")
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := strava.GetAllActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
 	}
 
-	// Ensure we have a valid access token
-	if err := auth.EnsureValidToken(config); err != nil {
-		log.Fatalf("Failed to obtain valid token: %v", err)
+	// Run every aggregator over the activities
+	strava.Aggregate(activities, aggregators...)
+	var counts []Counts
+	var groups []strava.CaseGroup
+	for _, aggregator := range aggregators {
+		rows := aggregator.Report()
+		switch aggregator.Kind() {
+		case "name":
+			nameCounts := make(map[string]int)
+			for _, row := range rows {
+				nameCounts[row.Key] = row.Count
+			}
+			groups = strava.GroupByCase(nameCounts, caseExceptions)
+		case "gear":
+			nameGear(config.AccessToken, rows)
+		}
+		counts = append(counts, Counts{Aggregator: aggregator, Rows: limitRows(rows, *minCountPtr, *topPtr), Total: len(rows)})
 	}
 
-	// Save updated config
-	if err := auth.SaveConfig(*configFilePtr, config); err != nil {
-		log.Printf("Warning: Failed to save config: %v", err)
+	out, err := cli.CreateOutput(*outputPtr)
+	if err != nil {
+		log.Fatalf("Failed to open output: %v", err)
 	}
+	defer out.Discard()
 
-	// Get all activities
-	activities, err := strava.GetAllActivities(config.AccessToken)
+	switch *formatPtr {
+	case "table":
+		printTable(out, counts, groups)
+	case "csv":
+		err = writeCSV(out, counts)
+	case "json":
+		err = writeJSON(out, counts, groups)
+	}
 	if err != nil {
-		log.Fatalf("Failed to get activities: %v", err)
+		log.Fatalf("Failed to write report: %v", err)
 	}
 
-	// Count activities by name and sport type
-	activityCounts := make(map[string]int)
-	sportTypeCounts := make(map[string]int)
-	for _, activity := range activities {
-		activityCounts[activity.Name]++
-		sportTypeCounts[activity.SportType]++
+	if err := out.Commit(); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
 	}
+}
+
+// Counts is the report of one aggregator. Rows may be cut down by
+// -min-count and -top; Total is the number of values before that.
+type Counts struct {
+	Aggregator strava.Aggregator
+	Rows       []strava.Row
+	Total      int
+}
 
-	// Convert to slices for sorting
-	type Count struct {
-		Name  string
-		Count int
+// limitRows drops the rows counted fewer than minCount times, then keeps
+// the first top of the rest (all of them if top is 0), in report order.
+func limitRows(rows []strava.Row, minCount, top int) []strava.Row {
+	var kept []strava.Row
+	for _, row := range rows {
+		if row.Count >= minCount {
+			kept = append(kept, row)
+		}
+	}
+	if top > 0 && len(kept) > top {
+		kept = kept[:top]
 	}
-	var nameCounts []Count
-	var sportTypeCountsList []Count
+	return kept
+}
+
+// kindLabels name what the built-in kinds count, for the table footers and
+// the JSON keys.
+var kindLabels = map[string]struct{ table, json string }{
+	"name":       {"activities", "names"},
+	"sport_type": {"sport types", "sport_types"},
+	"month":      {"months", "months"},
+	"gear":       {"gear", "gear"},
+}
 
-	for name, count := range activityCounts {
-		nameCounts = append(nameCounts, Count{name, count})
+// labels returns the kindLabels of kind, or the kind itself for other
+// aggregators.
+func labels(kind string) (table, json string) {
+	if l, ok := kindLabels[kind]; ok {
+		return l.table, l.json
 	}
-	for sportType, count := range sportTypeCounts {
-		sportTypeCountsList = append(sportTypeCountsList, Count{sportType, count})
+	return kind, kind
+}
+
+// nameGear replaces the gear IDs in rows with the gear names, looking each
+// up once, and sorts the rows again. Gear that can't be looked up keeps its
+// ID.
+func nameGear(accessToken string, rows []strava.Row) {
+	for i, row := range rows {
+		gear, err := strava.GetGear(accessToken, row.Key)
+		if err != nil {
+			log.Printf("Warning: Failed to get gear %s: %v", row.Key, err)
+			continue
+		}
+		rows[i].Key = gear.Name
 	}
+	strava.SortRows(rows)
+}
 
-	// Sort by count (descending)
-	sort.Slice(nameCounts, func(i, j int) bool {
-		return nameCounts[i].Count > nameCounts[j].Count
-	})
-	sort.Slice(sportTypeCountsList, func(i, j int) bool {
-		return sportTypeCountsList[i].Count > sportTypeCountsList[j].Count
-	})
+// printTable writes each aggregator's counts as a text table, visualizing
+// the spaces in names.
+func printTable(w io.Writer, counts []Counts, groups []strava.CaseGroup) {
+	for _, c := range counts {
+		kind := c.Aggregator.Kind()
+		fmt.Fprintf(w, "\n%s:\n", c.Aggregator.Title())
+		fmt.Fprintf(w, "--------------------\n")
+		for _, row := range c.Rows {
+			key := row.Key
+			if kind == "name" {
+				key = visualizeSpaces(key)
+			}
+			fmt.Fprintf(w, "%-40s %d\n", key, row.Count)
+		}
+		fmt.Fprintf(w, "--------------------\n")
+		label, _ := labels(kind)
+		if len(c.Rows) < c.Total {
+			fmt.Fprintf(w, "Total unique %s: %d (%d shown)\n", label, c.Total, len(c.Rows))
+		} else {
+			fmt.Fprintf(w, "Total unique %s: %d\n", label, c.Total)
+		}
 
-	// Print name counts
-	fmt.Printf("\nActivity Name Counts:\n")
-	fmt.Printf("--------------------\n")
-	for _, count := range nameCounts {
-		// Visualize spaces in the name
-		visualizedName := strings.ReplaceAll(count.Name, " ", "·")
-		if strings.HasPrefix(count.Name, " ") {
-			visualizedName = "→" + visualizedName
+		// Print names that differ only by case
+		if kind == "name" && len(groups) > 0 {
+			fmt.Fprintf(w, "\nNames Differing Only By Case:\n")
+			fmt.Fprintf(w, "--------------------\n")
+			for _, group := range groups {
+				fmt.Fprintf(w, "%s\n", group.Canonical)
+				for _, name := range group.Spellings() {
+					fmt.Fprintf(w, "  %-38s %d\n", name, group.Counts[name])
+				}
+			}
+			fmt.Fprintf(w, "--------------------\n")
+			fmt.Fprintf(w, "Run strava-activity-cleaner.go -normalize-case to apply the canonical spellings\n")
 		}
-		if strings.HasSuffix(count.Name, " ") {
-			visualizedName = visualizedName + "←"
+	}
+}
+
+// visualizeSpaces marks the spaces in name with dots, and leading and
+// trailing ones with arrows.
+func visualizeSpaces(name string) string {
+	visualizedName := strings.ReplaceAll(name, " ", "·")
+	if hasLeadingSpace(name) {
+		visualizedName = "→" + visualizedName
+	}
+	if hasTrailingSpace(name) {
+		visualizedName = visualizedName + "←"
+	}
+	return visualizedName
+}
+
+// hasLeadingSpace and hasTrailingSpace detect the whitespace the table
+// marks with arrows.
+func hasLeadingSpace(name string) bool {
+	return strings.HasPrefix(name, " ")
+}
+
+func hasTrailingSpace(name string) bool {
+	return strings.HasSuffix(name, " ")
+}
+
+// writeCSV writes one row per counted value. The kind column tells the
+// aggregators apart, and surrounding whitespace in names is flagged in its
+// own columns instead of being marked up in the name.
+func writeCSV(w io.Writer, counts []Counts) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"kind", "value", "count", "leading_space", "trailing_space"})
+	for _, c := range counts {
+		kind := c.Aggregator.Kind()
+		for _, row := range c.Rows {
+			leading, trailing := false, false
+			if kind == "name" {
+				leading, trailing = hasLeadingSpace(row.Key), hasTrailingSpace(row.Key)
+			}
+			writer.Write([]string{kind, row.Key, strconv.Itoa(row.Count),
+				strconv.FormatBool(leading), strconv.FormatBool(trailing)})
 		}
-		fmt.Printf("%-40s %d\n", visualizedName, count.Count)
 	}
-	fmt.Printf("--------------------\n")
-	fmt.Printf("Total unique activities: %d\n", len(nameCounts))
+	writer.Flush()
+	return writer.Error()
+}
+
+type jsonName struct {
+	Name          string `json:"name"`
+	Count         int    `json:"count"`
+	LeadingSpace  bool   `json:"leading_space"`
+	TrailingSpace bool   `json:"trailing_space"`
+}
+
+type jsonCaseGroup struct {
+	Canonical string         `json:"canonical"`
+	Spellings map[string]int `json:"spellings"`
+}
+
+// writeJSON writes the counts as a single JSON document with an array per
+// aggregator, e.g. "names" and "sport_types", and "case_groups" alongside
+// the names. Each entry holds the value under the aggregator's kind (e.g.
+// "sport_type") and its count.
+func writeJSON(w io.Writer, counts []Counts, groups []strava.CaseGroup) error {
+	doc := make(map[string]any)
+	for _, c := range counts {
+		kind := c.Aggregator.Kind()
+		if kind == "name" {
+			names := []jsonName{}
+			for _, row := range c.Rows {
+				names = append(names, jsonName{row.Key, row.Count, hasLeadingSpace(row.Key), hasTrailingSpace(row.Key)})
+			}
+			_, key := labels(kind)
+			doc[key] = names
+
+			caseGroups := []jsonCaseGroup{}
+			for _, group := range groups {
+				caseGroups = append(caseGroups, jsonCaseGroup{group.Canonical, group.Counts})
+			}
+			doc["case_groups"] = caseGroups
+			continue
+		}
 
-	// Print sport type counts
-	fmt.Printf("\nSport Type Counts:\n")
-	fmt.Printf("--------------------\n")
-	for _, count := range sportTypeCountsList {
-		fmt.Printf("%-40s %d\n", count.Name, count.Count)
+		entries := []map[string]any{}
+		for _, row := range c.Rows {
+			entries = append(entries, map[string]any{kind: row.Key, "count": row.Count})
+		}
+		_, key := labels(kind)
+		doc[key] = entries
 	}
-	fmt.Printf("--------------------\n")
-	fmt.Printf("Total unique sport types: %d\n", len(sportTypeCountsList))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
 }
@@ -0,0 +1,131 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	flag.Parse()
+	clientFlags.Configure()
+
+	log.SetOutput(os.Stdout)
+	log.SetFlags(0)
+
+	if !runChecks(authFlags) {
+		os.Exit(1)
+	}
+	log.Printf("\nAll checks passed")
+}
+
+// runChecks runs each diagnostic step in order, stopping at the first
+// failure since later steps depend on earlier ones.
+func runChecks(authFlags *cli.AuthFlags) bool {
+	// Config file exists
+	configPath := authFlags.ConfigPath()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fail("Config file %s can be read", err, configPath)
+	}
+	pass("Config file %s can be read", configPath)
+
+	// Config file is valid JSON
+	if !json.Valid(data) {
+		return fail("Config file is valid JSON", fmt.Errorf("invalid JSON"))
+	}
+	pass("Config file is valid JSON")
+
+	// Hold the lock until the refreshed token is saved
+	unlock, err := auth.LockConfig(configPath)
+	if err != nil {
+		return fail("Config file can be locked", err)
+	}
+	defer unlock()
+
+	// Selected profile loads
+	config, err := auth.LoadProfile(configPath, authFlags.Profile)
+	if err != nil {
+		return fail("Config profile loads", err)
+	}
+	if config.Profile != "" {
+		pass("Config profile %q loads", config.Profile)
+	}
+	authFlags.ApplyOverrides(config)
+
+	// Required fields are present
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"client_id", config.ClientID},
+		{"client_secret", config.ClientSecret},
+		{"refresh_token", config.RefreshToken},
+	} {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fail("Required fields are present", fmt.Errorf("missing %s", strings.Join(missing, ", ")))
+	}
+	pass("Required fields are present (client_id=%s, client_secret=%s, refresh_token=%s)",
+		config.ClientID, redact(config.ClientSecret), redact(config.RefreshToken))
+
+	// Token refresh works
+	if err := auth.RefreshToken(config); err != nil {
+		return fail("Token refresh succeeds", err)
+	}
+	pass("Token refresh succeeds (access_token=%s)", redact(config.AccessToken))
+	if err := auth.SaveConfig(configPath, config); err != nil {
+		log.Printf("Warning: Failed to save refreshed token: %v", err)
+	}
+
+	// Token is accepted by the API
+	athlete, err := strava.GetAthlete(config.AccessToken)
+	if err != nil {
+		return fail("Access token is accepted by the API", err)
+	}
+	pass("Access token is accepted by the API (athlete %d: %s %s)",
+		athlete.ID, athlete.Firstname, athlete.Lastname)
+
+	// Token can write, when the granted scopes are known
+	if config.MissingScope("activity:write") {
+		return fail("Token has the activity:write scope", fmt.Errorf("granted %q; re-authorize with scope=read,activity:read_all,activity:write", config.Scope))
+	}
+	if config.Scope != "" {
+		pass("Token has the activity:write scope (%s)", config.Scope)
+	}
+
+	return true
+}
+
+func pass(format string, args ...any) {
+	log.Printf("[PASS] "+format, args...)
+}
+
+// fail logs a failed check and returns false so callers can return it.
+func fail(format string, err error, args ...any) bool {
+	log.Printf("[FAIL] "+format, args...)
+	log.Printf("       %v", err)
+	return false
+}
+
+// redact hides all but the last four characters of a secret.
+func redact(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", 8) + secret[len(secret)-4:]
+}
@@ -0,0 +1,385 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  export-json      Write all activities as JSON\n")
+	fmt.Fprintf(os.Stderr, "  export-csv       Write all activities as CSV, for editing in a spreadsheet\n")
+	fmt.Fprintf(os.Stderr, "  export-comments  Write the comments on every activity as JSON\n")
+	fmt.Fprintf(os.Stderr, "  export-geojson   Write each activity's route as a GeoJSON FeatureCollection\n")
+	fmt.Fprintf(os.Stderr, "  export-tracks    Write each activity's recorded track as a GPX or TCX file in -tracks-dir\n")
+	fmt.Fprintf(os.Stderr, "  export-sqlite    Insert or update all activities in the SQLite database -output (needs -tags sqlite)\n")
+	fmt.Fprintf(os.Stderr, "  apply-csv        Update activities from an edited CSV (-input)\n")
+	fmt.Fprintf(os.Stderr, "  backup           Save all activities to a timestamped snapshot in -backup-dir\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	logFlags := cli.RegisterLogFlags()
+	outputPtr := cli.RegisterOutputFlag()
+	prettyPtr := flag.Bool("pretty", false, "Indent JSON output instead of writing one activity per line")
+	privateNotesPtr := flag.Bool("private-notes", false, "Fetch each activity's details so the backup includes private notes (one API call per activity)")
+	trackFormatPtr := flag.String("track-format", "gpx", `Format of export-tracks files: "gpx" or "tcx"; activities without GPS are always written as TCX`)
+	tracksDirPtr := flag.String("tracks-dir", "tracks", "Directory export-tracks writes <activity id>.gpx or .tcx files to")
+	inputPtr := flag.String("input", "", "CSV with an id column and the desired name, sport_type or description (apply-csv)")
+	descriptionModePtr := cli.RegisterDescriptionModeFlag(strava.DescriptionReplace)
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	batchFlags := cli.RegisterBatchFlags()
+	flag.Usage = usage
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+
+	// Set up logging
+	logFlags.Configure()
+
+	var db *sql.DB
+	switch command {
+	case "export-json", "export-csv", "export-comments", "export-geojson":
+	case "export-tracks":
+		if *trackFormatPtr != "gpx" && *trackFormatPtr != "tcx" {
+			log.Fatalf(`Invalid -track-format %q, expected "gpx" or "tcx"`, *trackFormatPtr)
+		}
+	case "export-sqlite":
+		if *outputPtr == "" || *outputPtr == "-" {
+			log.Fatalf("No database provided. Please pass the SQLite file to create or update with -output")
+		}
+		var err error
+		db, err = cli.OpenSQLite(*outputPtr)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+	case "backup":
+		if batchFlags.BackupDir == "" {
+			batchFlags.BackupDir = "backups"
+		}
+	case "apply-csv":
+		if *inputPtr == "" {
+			log.Fatalf("No input provided. Please pass the edited CSV with -input")
+		}
+		dryRunFlags.Configure()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	if command == "apply-csv" {
+		applyCSV(config, *inputPtr, *descriptionModePtr, dryRunFlags.DryRun, changeReport, batchFlags, logFlags.Verbose)
+		return
+	}
+
+	// Get all activities
+	activities, err := strava.GetAllActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	if command == "export-tracks" {
+		exportTracks(config.AccessToken, activities, *tracksDirPtr, *trackFormatPtr)
+		return
+	}
+
+	if command == "export-sqlite" {
+		if err := strava.WriteActivitiesSQLite(db, activities); err != nil {
+			log.Fatalf("Failed to write activities: %v", err)
+		}
+		cli.Infof("Exported %d activities to %s", len(activities), *outputPtr)
+		return
+	}
+
+	if command == "backup" {
+		if *privateNotesPtr {
+			activities = withDetails(config.AccessToken, activities)
+		}
+		path, err := cli.WriteBackup(batchFlags.BackupDir, activities)
+		if err != nil {
+			log.Fatalf("Failed to write backup: %v", err)
+		}
+		cli.Infof("Backed up %d activities to %s", len(activities), path)
+		return
+	}
+
+	out, err := cli.CreateOutput(*outputPtr)
+	if err != nil {
+		log.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Discard()
+
+	exported := len(activities)
+	switch command {
+	case "export-comments":
+		comments := fetchComments(config.AccessToken, activities)
+		exported = len(comments)
+		err = strava.WriteCommentsJSON(out, comments, *prettyPtr)
+	case "export-csv":
+		err = strava.WriteActivitiesCSV(out, activities)
+	case "export-geojson":
+		var malformed []int64
+		malformed, err = strava.WriteActivitiesGeoJSON(out, activities, *prettyPtr)
+		for _, id := range malformed {
+			log.Printf("Warning: Activity ID %d has a malformed route, exporting it without one", id)
+		}
+	default:
+		err = strava.WriteActivitiesJSON(out, activities, *prettyPtr)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write activities: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		log.Fatalf("Failed to write activities: %v", err)
+	}
+
+	cli.Infof("Exported %d activities", exported)
+}
+
+// fetchComments fetches the comments on each activity that has any.
+// Activities deleted since the list was fetched are skipped.
+func fetchComments(accessToken string, activities []strava.Activity) []strava.ActivityComments {
+	var withComments []strava.Activity
+	for _, activity := range activities {
+		if activity.CommentCount > 0 {
+			withComments = append(withComments, activity)
+		}
+	}
+	cli.Infof("Fetching comments on %d activities...", len(withComments))
+
+	var all []strava.ActivityComments
+	for _, activity := range withComments {
+		comments, err := strava.GetActivityComments(accessToken, activity.ID)
+		if strava.IsNotFound(err) {
+			log.Printf("Warning: Activity ID %d no longer exists, skipping", activity.ID)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Failed to get comments on activity ID %d: %v", activity.ID, err)
+		}
+		all = append(all, strava.ActivityComments{
+			ActivityID:     activity.ID,
+			Name:           activity.Name,
+			StartDateLocal: activity.StartDateLocal,
+			Comments:       comments,
+		})
+	}
+	return all
+}
+
+// exportTracks writes the track of each recorded activity to dir, one
+// file per activity. Every activity costs an API call, so files that
+// already exist are skipped: when the rate limit or -max-api-calls stops an
+// export, rerunning it picks up where it left off.
+func exportTracks(accessToken string, activities []strava.Activity, dir, format string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("Failed to create %s: %v", dir, err)
+	}
+
+	var pending []strava.Activity
+	existing := 0
+	for _, activity := range activities {
+		if activity.Manual {
+			continue
+		}
+		if trackExists(dir, activity.ID) {
+			existing++
+			continue
+		}
+		pending = append(pending, activity)
+	}
+	cli.Infof("Exporting the tracks of %d activities to %s (%d already exported)...", len(pending), dir, existing)
+
+	exported := 0
+	for _, activity := range pending {
+		streams, err := strava.GetActivityStreams(accessToken, activity.ID, strava.TrackStreamTypes)
+		if errors.Is(err, strava.ErrCallBudgetExhausted) || strava.IsRateLimited(err) {
+			log.Printf("Stopped after exporting %d tracks: %v. Rerun later to export the remaining %d", exported, err, len(pending)-exported)
+			os.Exit(cli.ExitBudgetExhausted)
+		}
+		if strava.IsNotFound(err) {
+			log.Printf("Warning: Activity ID %d has no recorded track, skipping", activity.ID)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Failed to get the track of activity ID %d: %v", activity.ID, err)
+		}
+
+		// GPX needs positions, so indoor activities are written as TCX
+		write, ext := strava.WriteGPX, ".gpx"
+		if format == "tcx" || len(streams.LatLng) == 0 {
+			write, ext = strava.WriteTCX, ".tcx"
+		}
+		path := filepath.Join(dir, strconv.FormatInt(activity.ID, 10)+ext)
+		out, err := cli.CreateOutput(path)
+		if err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		if err := write(out, activity, streams); err != nil {
+			out.Discard()
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		if err := out.Commit(); err != nil {
+			log.Fatalf("Failed to write %s: %v", path, err)
+		}
+		exported++
+	}
+
+	cli.Infof("Exported %d tracks", exported)
+}
+
+// trackExists reports whether an earlier export-tracks wrote the track of
+// activity id to dir, in either format.
+func trackExists(dir string, id int64) bool {
+	for _, ext := range []string{".gpx", ".tcx"} {
+		if _, err := os.Stat(filepath.Join(dir, strconv.FormatInt(id, 10)+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// withDetails replaces each activity with its detailed representation,
+// which adds the fields missing from the activity list such as the private
+// note.
+func withDetails(accessToken string, activities []strava.Activity) []strava.Activity {
+	detailed := make([]strava.Activity, 0, len(activities))
+	for _, activity := range activities {
+		details, err := strava.GetActivityByID(accessToken, activity.ID)
+		if err != nil {
+			log.Fatalf("Failed to get activity ID %d: %v", activity.ID, err)
+		}
+		detailed = append(detailed, *details)
+	}
+	return detailed
+}
+
+// applyCSV updates the activities listed in the CSV at path wherever a
+// value differs from the activity's current state. Each activity is fetched
+// again rather than trusting the exported values, since the CSV may be
+// stale by the time it is applied. Descriptions are written in mode.
+func applyCSV(config *auth.StravaConfig, path string, mode strava.DescriptionMode, dryRun bool, changeReport *cli.ChangeReport, batchFlags *cli.BatchFlags, verbose bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open input: %v", err)
+	}
+	edits, err := strava.ReadActivityEditsCSV(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	cli.Infof("Checking %d activities from %s...", len(edits), path)
+	var activitiesToUpdate []strava.Activity
+	updates := make(map[int64]strava.ActivityUpdate)
+	var unknown []strava.ActivityEdit
+	for _, edit := range edits {
+		if edit.Update == (strava.ActivityUpdate{}) {
+			continue
+		}
+
+		activity, err := strava.GetActivityByID(config.AccessToken, edit.ID)
+		if strava.IsNotFound(err) {
+			unknown = append(unknown, edit)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Failed to get activity ID %d: %v", edit.ID, err)
+		}
+
+		update := edit.Update.WithDescriptionMode(*activity, mode)
+		if update.IsNoop(*activity) {
+			continue
+		}
+		activitiesToUpdate = append(activitiesToUpdate, *activity)
+		updates[activity.ID] = update
+	}
+
+	for _, edit := range unknown {
+		log.Printf("Warning: Line %d references unknown activity ID %d, skipping", edit.Line, edit.ID)
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found that need changes")
+		if dryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities that need changes:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		update := updates[activity.ID]
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		changeReport.Add(activity, update)
+		if update.Name != "" && update.Name != activity.Name {
+			cli.Infof("    Name: '%s' -> '%s'", activity.Name, update.Name)
+		}
+		if update.SportType != "" && update.SportType != activity.SportType {
+			cli.Infof("    Sport type: %s -> %s", activity.SportType, update.SportType)
+		}
+		if update.Description != "" && update.Description != activity.Description {
+			cli.Infof("    Description: '%s' -> '%s'", activity.Description, update.Description)
+		}
+	}
+
+	if dryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		updated, err := batch.Update(config.AccessToken, activity, updates[activity.ID])
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d", activity.ID)
+	}
+}
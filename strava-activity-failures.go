@@ -0,0 +1,132 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  retry-failures   Re-attempt the updates recorded in -failures-file\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	flag.Usage = usage
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+
+	// Set up logging
+	logFlags.Configure()
+
+	if command != "retry-failures" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+	if batchFlags.FailuresFile == "" {
+		log.Fatalf("No failures file provided. Please pass the file a batch wrote with -failures-file")
+	}
+	dryRunFlags.Configure()
+
+	failures, err := cli.LoadFailures(batchFlags.FailuresFile)
+	if err != nil {
+		log.Fatalf("Failed to load failures: %v", err)
+	}
+	if len(failures) == 0 {
+		cli.Infof("No failed updates to retry")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Compare against the current state, since an activity may have been
+	// fixed by hand (or deleted) since the update failed
+	cli.Infof("Checking %d failed updates...", len(failures))
+	var activitiesToUpdate []strava.Activity
+	updates := make(map[int64]strava.ActivityUpdate)
+	failedWith := make(map[int64]string)
+	for _, failure := range failures {
+		activity, err := strava.GetActivityByID(config.AccessToken, failure.ID)
+		if err != nil {
+			log.Printf("Warning: Failed to get activity ID %d, keeping it in %s: %v", failure.ID, batchFlags.FailuresFile, err)
+			continue
+		}
+		activitiesToUpdate = append(activitiesToUpdate, *activity)
+		updates[activity.ID] = failure.Update
+		failedWith[activity.ID] = failure.Error
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No failed updates could be checked")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be retried
+	cli.Infof("Found %d failed updates to retry:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		cli.Infof("  ID: %d '%s' (failed: %s)", activity.ID, activity.Name, failedWith[activity.ID])
+		changeReport.Add(activity, updates[activity.ID])
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes. The batch shares -failures-file, so each success
+	// (or update that is no longer needed) is removed from it.
+	cli.Infof("\nRetrying updates...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		updated, err := batch.Update(config.AccessToken, activity, updates[activity.ID])
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d", activity.ID)
+	}
+}
@@ -0,0 +1,142 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	planPtr := flag.String("plan", "", "Plan saved by a dry run with -save-plan")
+	descriptionModePtr := cli.RegisterDescriptionModeFlag(strava.DescriptionReplace)
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	if *planPtr == "" {
+		log.Fatalf("No plan provided. Please pass the file a dry run wrote with -save-plan")
+	}
+	plan, err := cli.LoadPlan(*planPtr)
+	if err != nil {
+		log.Fatalf("Failed to load plan: %v", err)
+	}
+	if len(plan) == 0 {
+		cli.Infof("The plan has no changes")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Compare against the current state, and only apply what still starts
+	// from the values the plan was made against
+	cli.Infof("Checking %d planned updates...", len(plan))
+	var activitiesToUpdate []strava.Activity
+	updates := make(map[int64]strava.ActivityUpdate)
+	drifted := 0
+	for _, planned := range plan {
+		activity, err := strava.GetActivityByID(config.AccessToken, planned.ID)
+		if strava.IsNotFound(err) {
+			log.Printf("Warning: Activity ID %d no longer exists, skipping", planned.ID)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Failed to get activity ID %d: %v", planned.ID, err)
+		}
+
+		if drift := planned.Drift(*activity); len(drift) > 0 {
+			log.Printf("Warning: Skipping activity ID %d, it changed since the plan was made: %s", activity.ID, strings.Join(drift, "; "))
+			drifted++
+			continue
+		}
+		update := planned.Pending(*activity).WithDescriptionMode(*activity, *descriptionModePtr)
+		if update.IsNoop(*activity) {
+			continue
+		}
+		activitiesToUpdate = append(activitiesToUpdate, *activity)
+		updates[activity.ID] = update
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found that need changes")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities that need changes:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		update := updates[activity.ID]
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		changeReport.Add(activity, update)
+		if update.Name != "" {
+			cli.Infof("    Name: '%s' -> '%s'", activity.Name, update.Name)
+		}
+		if update.SportType != "" {
+			cli.Infof("    Sport type: %s -> %s", activity.SportType, update.SportType)
+		}
+		if update.Description != "" {
+			cli.Infof("    Description: '%s' -> '%s'", activity.Description, update.Description)
+		}
+		if !update.StartDateLocal.IsZero() {
+			cli.Infof("    Start: %s -> %s", activity.StartDateLocal.Format("2006-01-02 15:04:05"), update.StartDateLocal.Format("2006-01-02 15:04:05"))
+		}
+		if update.PrivateNote != nil {
+			cli.Infof("    Private note: '%s' -> '%s'", activity.PrivateNote, *update.PrivateNote)
+		}
+	}
+	if drifted > 0 {
+		log.Printf("Warning: %d planned updates were skipped because the activities changed; make a new plan for them", drifted)
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		updated, err := batch.Update(config.AccessToken, activity, updates[activity.ID])
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d", activity.ID)
+	}
+}
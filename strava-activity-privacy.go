@@ -0,0 +1,138 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	filterFlags := cli.RegisterFilterFlags()
+	toPtr := flag.String("to", "private", `Visibility to set: "only_me" (or "private"), "followers_only" (or "followers") or "everyone" (or "public")`)
+	hideFromHomePtr := flag.Bool("hide-from-home", false, "Also mute the activities from followers' home feeds")
+	confirmPtr := flag.Int("confirm", 0, "With -apply, the number of activities the dry run said would change, to confirm it was reviewed")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	if filterFlags.Before == "" {
+		log.Fatalf("No date provided. Please specify -before=YYYY-MM-DD; only activities started before it are changed")
+	}
+	visibility, err := cli.ParseVisibility(*toPtr)
+	if err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+	filters, err := filterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	update := strava.ActivityUpdate{Visibility: visibility}
+	if *hideFromHomePtr {
+		update.HideFromHome = hideFromHomePtr
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := filterFlags.FetchActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	var activitiesToUpdate []strava.Activity
+	for _, activity := range strava.FilterActivities(activities, filters...) {
+		if !update.IsNoop(activity) {
+			activitiesToUpdate = append(activitiesToUpdate, activity)
+		}
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities started before %s need changes", filterFlags.Before)
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed, then how many change from each
+	// visibility, so the scale is clear before anything is sent
+	cli.Infof("Found %d activities started before %s to change:", len(activitiesToUpdate), filterFlags.Before)
+	from := make(map[string]int)
+	for _, activity := range activitiesToUpdate {
+		current := activity.ActivityVisibility()
+		cli.Infof("  ID: %d '%s' (%s)", activity.ID, activity.Name, activity.StartDateLocal.Format("2006-01-02"))
+		if current != visibility {
+			cli.Infof("    Visibility: %s -> %s", current, visibility)
+			from[current]++
+		}
+		if update.HideFromHome != nil && !activity.HideFromHome {
+			cli.Infof("    Hide from home: false -> true")
+		}
+		changeReport.Add(activity, update)
+	}
+	var counts []string
+	changing := 0
+	for _, current := range strava.Visibilities {
+		if from[current] > 0 {
+			counts = append(counts, fmt.Sprintf("%d from %s", from[current], current))
+			changing += from[current]
+		}
+	}
+	if changing > 0 {
+		cli.Infof("%d activities will change visibility to %s: %s", changing, visibility, strings.Join(counts, ", "))
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply -confirm=%d", len(activitiesToUpdate))
+		changeReport.Finish()
+		return
+	}
+
+	// Changing who can see years of activities is hard to undo by hand,
+	// so insist on the count from a reviewed dry run
+	if *confirmPtr != len(activitiesToUpdate) {
+		log.Fatalf("Refusing to change %d activities without -confirm=%d. Review the dry run first; if the count differs from it, something changed since", len(activitiesToUpdate), len(activitiesToUpdate))
+	}
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		updated, err := batch.Update(config.AccessToken, activity, update)
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d: %s -> %s", activity.ID, activity.ActivityVisibility(), visibility)
+	}
+}
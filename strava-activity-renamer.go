@@ -1,107 +1,251 @@
+//go:build ignore
+// +build ignore
+
 package main
 
 //nolint:gochecknoglobals
 import (
 	"flag"
+	"fmt"
 	"log"
-	"os"
+	"sort"
 
-	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
 	"strava-activity-updater/strava"
 )
 
-var nameMappings = map[string]string{
-	"Pickup ice Hockey":        "Pickup Ice Hockey",
-	"Private Training Workout": "Private Training Session",
-	"Workout w/Trainer":        "Private Training Session",
-	"Workout":                  "Gym Workout",
-	"Gym Workou":               "Gym Workout",
+var nameMappings = strava.NameMappings{
+	Generic: map[string]string{
+		"Pickup ice Hockey":        "Pickup Ice Hockey",
+		"Private Training Workout": "Private Training Session",
+		"Workout w/Trainer":        "Private Training Session",
+		"Workout":                  "Gym Workout",
+		"Gym Workou":               "Gym Workout",
+	},
 }
 
 func main() {
 	// Parse command line arguments
-	apiKeyPtr := flag.String("api-key", "", "Strava API key")
-	configFilePtr := flag.String("config", "strava_config.json", "Path to config file")
-	dryRunPtr := flag.Bool("dry-run", true, "Show what would be changed without making changes")
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	incrementalFlags := cli.RegisterIncrementalFlags()
+	trainerOnlyPtr := flag.Bool("trainer-only", false, "Only rename activities recorded on an indoor trainer")
+	manualOnlyPtr := flag.Bool("manual-only", false, "Only rename manually-entered activities")
+	mappingsFilePtr := flag.String("mappings", "", "Path to a name mappings file, e.g. from strava-activity-suggest-mappings.go (default: built-in mappings)")
+	canonicalFilePtr := flag.String("canonical", "", "Path to a list of canonical names; names that no mapping covers are renamed to the nearest one (fuzzy mode)")
+	fuzzyDistancePtr := flag.Int("fuzzy-distance", 1, "In fuzzy mode, the most edits (ignoring case) a name may be from a canonical name")
+	fuzzyScorePtr := flag.Float64("fuzzy-score", 0.85, "In fuzzy mode, the minimum similarity (1 minus edits over name length) to rename")
 	flag.Parse()
 
 	// Set up logging
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.Ldate | log.Ltime)
+	logFlags.Configure()
+	dryRunFlags.Configure()
 
-	// Load configuration
-	config, err := auth.LoadConfig(*configFilePtr)
+	nameFilters, err := nameFilterFlags.Filters()
 	if err != nil {
-		log.Printf("Could not load config file, will attempt to create it")
-		config = &auth.StravaConfig{}
+		log.Fatalf("Invalid filter: %v", err)
 	}
 
-	// Set API key from command line if provided
-	if *apiKeyPtr != "" {
-		config.RefreshToken = *apiKeyPtr
+	if *mappingsFilePtr != "" {
+		mappings, err := strava.LoadNameMappings(*mappingsFilePtr)
+		if err != nil {
+			log.Fatalf("Failed to load name mappings: %v", err)
+		}
+		nameMappings = mappings
 	}
 
-	if config.RefreshToken == "" {
-		log.Fatalf("No refresh token provided. Please specify either via config file or -api-key flag")
+	var canonicalNames []string
+	if *canonicalFilePtr != "" {
+		var err error
+		canonicalNames, err = strava.LoadCanonicalNames(*canonicalFilePtr)
+		if err != nil {
+			log.Fatalf("Failed to load canonical names: %v", err)
+		}
 	}
 
-	// Ensure we have a valid access token
-	if err := auth.EnsureValidToken(config); err != nil {
-		log.Fatalf("Failed to obtain valid token: %v", err)
+	// Mappings win; fuzzy matching only covers the names they don't
+	rename := func(activity strava.Activity) (renaming, bool) {
+		if newName, exists := nameMappings.Lookup(activity.SportType, activity.Name); exists {
+			return renaming{newName: newName}, true
+		}
+		if canonicalNames == nil {
+			return renaming{}, false
+		}
+		match, ok := strava.NearestName(activity.Name, canonicalNames, *fuzzyDistancePtr, *fuzzyScorePtr)
+		if !ok || match.Canonical == activity.Name {
+			return renaming{}, false
+		}
+		return renaming{newName: match.Canonical, fuzzy: &match}, true
 	}
 
-	// Save updated config
-	if err := auth.SaveConfig(*configFilePtr, config); err != nil {
-		log.Printf("Warning: Failed to save config: %v", err)
-	}
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
 
 	// Get all activities
-	activities, err := strava.GetAllActivities(config.AccessToken)
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, incrementalFlags)
 	if err != nil {
 		log.Fatalf("Failed to get activities: %v", err)
 	}
 
+	// Scope the rules to trainer or manual activities if requested
+	filters := nameFilters
+	if *trainerOnlyPtr {
+		filters = append(filters, strava.ByTrainer(true))
+	}
+	if *manualOnlyPtr {
+		filters = append(filters, strava.ByManual(true))
+	}
+
 	// Find activities that need to be renamed
 	var activitiesToUpdate []strava.Activity
-	for _, activity := range activities {
-		if _, exists := nameMappings[activity.Name]; exists {
+	renames := make(map[int64]renaming)
+	for _, activity := range strava.FilterActivities(activities, filters...) {
+		if r, ok := rename(activity); ok {
 			activitiesToUpdate = append(activitiesToUpdate, activity)
+			renames[activity.ID] = r
 		}
 	}
 
 	if len(activitiesToUpdate) == 0 {
-		log.Printf("No activities found that need to be renamed")
+		cli.Infof("No activities found that need to be renamed")
+		if dryRunFlags.DryRun {
+			reportRuleUsage(activities)
+			changeReport.Finish()
+		} else {
+			incrementalFlags.Advance(activities)
+		}
 		return
 	}
 
 	// Print what would be changed
-	log.Printf("Found %d activities that need to be renamed:", len(activitiesToUpdate))
+	cli.Infof("Found %d activities that need to be renamed:", len(activitiesToUpdate))
 	for _, activity := range activitiesToUpdate {
-		newName := nameMappings[activity.Name]
-		log.Printf("  ID: %d", activity.ID)
-		log.Printf("    From: '%s'", activity.Name)
-		log.Printf("    To:   '%s'", newName)
+		r := renames[activity.ID]
+		cli.Infof("  ID: %d", activity.ID)
+		cli.Infof("    From: '%s'", activity.Name)
+		if r.fuzzy != nil {
+			cli.Infof("    To:   '%s' (fuzzy: %d edits, %.0f%% similar)", r.newName, r.fuzzy.Distance, r.fuzzy.Score*100)
+		} else {
+			cli.Infof("    To:   '%s'", r.newName)
+		}
+		changeReport.Add(activity, strava.ActivityUpdate{Name: r.newName})
+	}
+
+	if dryRunFlags.DryRun {
+		reportRuleUsage(activities)
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
 	}
 
-	if *dryRunPtr {
-		log.Printf("\nThis was a dry run. To apply changes, run with -dry-run=false")
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
 		return
 	}
+	cli.WarnMissingWriteScope(config)
 
 	// Apply changes
-	log.Printf("\nApplying changes...")
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
 	for _, activity := range activitiesToUpdate {
-		newName := nameMappings[activity.Name]
+		newName := renames[activity.ID].newName
 		update := strava.ActivityUpdate{
 			Name: newName,
 		}
 
-		if err := strava.UpdateActivity(config.AccessToken, activity.ID, update); err != nil {
+		updated, err := batch.Update(config.AccessToken, activity, update)
+		if err != nil {
 			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
 			continue
 		}
 
-		log.Printf("Successfully updated activity ID %d: '%s' -> '%s'",
+		batch.LogSuccess("Successfully updated activity ID %d: '%s' -> '%s'",
 			activity.ID, activity.Name, newName)
 	}
+
+	if batch.Complete() {
+		incrementalFlags.Advance(activities)
+	}
+}
+
+// renaming is the new name chosen for an activity, and the fuzzy match
+// behind it when no mapping covered the name.
+type renaming struct {
+	newName string
+	fuzzy   *strava.FuzzyMatch
+}
+
+// reportRuleUsage lists mapping rules that matched no activity, so dead
+// rules can be pruned, and activity names that are one edit away from a
+// rule but didn't match it exactly.
+func reportRuleUsage(activities []strava.Activity) {
+	nameCounts := make(map[string]int)
+	sportNameCounts := make(map[[2]string]int)
+	for _, activity := range activities {
+		nameCounts[activity.Name]++
+		sportNameCounts[[2]string{activity.SportType, activity.Name}]++
+	}
+
+	rules := nameMappings.Rules()
+	var unused []strava.NameMapping
+	for _, rule := range rules {
+		if rule.SportType == "" && nameCounts[rule.From] == 0 ||
+			rule.SportType != "" && sportNameCounts[[2]string{rule.SportType, rule.From}] == 0 {
+			unused = append(unused, rule)
+		}
+	}
+	if len(unused) > 0 {
+		cli.Infof("\nRules that matched no activities:")
+		for _, rule := range unused {
+			cli.Infof("  %s", describeRule(rule))
+		}
+	}
+
+	// Names that are already a rule's source or target are fine as they are
+	known := make(map[string]bool)
+	for _, rule := range rules {
+		known[rule.From] = true
+		known[rule.To] = true
+	}
+
+	var names []string
+	for name := range nameCounts {
+		if !known[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var nearMisses []string
+	for _, name := range names {
+		for _, rule := range rules {
+			if strava.EditDistance(name, rule.From) == 1 {
+				nearMisses = append(nearMisses, fmt.Sprintf("  '%s' (%d activities) is close to rule %s", name, nameCounts[name], describeRule(rule)))
+			}
+		}
+	}
+	if len(nearMisses) > 0 {
+		log.Printf("\nWarning: names that almost match a rule:")
+		for _, line := range nearMisses {
+			log.Printf("%s", line)
+		}
+	}
+}
+
+func describeRule(rule strava.NameMapping) string {
+	if rule.SportType != "" {
+		return fmt.Sprintf("%s '%s' -> '%s'", rule.SportType, rule.From, rule.To)
+	}
+	return fmt.Sprintf("'%s' -> '%s'", rule.From, rule.To)
 }
@@ -0,0 +1,471 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+// reports lists the available reports in the order they're shown in usage.
+var reports = []struct {
+	name        string
+	description string
+}{
+	{"engagement", "Top activities by kudos and comments"},
+	{"duplicates", "Probable duplicate recordings of the same workout"},
+	{"gear", "Distance per shoe and bike, flagging gear due for retirement"},
+	{"performance", "Pace or speed, heart rate and power per activity"},
+	{"zones", "Activities per heart rate zone, by average heart rate"},
+	{"naming", "A naming consistency score, with suggestions for cleaning up"},
+	{"commutes", "Commutes per month, with distance and an estimate of the CO2 saved"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <report> [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Reports:\n")
+	for _, r := range reports {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", r.name, r.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func isReport(name string) bool {
+	for _, r := range reports {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	logFlags := cli.RegisterLogFlags()
+	topPtr := flag.Int("top", 20, "Maximum number of rows to show (0 for all)")
+	windowPtr := flag.Duration("window", 5*time.Minute, "duplicates: maximum difference in start time between two recordings")
+	outputPtr := cli.RegisterOutputFlag()
+	retireKmPtr := flag.Float64("retire-km", 800, "gear: flag gear with more than this many kilometers as due for retirement")
+	matchPtr := flag.String("match", "sport_type", "duplicates: comma-separated fields that must be equal (sport_type, name)")
+	unitsPtr := flag.String("units", "metric", `performance: "metric" or "imperial"`)
+	maxDistancePtr := flag.Int("max-distance", 2, "naming: maximum number of edits between names counted as near-duplicates")
+	co2PerKmPtr := flag.Float64("co2-per-km", 170, "commutes: grams of CO2 a car would emit per km, for the estimate of what commuting saved")
+	var caseExceptions cli.StringList
+	flag.Var(&caseExceptions, "case-exception", "naming: word whose casing is intentional, e.g. HIIT (repeatable)")
+	filterFlags := cli.RegisterFilterFlags()
+	flag.Usage = usage
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	report := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+
+	// Set up logging
+	logFlags.Configure()
+
+	if !isReport(report) {
+		fmt.Fprintf(os.Stderr, "Unknown report %q\n\n", report)
+		usage()
+		os.Exit(2)
+	}
+
+	filters, err := filterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := filterFlags.FetchActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+	activities = strava.FilterActivities(activities, filters...)
+
+	out, err := cli.CreateOutput(*outputPtr)
+	if err != nil {
+		log.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Discard()
+
+	switch report {
+	case "engagement":
+		printEngagement(out, activities, *topPtr)
+	case "duplicates":
+		fields, err := parseMatchFields(*matchPtr)
+		if err != nil {
+			log.Fatalf("Invalid -match: %v", err)
+		}
+		printDuplicates(out, activities, *windowPtr, fields)
+	case "gear":
+		printGear(out, config.AccessToken, activities, *retireKmPtr)
+	case "performance":
+		units, err := strava.ParseUnits(*unitsPtr)
+		if err != nil {
+			log.Fatalf("Invalid -units: %v", err)
+		}
+		printPerformance(out, activities, units, *topPtr)
+	case "zones":
+		zones, err := strava.GetHeartRateZones(config.AccessToken)
+		if strava.IsMissingScope(err) {
+			log.Fatalf("Failed to get heart rate zones: %v. Re-authorize with the profile:read_all scope", err)
+		}
+		if err != nil {
+			log.Fatalf("Failed to get heart rate zones: %v", err)
+		}
+		if len(zones.Zones) == 0 {
+			log.Fatalf("No heart rate zones are configured on this account. Set them up under Settings > My Performance on strava.com")
+		}
+		printZones(out, activities, *zones)
+	case "naming":
+		printNaming(out, strava.ScoreNaming(activities, *maxDistancePtr, caseExceptions))
+	case "commutes":
+		if *co2PerKmPtr < 0 {
+			log.Fatalf("Invalid -co2-per-km %g, expected a non-negative number of grams", *co2PerKmPtr)
+		}
+		printCommutes(out, strava.FilterActivities(activities, strava.ByCommute(true)), *co2PerKmPtr)
+	}
+
+	if err := out.Commit(); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
+
+func printEngagement(w io.Writer, activities []strava.Activity, top int) {
+	// Sort by kudos (descending), breaking ties by comments
+	sorted := append([]strava.Activity(nil), activities...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].KudosCount != sorted[j].KudosCount {
+			return sorted[i].KudosCount > sorted[j].KudosCount
+		}
+		return sorted[i].CommentCount > sorted[j].CommentCount
+	})
+
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+
+	fmt.Fprintf(w, "\nTop Activities by Kudos:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "%-40s %-10s %6s %8s\n", "Name", "Date", "Kudos", "Comments")
+	for _, activity := range sorted {
+		fmt.Fprintf(w, "%-40s %-10s %6d %8d\n", activity.Name,
+			activity.StartDateLocal.Format("2006-01-02"), activity.KudosCount, activity.CommentCount)
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Total activities: %d\n", len(activities))
+}
+
+// matchFields maps the -match field names to equality checks.
+var matchFields = map[string]func(a, b strava.Activity) bool{
+	"sport_type": func(a, b strava.Activity) bool { return a.SportType == b.SportType },
+	"name":       func(a, b strava.Activity) bool { return a.Name == b.Name },
+}
+
+func parseMatchFields(value string) ([]func(a, b strava.Activity) bool, error) {
+	var fields []func(a, b strava.Activity) bool
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		field, ok := matchFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func printDuplicates(w io.Writer, activities []strava.Activity, window time.Duration, fields []func(a, b strava.Activity) bool) {
+	// Sort by start time so candidates are always close together
+	sorted := append([]strava.Activity(nil), activities...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartDate.Before(sorted[j].StartDate)
+	})
+
+	fmt.Fprintf(w, "\nProbable Duplicates (within %s):\n", window)
+	fmt.Fprintf(w, "--------------------\n")
+	pairs := 0
+	for i := range sorted {
+		for j := i + 1; j < len(sorted); j++ {
+			if sorted[j].StartDate.Sub(sorted[i].StartDate) > window {
+				break
+			}
+			if !fieldsMatch(sorted[i], sorted[j], fields) {
+				continue
+			}
+
+			pairs++
+			for _, activity := range []strava.Activity{sorted[i], sorted[j]} {
+				fmt.Fprintf(w, "  %-12d %-20s %s\n", activity.ID,
+					activity.StartDateLocal.Format("2006-01-02 15:04:05"), activity.Name)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Candidate pairs: %d\n", pairs)
+}
+
+func fieldsMatch(a, b strava.Activity, fields []func(a, b strava.Activity) bool) bool {
+	for _, field := range fields {
+		if !field(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// gearTotal is the distance and activity count recorded with one piece of
+// gear.
+type gearTotal struct {
+	id       string
+	name     string
+	distance float64 // meters
+	count    int
+}
+
+func printGear(w io.Writer, accessToken string, activities []strava.Activity, retireKm float64) {
+	totals := make(map[string]*gearTotal)
+	for _, activity := range activities {
+		if activity.GearID == "" {
+			continue
+		}
+		total, ok := totals[activity.GearID]
+		if !ok {
+			total = &gearTotal{id: activity.GearID, name: activity.GearID}
+			totals[activity.GearID] = total
+		}
+		total.distance += activity.Distance
+		total.count++
+	}
+
+	// Activities only carry the gear ID, so look up each name once
+	var sorted []*gearTotal
+	for _, total := range totals {
+		gear, err := strava.GetGear(accessToken, total.id)
+		if err != nil {
+			log.Printf("Warning: failed to get gear %s: %v", total.id, err)
+		} else {
+			total.name = gear.Name
+		}
+		sorted = append(sorted, total)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].distance > sorted[j].distance
+	})
+
+	fmt.Fprintf(w, "\nGear Mileage:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "%-40s %10s %10s\n", "Gear", "Distance", "Activities")
+	due := 0
+	for _, total := range sorted {
+		km := total.distance / 1000
+		note := ""
+		if km > retireKm {
+			note = "  consider retiring"
+			due++
+		}
+		fmt.Fprintf(w, "%-40s %7.0f km %10d%s\n", total.name, km, total.count, note)
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Gear over %.0f km: %d\n", retireKm, due)
+}
+
+func printPerformance(w io.Writer, activities []strava.Activity, units strava.Units, top int) {
+	// Most recent first
+	sorted := append([]strava.Activity(nil), activities...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartDate.After(sorted[j].StartDate)
+	})
+
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+
+	// Zero means the activity has no such data
+	orDash := func(value float64, format string) string {
+		if value == 0 {
+			return "-"
+		}
+		return fmt.Sprintf(format, value)
+	}
+
+	fmt.Fprintf(w, "\nPerformance:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "%-10s %-30s %-16s %10s %14s %6s %6s %6s\n",
+		"Date", "Name", "Sport", "Distance", "Pace/Speed", "Avg HR", "Max HR", "Avg W")
+	for _, activity := range sorted {
+		fmt.Fprintf(w, "%-10s %-30s %-16s %10s %14s %6s %6s %6s\n",
+			activity.StartDateLocal.Format("2006-01-02"), activity.Name, activity.SportType,
+			units.FormatDistance(activity.Distance),
+			units.FormatSpeed(activity.SportType, activity.AverageSpeed),
+			orDash(activity.AverageHeartrate, "%.0f"),
+			orDash(activity.MaxHeartrate, "%.0f"),
+			orDash(activity.AverageWatts, "%.0f"))
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Total activities: %d\n", len(activities))
+}
+
+// printZones buckets activities by the zone of their average heart rate.
+// That's only a rough measure of intensity, since the time spent in each
+// zone isn't part of the activity list.
+func printZones(w io.Writer, activities []strava.Activity, zones strava.HeartRateZones) {
+	counts := make([]int, len(zones.Zones)+1) // counts[0] is below zone 1
+	withHeartrate := 0
+	for _, activity := range activities {
+		if activity.AverageHeartrate == 0 {
+			continue
+		}
+		counts[zones.Zone(activity.AverageHeartrate)]++
+		withHeartrate++
+	}
+
+	fmt.Fprintf(w, "\nActivities by Heart Rate Zone:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	for i, zone := range zones.Zones {
+		bounds := fmt.Sprintf("%d-%d bpm", zone.Min, zone.Max)
+		if zone.Max < 0 {
+			bounds = fmt.Sprintf("%d+ bpm", zone.Min)
+		}
+		share := 0.0
+		if withHeartrate > 0 {
+			share = float64(counts[i+1]) / float64(withHeartrate) * 100
+		}
+		fmt.Fprintf(w, "Zone %d %-12s %6d %5.1f%% %s\n", i+1, bounds, counts[i+1], share,
+			strings.Repeat("#", int(share/2)))
+	}
+	if counts[0] > 0 {
+		fmt.Fprintf(w, "Below zone 1         %6d\n", counts[0])
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Activities with heart rate: %d of %d\n", withHeartrate, len(activities))
+}
+
+func printNaming(w io.Writer, score strava.NamingScore) {
+	percent := func(count int) float64 {
+		if score.Total == 0 {
+			return 0
+		}
+		return 100 * float64(count) / float64(score.Total)
+	}
+
+	fmt.Fprintf(w, "\nNaming Consistency:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "%-40s %6s %7s\n", "Problem", "Count", "Share")
+	rows := []struct {
+		label string
+		count int
+	}{
+		{"Leading or trailing whitespace", score.Whitespace},
+		{"Unicode issues (mojibake, quotes, ...)", score.Unicode},
+		{"Empty or placeholder names", score.Placeholders},
+		{"Spelled with different case", score.CaseVariants},
+		{"Near-duplicates of a more used name", score.Variants},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%-40s %6d %6.1f%%\n", row.label, row.count, percent(row.count))
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Score: %.0f/100 (%d of %d activities have a clean name)\n",
+		score.Score(), score.Total-score.Messy, score.Total)
+
+	var suggestions []string
+	if score.Whitespace > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("Run strava-activity-cleaner.go to trim %d names", score.Whitespace))
+	}
+	if score.Unicode > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("Run strava-activity-cleaner.go -unicode=all to fix %d names", score.Unicode))
+	}
+	if score.Placeholders > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("Run strava-activity-cleaner.go -rename-placeholders to name %d activities", score.Placeholders))
+	}
+	if len(score.CaseGroups) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("Run strava-activity-cleaner.go -normalize-case to merge %d names that differ only by case", len(score.CaseGroups)))
+	}
+	if len(score.Clusters) > 0 {
+		mappings := 0
+		for _, cluster := range score.Clusters {
+			mappings += len(cluster.Variants())
+		}
+		suggestions = append(suggestions, fmt.Sprintf("Run strava-activity-suggest-mappings.go and review its %d mappings for %d clusters of similar names", mappings, len(score.Clusters)))
+	}
+
+	if len(suggestions) > 0 {
+		fmt.Fprintf(w, "\nSuggestions:\n")
+		for _, suggestion := range suggestions {
+			fmt.Fprintf(w, "  - %s\n", suggestion)
+		}
+	}
+}
+
+// commuteMonth is the commuting done in one month.
+type commuteMonth struct {
+	month    string // e.g. "2024-03"
+	count    int
+	distance float64 // meters
+}
+
+// printCommutes totals commutes per month. The CO2 saved is only an
+// estimate: the distance times co2PerKm, as if each commute had been made
+// alone by a car emitting that much.
+func printCommutes(w io.Writer, commutes []strava.Activity, co2PerKm float64) {
+	months := make(map[string]*commuteMonth)
+	for _, activity := range commutes {
+		key := activity.StartDateLocal.Format("2006-01")
+		month, ok := months[key]
+		if !ok {
+			month = &commuteMonth{month: key}
+			months[key] = month
+		}
+		month.count++
+		month.distance += activity.Distance
+	}
+
+	var sorted []*commuteMonth
+	for _, month := range months {
+		sorted = append(sorted, month)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].month < sorted[j].month
+	})
+
+	kgCO2 := func(meters float64) float64 {
+		return meters / 1000 * co2PerKm / 1000
+	}
+
+	fmt.Fprintf(w, "\nCommutes by Month:\n")
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "%-8s %9s %12s %12s\n", "Month", "Commutes", "Distance", "CO2 saved")
+	total := commuteMonth{}
+	for _, month := range sorted {
+		fmt.Fprintf(w, "%-8s %9d %9.1f km %9.1f kg\n", month.month, month.count,
+			month.distance/1000, kgCO2(month.distance))
+		total.count += month.count
+		total.distance += month.distance
+	}
+	fmt.Fprintf(w, "--------------------\n")
+	fmt.Fprintf(w, "Total: %d commutes, %.1f km, about %.1f kg of CO2 saved\n",
+		total.count, total.distance/1000, kgCO2(total.distance))
+	fmt.Fprintf(w, "(CO2 is an estimate at %g g per km driven; set -co2-per-km for your car)\n", co2PerKm)
+}
@@ -0,0 +1,206 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	rulesFilePtr := flag.String("rules", "rules.json", "Path to the rules file")
+	execPtr := flag.String("exec", "", "Also run this command for each activity: it gets the activity as JSON on stdin and prints an update as JSON (or nothing) on stdout")
+	execTimeoutPtr := flag.Duration("exec-timeout", 10*time.Second, "Timeout for each -exec command")
+	execConcurrencyPtr := flag.Int("exec-concurrency", 4, "Number of -exec commands to run at once")
+	detailPtr := flag.Bool("detail", false, "List every activity that would change, not just how many each rule changes")
+	descriptionModePtr := cli.RegisterDescriptionModeFlag(strava.DescriptionReplace)
+	legacyTypePtr := flag.Bool("legacy-type", false, "Also set the legacy type field when a rule changes the sport type")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	incrementalFlags := cli.RegisterIncrementalFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	nameFilters, err := nameFilterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	// With -exec the rules file is optional, unless given explicitly
+	rulesSet := false
+	flag.Visit(func(f *flag.Flag) {
+		rulesSet = rulesSet || f.Name == "rules"
+	})
+	var rules []strava.Rule
+	if *execPtr == "" || rulesSet {
+		rules, err = strava.LoadRules(*rulesFilePtr)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+	}
+
+	var command *strava.CommandRule
+	if *execPtr != "" {
+		command = &strava.CommandRule{Command: strings.Fields(*execPtr), Timeout: *execTimeoutPtr}
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, incrementalFlags)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	// Run every rule over each activity, then the command, combining the
+	// changes into one update per activity
+	var allResults []strava.RuleResult
+	commandFailed := false
+	for _, activity := range strava.FilterActivities(activities, nameFilters...) {
+		allResults = append(allResults, strava.ApplyRules(activity, rules))
+	}
+	if command != nil {
+		cli.Infof("Running %s for %d activities...", command.Command[0], len(allResults))
+		var errs []error
+		allResults, errs = strava.ApplyCommandRule(*command, allResults, *execConcurrencyPtr)
+		for i, err := range errs {
+			if err != nil {
+				log.Printf("Warning: Skipping activity ID %d: %v", allResults[i].Activity.ID, err)
+				allResults[i].Update = strava.ActivityUpdate{}
+				commandFailed = true
+			}
+		}
+	}
+
+	var results []strava.RuleResult
+	for _, result := range allResults {
+		if result.Update.Description != "" && *descriptionModePtr != strava.DescriptionReplace {
+			// The activity list doesn't include descriptions, so fetch the
+			// one the command's text is combined with
+			activity, err := strava.GetActivityByID(config.AccessToken, result.Activity.ID)
+			if err != nil {
+				log.Fatalf("Failed to get activity ID %d: %v", result.Activity.ID, err)
+			}
+			result.Activity = *activity
+		}
+		result.Update = result.Update.WithDescriptionMode(result.Activity, *descriptionModePtr)
+		if result.Update.SportType != "" && *legacyTypePtr {
+			result.Update.Type = strava.LegacyType(result.Update.SportType)
+		}
+		if result.Update.IsNoop(result.Activity) {
+			continue
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		cli.Infof("No activities found that need changes")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		} else if !commandFailed {
+			incrementalFlags.Advance(activities)
+		}
+		return
+	}
+
+	// Print what would be changed, grouped by the rule that changed it
+	cli.Infof("Found %d activities that need changes:", len(results))
+	var labels []string
+	descriptions := make(map[string]string)
+	for i := range rules {
+		label := rules[i].Label(i)
+		labels = append(labels, label)
+		descriptions[label] = rules[i].Description()
+	}
+	if command != nil {
+		labels = append(labels, "command")
+		descriptions["command"] = "Changed by " + command.Command[0]
+	}
+	fired := make(map[string]int)
+	for _, result := range results {
+		for _, label := range result.Fired {
+			fired[label]++
+		}
+	}
+	for _, label := range labels {
+		if fired[label] > 0 {
+			cli.Infof("  %s: %d (%s)", descriptions[label], fired[label], label)
+		}
+	}
+
+	for _, result := range results {
+		activity, update := result.Activity, result.Update
+		changeReport.Add(activity, update)
+		if !*detailPtr {
+			continue
+		}
+		cli.Infof("  ID: %d", activity.ID)
+		if update.Name != "" {
+			cli.Infof("    Name:       '%s' -> '%s'", activity.Name, update.Name)
+		}
+		if update.SportType != "" {
+			cli.Infof("    Sport type: %s -> %s", activity.SportType, update.SportType)
+		}
+		if update.Type != "" && update.Type != activity.Type {
+			cli.Infof("    Type:       %s -> %s", activity.Type, update.Type)
+		}
+		if update.Description != "" && update.Description != activity.Description {
+			cli.Infof("    Description: '%s' -> '%s'", activity.Description, update.Description)
+		}
+		cli.Infof("    Rules:      %s", strings.Join(result.Fired, ", "))
+	}
+	if !*detailPtr {
+		cli.Infof("An activity changed by several rules is counted for each. Run with -detail to list every activity")
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(results)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(results), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, result := range results {
+		updated, err := batch.UpdateWithRules(config.AccessToken, result.Activity, result.Update, result.Fired)
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", result.Activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d", result.Activity.ID)
+	}
+
+	if batch.Complete() && !commandFailed {
+		incrementalFlags.Advance(activities)
+	}
+}
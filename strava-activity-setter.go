@@ -0,0 +1,190 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	filterFlags := cli.RegisterFilterFlags()
+	privateNotePtr := flag.String("private-note", "", "Set the private note; may be a Go template over the activity, e.g. '{{.Name}} on {{.StartDateLocal.Format \"Jan 2\"}}' (empty clears it)")
+	hideFromHomePtr := flag.Bool("hide-from-home", false, "Mute activities from followers' home feeds (-hide-from-home=false unmutes them)")
+	workoutTypePtr := flag.String("workout-type", "", `Set the workout type of runs and rides: "race", "long-run" (runs only), "workout" or "default"`)
+	perceivedExertionPtr := flag.Int("perceived-exertion", 0, "Set the perceived exertion, from 1 (easy) to 10 (max effort)")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	// Only fields given on the command line are changed, so an empty value
+	// can clear a field
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	var privateNote *template.Template
+	if setFlags["private-note"] {
+		var err error
+		privateNote, err = template.New("private-note").Option("missingkey=error").Parse(*privateNotePtr)
+		if err != nil {
+			log.Fatalf("Invalid -private-note template: %v", err)
+		}
+	}
+	var hideFromHome *bool
+	if setFlags["hide-from-home"] {
+		hideFromHome = hideFromHomePtr
+	}
+	var perceivedExertion *int
+	if setFlags["perceived-exertion"] {
+		perceivedExertion = perceivedExertionPtr
+		if err := (strava.ActivityUpdate{PerceivedExertion: perceivedExertion}).Validate(); err != nil {
+			log.Fatalf("Invalid -perceived-exertion: %v", err)
+		}
+	}
+	if privateNote == nil && hideFromHome == nil && *workoutTypePtr == "" && perceivedExertion == nil {
+		log.Fatalf("Nothing to set. Please specify a field to change, e.g. -private-note, -hide-from-home, -workout-type or -perceived-exertion")
+	}
+
+	filters, err := filterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+	if len(filters) == 0 {
+		log.Fatalf("No filter provided. Please narrow the activities down with -name, -name-contains, -name-regex, -sport-type, -exclude-sport, -visibility, -after, -before, -since, -photos or -ids")
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := filterFlags.FetchActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	candidates := strava.FilterActivities(activities, filters...)
+
+	// Fields like the private note are only in the detailed representation,
+	// so fetch each candidate to compare against its current values
+	cli.Infof("Checking %d matching activities...", len(candidates))
+	var activitiesToUpdate []strava.Activity
+	updates := make(map[int64]strava.ActivityUpdate)
+	for _, candidate := range candidates {
+		activity, err := strava.GetActivityByID(config.AccessToken, candidate.ID)
+		if err != nil {
+			log.Fatalf("Failed to get activity ID %d: %v", candidate.ID, err)
+		}
+
+		var update strava.ActivityUpdate
+		if privateNote != nil {
+			var note strings.Builder
+			if err := privateNote.Execute(&note, activity); err != nil {
+				log.Fatalf("Failed to render -private-note for activity ID %d: %v", activity.ID, err)
+			}
+			value := note.String()
+			update.PrivateNote = &value
+		}
+		update.HideFromHome = hideFromHome
+		update.PerceivedExertion = perceivedExertion
+		if *workoutTypePtr != "" {
+			// The value depends on the sport, so resolve it per activity
+			workoutType, err := strava.WorkoutType(activity.SportType, *workoutTypePtr)
+			if err != nil {
+				log.Printf("Warning: Skipping activity ID %d: %v", activity.ID, err)
+				continue
+			}
+			update.WorkoutType = &workoutType
+		}
+
+		if update.IsNoop(*activity) {
+			continue
+		}
+		activitiesToUpdate = append(activitiesToUpdate, *activity)
+		updates[activity.ID] = update
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found that need changes")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities that need changes:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		update := updates[activity.ID]
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		changeReport.Add(activity, update)
+		if update.PrivateNote != nil {
+			cli.Infof("    Private note: '%s' -> '%s'", activity.PrivateNote, *update.PrivateNote)
+		}
+		if update.HideFromHome != nil {
+			cli.Infof("    Hide from home: %t -> %t", activity.HideFromHome, *update.HideFromHome)
+		}
+		if update.WorkoutType != nil {
+			from := "none"
+			if activity.WorkoutType != nil {
+				from = strava.WorkoutTypeName(activity.SportType, *activity.WorkoutType)
+			}
+			cli.Infof("    Workout type: %s -> %s", from, strava.WorkoutTypeName(activity.SportType, *update.WorkoutType))
+		}
+		if update.PerceivedExertion != nil {
+			from := "none"
+			if activity.PerceivedExertion != nil {
+				from = strconv.FormatFloat(*activity.PerceivedExertion, 'f', -1, 64)
+			}
+			cli.Infof("    Perceived exertion: %s -> %d", from, *update.PerceivedExertion)
+		}
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		updated, err := batch.Update(config.AccessToken, activity, updates[activity.ID])
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d", activity.ID)
+	}
+}
@@ -0,0 +1,119 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+// displayLayout formats wall-clock start times in log output.
+const displayLayout = "2006-01-02 15:04:05"
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	filterFlags := cli.RegisterFilterFlags()
+	offsetPtr := flag.Duration("offset", 0, "Amount to shift start times by, e.g. 1h or -30m")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	if *offsetPtr == 0 {
+		log.Fatalf("No offset provided. Please specify one with the -offset flag, e.g. -offset=1h")
+	}
+
+	filters, err := filterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+	if len(filters) == 0 {
+		log.Fatalf("No filter provided. Please narrow the activities down with -name, -name-contains, -name-regex, -sport-type, -exclude-sport, -visibility, -after, -before, -since, -photos or -ids")
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := filterFlags.FetchActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	activitiesToUpdate := strava.FilterActivities(activities, filters...)
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found matching the filter")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed, refusing to move anything into the future
+	now := time.Now()
+	inFuture := 0
+	cli.Infof("Found %d activities to shift by %s:", len(activitiesToUpdate), *offsetPtr)
+	for _, activity := range activitiesToUpdate {
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		cli.Infof("    From: %s", activity.StartDateLocal.Format(displayLayout))
+		cli.Infof("    To:   %s", activity.StartDateLocal.Add(*offsetPtr).Format(displayLayout))
+		changeReport.Add(activity, strava.ActivityUpdate{StartDateLocal: activity.StartDateLocal.Add(*offsetPtr)})
+		if activity.StartDate.Add(*offsetPtr).After(now) {
+			log.Printf("    Error: new start time of activity ID %d is in the future", activity.ID)
+			inFuture++
+		}
+	}
+
+	if inFuture > 0 {
+		log.Fatalf("%d activities would start in the future. Use a smaller offset or narrow the filter", inFuture)
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		newStart := activity.StartDateLocal.Add(*offsetPtr)
+		update := strava.ActivityUpdate{
+			StartDateLocal: newStart,
+		}
+
+		updated, err := batch.Update(config.AccessToken, activity, update)
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d: %s -> %s", activity.ID,
+			activity.StartDateLocal.Format(displayLayout), newStart.Format(displayLayout))
+	}
+}
@@ -0,0 +1,82 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	inputPtr := flag.String("input", "", "Activities exported with strava-activity-exporter.go export-json")
+	var passes cli.StringList
+	flag.Var(&passes, "mappings", "Name mappings file to apply as a pass; passes run in the order given (repeatable)")
+	trimPtr := flag.Bool("trim", false, "Trim whitespace from names before the mapping passes, like the cleaner")
+	allPtr := flag.Bool("all", false, "List every activity, not only those whose name changes")
+	flag.Parse()
+
+	// Set up logging
+	log.SetOutput(os.Stdout)
+	log.SetFlags(0)
+
+	if *inputPtr == "" {
+		log.Fatalf("No input provided. Please export your activities and pass them with -input")
+	}
+
+	file, err := os.Open(*inputPtr)
+	if err != nil {
+		log.Fatalf("Failed to open input: %v", err)
+	}
+	activities, err := strava.ReadActivitiesJSON(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to read activities: %v", err)
+	}
+
+	var renamePasses []strava.RenamePass
+	if *trimPtr {
+		renamePasses = append(renamePasses, strava.TrimPass())
+	}
+	for _, filename := range passes {
+		mappings, err := strava.LoadNameMappings(filename)
+		if err != nil {
+			log.Fatalf("Failed to load name mappings %s: %v", filename, err)
+		}
+		renamePasses = append(renamePasses, strava.MappingPass(filename, mappings))
+	}
+	if len(renamePasses) == 0 {
+		log.Fatalf("No passes to simulate. Please specify -mappings and/or -trim")
+	}
+
+	// Print the final state of each activity and the rules that got it there
+	results := strava.SimulateRenames(activities, renamePasses)
+	changed := 0
+	ruleCounts := make(map[string]int)
+	for _, result := range results {
+		if result.Changed() {
+			changed++
+		}
+		for _, rule := range result.Fired {
+			ruleCounts[rule]++
+		}
+		if !result.Changed() && !*allPtr {
+			continue
+		}
+
+		log.Printf("ID: %d", result.Activity.ID)
+		log.Printf("  From: '%s'", result.Activity.Name)
+		log.Printf("  To:   '%s'", result.FinalName)
+		for _, rule := range result.Fired {
+			log.Printf("  Rule: %s", rule)
+		}
+	}
+
+	log.Printf("\n%d of %d activities would be renamed by %d distinct rules", changed, len(activities), len(ruleCounts))
+	log.Printf("This was a simulation. Nothing was sent to Strava")
+}
@@ -0,0 +1,227 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+// sportRule proposes SportType for activities whose name contains one of
+// Keywords (when given) and whose average speed is within the bounds (when
+// given). Rules are tried in order and the first match wins.
+type sportRule struct {
+	Keywords    []string `json:"keywords,omitempty"`
+	MinSpeedKmh float64  `json:"min_speed_kmh,omitempty"`
+	MaxSpeedKmh float64  `json:"max_speed_kmh,omitempty"`
+	SportType   string   `json:"sport_type"`
+}
+
+// defaultSportRules are used when no -rules file is given. Keyword rules
+// come first; the speed-only rules catch unnamed GPS activities.
+var defaultSportRules = []sportRule{
+	{Keywords: []string{"run", "jog", "5k", "10k"}, SportType: "Run"},
+	{Keywords: []string{"ride", "bike", "cycling", "cycle"}, SportType: "Ride"},
+	{Keywords: []string{"swim", "swimming"}, SportType: "Swim"},
+	{Keywords: []string{"walk"}, SportType: "Walk"},
+	{Keywords: []string{"hike", "hiking"}, SportType: "Hike"},
+	{Keywords: []string{"yoga"}, SportType: "Yoga"},
+	{Keywords: []string{"row", "rowing"}, SportType: "Rowing"},
+	{MinSpeedKmh: 15, MaxSpeedKmh: 60, SportType: "Ride"},
+	{MinSpeedKmh: 7, MaxSpeedKmh: 15, SportType: "Run"},
+	{MinSpeedKmh: 3, MaxSpeedKmh: 7, SportType: "Walk"},
+}
+
+// match reports whether the rule applies to activity, along with the
+// evidence for the dry-run output.
+func (r sportRule) match(activity strava.Activity) (evidence string, ok bool) {
+	var reasons []string
+
+	if len(r.Keywords) > 0 {
+		keyword := findKeyword(activity.Name, r.Keywords)
+		if keyword == "" {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("name contains %q", keyword))
+	}
+
+	if r.MinSpeedKmh > 0 || r.MaxSpeedKmh > 0 {
+		if activity.Distance <= 0 || activity.MovingTime <= 0 {
+			return "", false
+		}
+		speed := activity.Distance / float64(activity.MovingTime) * 3.6
+		if speed < r.MinSpeedKmh || (r.MaxSpeedKmh > 0 && speed > r.MaxSpeedKmh) {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("average speed %.1f km/h", speed))
+	}
+
+	if len(reasons) == 0 {
+		return "", false
+	}
+	return strings.Join(reasons, ", "), true
+}
+
+// findKeyword returns the first keyword that appears as a whole word in
+// name, ignoring case.
+func findKeyword(name string, keywords []string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, keyword := range keywords {
+		for _, word := range words {
+			if word == strings.ToLower(keyword) {
+				return keyword
+			}
+		}
+	}
+	return ""
+}
+
+func loadSportRules(filename string) ([]sportRule, error) {
+	if filename == "" {
+		return defaultSportRules, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []sportRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	rulesFilePtr := flag.String("rules", "", "Path to a JSON file of sport type rules (default: built-in keyword and speed rules)")
+	fromPtr := flag.String("from", "Workout", "Only correct activities with this sport type")
+	legacyTypePtr := flag.Bool("legacy-type", false, "Also set the legacy type field to match the new sport type")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	nameFilters, err := nameFilterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	rules, err := loadSportRules(*rulesFilePtr)
+	if err != nil {
+		log.Fatalf("Failed to load sport type rules: %v", err)
+	}
+	for i, rule := range rules {
+		if !strava.IsValidSportType(rule.SportType) {
+			log.Fatalf("Rule %d proposes unknown sport type %q", i+1, rule.SportType)
+		}
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, nil)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	// Find activities a rule can re-type
+	var activitiesToUpdate []strava.Activity
+	proposed := make(map[int64]string)
+	evidence := make(map[int64]string)
+	for _, activity := range strava.FilterActivities(activities, append(nameFilters, strava.BySportType(*fromPtr))...) {
+		for _, rule := range rules {
+			why, ok := rule.match(activity)
+			if !ok {
+				continue
+			}
+			if rule.SportType != activity.SportType {
+				activitiesToUpdate = append(activitiesToUpdate, activity)
+				proposed[activity.ID] = rule.SportType
+				evidence[activity.ID] = why
+			}
+			break
+		}
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No %s activities found with a likely sport type", *fromPtr)
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities with a likely sport type:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		cli.Infof("    Sport type: %s -> %s (%s)", activity.SportType, proposed[activity.ID], evidence[activity.ID])
+		changeReport.Add(activity, sportTypeUpdate(proposed[activity.ID], *legacyTypePtr))
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		update := sportTypeUpdate(proposed[activity.ID], *legacyTypePtr)
+
+		updated, err := batch.Update(config.AccessToken, activity, update)
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d: %s -> %s",
+			activity.ID, activity.SportType, update.SportType)
+	}
+}
+
+// sportTypeUpdate builds the update setting sportType and, if legacyType is
+// set, the matching legacy type.
+func sportTypeUpdate(sportType string, legacyType bool) strava.ActivityUpdate {
+	update := strava.ActivityUpdate{SportType: sportType}
+	if legacyType {
+		update.Type = strava.LegacyType(sportType)
+	}
+	return update
+}
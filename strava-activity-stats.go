@@ -0,0 +1,62 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	logFlags := cli.RegisterLogFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// The stats endpoint needs the athlete ID
+	athlete, err := strava.GetAthlete(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get athlete: %v", err)
+	}
+
+	stats, err := strava.GetAthleteStats(config.AccessToken, athlete.ID)
+	if err != nil {
+		log.Fatalf("Failed to get athlete stats: %v", err)
+	}
+
+	fmt.Printf("\nStats for %s %s:\n", athlete.Firstname, athlete.Lastname)
+	for _, period := range []struct {
+		name            string
+		ride, run, swim strava.ActivityTotal
+	}{
+		{"Last 4 Weeks", stats.RecentRideTotals, stats.RecentRunTotals, stats.RecentSwimTotals},
+		{"Year to Date", stats.YTDRideTotals, stats.YTDRunTotals, stats.YTDSwimTotals},
+		{"All Time", stats.AllRideTotals, stats.AllRunTotals, stats.AllSwimTotals},
+	} {
+		fmt.Printf("\n%s:\n", period.name)
+		fmt.Printf("--------------------\n")
+		fmt.Printf("%-6s %8s %12s %12s %12s\n", "", "Count", "Distance", "Time", "Elevation")
+		printTotal("Ride", period.ride)
+		printTotal("Run", period.run)
+		printTotal("Swim", period.swim)
+	}
+}
+
+func printTotal(sport string, total strava.ActivityTotal) {
+	movingTime := time.Duration(total.MovingTime) * time.Second
+	fmt.Printf("%-6s %8d %9.1f km %12s %10.0f m\n", sport, total.Count,
+		total.Distance/1000, movingTime, total.ElevationGain)
+}
@@ -0,0 +1,81 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	maxDistancePtr := flag.Int("max-distance", 2, "Maximum number of edits between names in the same cluster")
+	logFlags := cli.RegisterLogFlags()
+	outputPtr := cli.RegisterOutputFlag()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := strava.GetAllActivities(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	nameCounts := make(map[string]int)
+	for _, activity := range activities {
+		nameCounts[activity.Name]++
+	}
+	clusters := strava.ClusterNames(nameCounts, *maxDistancePtr)
+
+	out, err := cli.CreateOutput(*outputPtr)
+	if err != nil {
+		log.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Discard()
+
+	writeMappings(out, clusters, len(activities))
+	if err := out.Commit(); err != nil {
+		log.Fatalf("Failed to write mappings: %v", err)
+	}
+
+	cli.Infof("Suggested mappings for %d clusters of similar names", len(clusters))
+}
+
+// writeMappings writes a starter mappings file for the renamer, mapping
+// every variant in a cluster to its most used name. A comment above each
+// cluster shows the counts behind the suggestion.
+func writeMappings(w io.Writer, clusters []strava.NameCluster, total int) {
+	fmt.Fprintf(w, "# Suggested name mappings, generated from %d activities.\n", total)
+	fmt.Fprintf(w, "# Each line maps \"current name%snew name\". Review the suggestions, edit\n", strava.MappingSeparator)
+	fmt.Fprintf(w, "# or delete lines, then run: go run strava-activity-renamer.go -mappings=<this file>\n")
+
+	for _, cluster := range clusters {
+		fmt.Fprintf(w, "\n# '%s' (%d activities)\n", cluster.Canonical, cluster.Counts[cluster.Canonical])
+		variants := cluster.Variants()
+		for _, name := range variants {
+			fmt.Fprintf(w, "#   '%s' (%d activities)\n", name, cluster.Counts[name])
+		}
+		for _, name := range variants {
+			if strings.HasPrefix(name, "@") || strings.HasPrefix(name, "#") {
+				// The renamer would read these as a sport scope or a comment
+				fmt.Fprintf(w, "# Can't be mapped, the name starts with %q: '%s'\n", name[:1], name)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s%s\n", name, strava.MappingSeparator, cluster.Canonical)
+		}
+	}
+}
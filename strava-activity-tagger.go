@@ -0,0 +1,203 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+// tagRule adds Tags to the description of every activity matching all of
+// the rule's non-empty conditions.
+type tagRule struct {
+	Name      string   `json:"name,omitempty"`
+	SportType string   `json:"sport_type,omitempty"`
+	Tags      []string `json:"tags"`
+}
+
+func (r tagRule) filters() []strava.Filter {
+	var filters []strava.Filter
+	if r.Name != "" {
+		filters = append(filters, strava.ByName(r.Name))
+	}
+	if r.SportType != "" {
+		filters = append(filters, strava.BySportType(r.SportType))
+	}
+	return filters
+}
+
+func loadTagRules(filename string) ([]tagRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []tagRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	rulesFilePtr := flag.String("rules", "tag_rules.json", "Path to the tag rules file")
+	descriptionModePtr := cli.RegisterDescriptionModeFlag(strava.DescriptionAppend)
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	incrementalFlags := cli.RegisterIncrementalFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	nameFilters, err := nameFilterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	rules, err := loadTagRules(*rulesFilePtr)
+	if err != nil {
+		log.Fatalf("Failed to load tag rules: %v", err)
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, incrementalFlags)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	// Collect the tags each activity should carry
+	wantedTags := make(map[int64][]string)
+	var candidates []strava.Activity
+	for _, activity := range strava.FilterActivities(activities, nameFilters...) {
+		for _, rule := range rules {
+			if !strava.All(rule.filters()...)(activity) {
+				continue
+			}
+			if _, seen := wantedTags[activity.ID]; !seen {
+				candidates = append(candidates, activity)
+			}
+			wantedTags[activity.ID] = append(wantedTags[activity.ID], rule.Tags...)
+		}
+	}
+
+	// The activity list doesn't include descriptions, so fetch each
+	// candidate to see which tags are already present
+	cli.Infof("Checking descriptions of %d matching activities...", len(candidates))
+	var activitiesToUpdate []strava.Activity
+	newDescriptions := make(map[int64]string)
+	addedTags := make(map[int64][]string)
+	for _, candidate := range candidates {
+		activity, err := strava.GetActivityByID(config.AccessToken, candidate.ID)
+		if err != nil {
+			log.Fatalf("Failed to get activity ID %d: %v", candidate.ID, err)
+		}
+
+		missing := missingTags(activity.Description, wantedTags[activity.ID])
+		if len(missing) == 0 {
+			continue
+		}
+
+		activitiesToUpdate = append(activitiesToUpdate, *activity)
+		newDescriptions[activity.ID] = addTags(activity.Description, missing, *descriptionModePtr)
+		addedTags[activity.ID] = missing
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found that are missing tags")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		} else {
+			incrementalFlags.Advance(activities)
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities that are missing tags:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		cli.Infof("  ID: %d '%s'", activity.ID, activity.Name)
+		cli.Infof("    Add: %s", strings.Join(addedTags[activity.ID], " "))
+		changeReport.Add(activity, strava.ActivityUpdate{Description: newDescriptions[activity.ID]})
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		update := strava.ActivityUpdate{
+			Description: newDescriptions[activity.ID],
+		}
+
+		updated, err := batch.Update(config.AccessToken, activity, update)
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully tagged activity ID %d: added %s",
+			activity.ID, strings.Join(addedTags[activity.ID], " "))
+	}
+
+	if batch.Complete() {
+		incrementalFlags.Advance(activities)
+	}
+}
+
+// missingTags returns the tags (without duplicates) that the description
+// doesn't already carry (see strava.HasTag).
+func missingTags(description string, tags []string) []string {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, tag := range tags {
+		key := strings.ToLower(tag)
+		if seen[key] || strava.HasTag(description, tag) {
+			continue
+		}
+		seen[key] = true
+		missing = append(missing, tag)
+	}
+	return missing
+}
+
+// addTags writes the tags to the description in mode, by default on their
+// own line at the end.
+func addTags(description string, tags []string, mode strava.DescriptionMode) string {
+	return strava.ApplyDescription(description, strings.Join(tags, " "), mode)
+}
@@ -0,0 +1,158 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	localePtr := flag.String("locale", "en", "Language of the time-of-day words: "+strings.Join(locales(), ", "))
+	namesPtr := flag.String("names", "", "Comma-separated time-of-day words, one per -starts hour, instead of the -locale ones")
+	startsPtr := flag.String("starts", "4,11,14,18,22", "Comma-separated hours (local time) at which each part of the day starts")
+	addPtr := flag.Bool("add", false, "Also prefix names that have no time-of-day word, e.g. 'Run' -> 'Evening Run'")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	changeReport := cli.RegisterChangeReport()
+	logFlags := cli.RegisterLogFlags()
+	batchFlags := cli.RegisterBatchFlags()
+	nameFilterFlags := cli.RegisterNameFilterFlags()
+	incrementalFlags := cli.RegisterIncrementalFlags()
+	flag.Parse()
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	nameFilters, err := nameFilterFlags.Filters()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	names, ok := strava.DayPeriodNames[*localePtr]
+	if *namesPtr != "" {
+		names, ok = strings.Split(*namesPtr, ","), true
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+	}
+	if !ok {
+		log.Fatalf("Unknown -locale %q, expected one of %s (or pass -names)", *localePtr, strings.Join(locales(), ", "))
+	}
+	var starts []int
+	for _, value := range strings.Split(*startsPtr, ",") {
+		start, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			log.Fatalf("Invalid -starts hour %q", value)
+		}
+		starts = append(starts, start)
+	}
+	periods, err := strava.NewDayPeriods(names, starts)
+	if err != nil {
+		log.Fatalf("Invalid -names or -starts: %v", err)
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	// Get all activities
+	activities, err := nameFilterFlags.FetchActivities(config.AccessToken, incrementalFlags)
+	if err != nil {
+		log.Fatalf("Failed to get activities: %v", err)
+	}
+
+	// Find activities whose time-of-day word doesn't match when they
+	// started. StartDate is UTC, so only the local start time will do.
+	var activitiesToUpdate []strava.Activity
+	newNames := make(map[int64]string)
+	noLocalTime := 0
+	for _, activity := range strava.FilterActivities(activities, nameFilters...) {
+		if activity.StartDateLocal.IsZero() {
+			noLocalTime++
+			continue
+		}
+		if newName := periods.FixName(activity.Name, activity.StartDateLocal, *addPtr); newName != activity.Name {
+			activitiesToUpdate = append(activitiesToUpdate, activity)
+			newNames[activity.ID] = newName
+		}
+	}
+	if noLocalTime > 0 {
+		log.Printf("Warning: Skipped %d activities without a local start time", noLocalTime)
+	}
+
+	if len(activitiesToUpdate) == 0 {
+		cli.Infof("No activities found that need a time-of-day fix")
+		if dryRunFlags.DryRun {
+			changeReport.Finish()
+		} else {
+			incrementalFlags.Advance(activities)
+		}
+		return
+	}
+
+	// Print what would be changed
+	cli.Infof("Found %d activities that need a time-of-day fix:", len(activitiesToUpdate))
+	for _, activity := range activitiesToUpdate {
+		cli.Infof("  ID: %d (started %s local time)", activity.ID, activity.StartDateLocal.Format("2006-01-02 15:04"))
+		cli.Infof("    From: '%s'", activity.Name)
+		cli.Infof("    To:   '%s'", newNames[activity.ID])
+		changeReport.Add(activity, strava.ActivityUpdate{Name: newNames[activity.ID]})
+	}
+
+	if dryRunFlags.DryRun {
+		cli.Infof("\nThis was a dry run. To apply changes, run with -apply")
+		changeReport.Finish()
+		return
+	}
+
+	if !batchFlags.Confirm(len(activitiesToUpdate)) {
+		return
+	}
+	cli.WarnMissingWriteScope(config)
+
+	// Apply changes
+	cli.Infof("\nApplying changes...")
+	batch := cli.NewBatch(len(activitiesToUpdate), batchFlags, logFlags.Verbose)
+	defer batch.Finish()
+	for _, activity := range activitiesToUpdate {
+		newName := newNames[activity.ID]
+		updated, err := batch.Update(config.AccessToken, activity, strava.ActivityUpdate{Name: newName})
+		if err != nil {
+			log.Printf("Failed to update activity ID %d: %v", activity.ID, err)
+			if batch.ShouldStop(err) {
+				break
+			}
+			continue
+		}
+		if !updated {
+			continue
+		}
+
+		batch.LogSuccess("Successfully updated activity ID %d: '%s' -> '%s'",
+			activity.ID, activity.Name, newName)
+	}
+
+	if batch.Complete() {
+		incrementalFlags.Advance(activities)
+	}
+}
+
+// locales returns the supported -locale values, sorted.
+func locales() []string {
+	var locales []string
+	for locale := range strava.DayPeriodNames {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
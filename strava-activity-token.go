@@ -0,0 +1,48 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"strava-activity-updater/cli"
+)
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	expiryPtr := flag.Bool("expiry", false, "Also print the token expiry time")
+	jsonPtr := flag.Bool("json", false, "Print the token and expiry as JSON")
+	flag.Parse()
+
+	// Set up logging; stdout carries only the token so it's pipe-safe
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.Ldate | log.Ltime)
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	if *jsonPtr {
+		output := struct {
+			AccessToken string `json:"access_token"`
+			ExpiresAt   int64  `json:"expires_at"`
+		}{config.AccessToken, config.ExpiresAt}
+
+		if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+			log.Fatalf("Failed to encode token: %v", err)
+		}
+		return
+	}
+
+	fmt.Println(config.AccessToken)
+	if *expiryPtr {
+		fmt.Println(time.Unix(config.ExpiresAt, 0).Format(time.RFC3339))
+	}
+}
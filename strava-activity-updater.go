@@ -7,48 +7,24 @@ package main
 import (
 	"flag"
 	"log"
-	"os"
 
-	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
 	"strava-activity-updater/strava"
 )
 
 func main() {
 	// Parse command line arguments
-	apiKeyPtr := flag.String("api-key", "", "Strava API key")
-	configFilePtr := flag.String("config", "strava_config.json", "Path to config file")
-	verbosePtr := flag.Bool("verbose", false, "Enable verbose logging")
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	logFlags := cli.RegisterLogFlags()
+	legacyTypePtr := flag.Bool("legacy-type", false, "Also set the legacy type field to match the new sport type")
 	flag.Parse()
 
 	// Set up logging
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.Ldate | log.Ltime)
+	logFlags.Configure()
 
-	// Load configuration
-	config, err := auth.LoadConfig(*configFilePtr)
-	if err != nil {
-		log.Printf("Could not load config file, will attempt to create it")
-		config = &auth.StravaConfig{}
-	}
-
-	// Set API key from command line if provided
-	if *apiKeyPtr != "" {
-		config.RefreshToken = *apiKeyPtr
-	}
-
-	if config.RefreshToken == "" {
-		log.Fatalf("No refresh token provided. Please specify either via config file or -api-key flag")
-	}
-
-	// Ensure we have a valid access token
-	if err := auth.EnsureValidToken(config); err != nil {
-		log.Fatalf("Failed to obtain valid token: %v", err)
-	}
-
-	// Save updated config
-	if err := auth.SaveConfig(*configFilePtr, config); err != nil {
-		log.Printf("Warning: Failed to save config: %v", err)
-	}
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
 
 	// Get latest activity
 	activity, err := strava.GetLatestActivity(config.AccessToken)
@@ -56,8 +32,8 @@ func main() {
 		log.Fatalf("Failed to get latest activity: %v", err)
 	}
 
-	if *verbosePtr {
-		log.Printf("Latest activity: ID=%d, Name='%s', Type='%s'",
+	if logFlags.Verbose {
+		cli.Infof("Latest activity: ID=%d, Name='%s', Type='%s'",
 			activity.ID, activity.Name, activity.SportType)
 	}
 
@@ -68,20 +44,24 @@ func main() {
 			Name:      "Pickup Ice Hockey",
 			SportType: "IceSkate",
 		}
+		if *legacyTypePtr {
+			update.Type = strava.LegacyType(update.SportType)
+		}
 
 		// Update the activity
+		cli.WarnMissingWriteScope(config)
 		if err := strava.UpdateActivity(config.AccessToken, activity.ID, update); err != nil {
 			log.Fatalf("Failed to update activity: %v", err)
 		}
 
-		log.Printf("Successfully updated activity ID %d:", activity.ID)
-		log.Printf("  - Changed Name from '%s' to '%s'", activity.Name, update.Name)
-		log.Printf("  - Changed Sport Type from '%s' to '%s'", activity.SportType, update.SportType)
+		cli.Infof("Successfully updated activity ID %d:", activity.ID)
+		cli.Infof("  - Changed Name from '%s' to '%s'", activity.Name, update.Name)
+		cli.Infof("  - Changed Sport Type from '%s' to '%s'", activity.SportType, update.SportType)
 	} else {
-		log.Printf("No update needed for activity ID %d", activity.ID)
-		if *verbosePtr {
-			log.Printf("  Current Name: '%s'", activity.Name)
-			log.Printf("  Current Sport Type: '%s'", activity.SportType)
+		cli.Infof("No update needed for activity ID %d", activity.ID)
+		if logFlags.Verbose {
+			cli.Infof("  Current Name: '%s'", activity.Name)
+			cli.Infof("  Current Sport Type: '%s'", activity.SportType)
 		}
 	}
 }
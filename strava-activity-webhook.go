@@ -0,0 +1,336 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"strava-activity-updater/auth"
+	"strava-activity-updater/cli"
+	"strava-activity-updater/strava"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  daemon   Serve the webhook callback and rename new activities as they are created\n")
+	fmt.Fprintf(os.Stderr, "  watch    Poll for new activities and rename them, without a public callback\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments
+	authFlags := cli.RegisterAuthFlags()
+	clientFlags := cli.RegisterClientFlags()
+	listenPtr := flag.String("listen", ":8080", "Address to serve the webhook callback on")
+	verifyTokenPtr := flag.String("verify-token", os.Getenv("STRAVA_VERIFY_TOKEN"), "Token Strava echoes when validating the subscription (default $STRAVA_VERIFY_TOKEN)")
+	var mappingFiles cli.StringList
+	flag.Var(&mappingFiles, "mappings", "Name mappings file to apply to new activities; applied in the order given (repeatable)")
+	trimPtr := flag.Bool("trim", false, "Trim whitespace from new activity names before the mappings, like the cleaner")
+	intervalPtr := flag.Duration("interval", 10*time.Minute, "watch: how often to check for new activities (at least 1m, to stay well inside the rate limits)")
+	watchStatePtr := flag.String("watch-state", "strava_watch.json", "watch: file recording the newest activity processed, so a restart doesn't reprocess")
+	dryRunFlags := cli.RegisterDryRunFlags()
+	logFlags := cli.RegisterLogFlags()
+	flag.Usage = usage
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+
+	// Set up logging
+	logFlags.Configure()
+	dryRunFlags.Configure()
+
+	if command != "daemon" && command != "watch" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+
+	if command == "watch" && *intervalPtr < time.Minute {
+		log.Fatalf("Invalid -interval %s, expected at least 1m", *intervalPtr)
+	}
+	if command == "daemon" && *verifyTokenPtr == "" {
+		log.Fatalf("No verify token provided. Please set -verify-token or $STRAVA_VERIFY_TOKEN to the value used when creating the subscription")
+	}
+
+	var passes []strava.RenamePass
+	if *trimPtr {
+		passes = append(passes, strava.TrimPass())
+	}
+	for _, filename := range mappingFiles {
+		mappings, err := strava.LoadNameMappings(filename)
+		if err != nil {
+			log.Fatalf("Failed to load name mappings %s: %v", filename, err)
+		}
+		passes = append(passes, strava.MappingPass(filename, mappings))
+	}
+	if len(passes) == 0 {
+		log.Fatalf("No rules to apply. Please specify -mappings and/or -trim")
+	}
+
+	clientFlags.Configure()
+	config := authFlags.Authenticate()
+
+	if command == "watch" {
+		d := &daemon{
+			authFlags: authFlags,
+			config:    config,
+			passes:    passes,
+			dryRun:    dryRunFlags.DryRun,
+		}
+		state := &cli.IncrementalFlags{SinceLastRun: true, StateFile: *watchStatePtr}
+		if d.dryRun {
+			// Nothing is renamed, so the real cursor must stay put; the dry
+			// run moves one of its own, starting from a copy
+			dir, err := scratchState(state)
+			if err != nil {
+				log.Fatalf("Failed to copy %s: %v", *watchStatePtr, err)
+			}
+			defer os.RemoveAll(dir)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		d.watch(ctx, state, *intervalPtr)
+		cli.Infof("Stopped")
+		return
+	}
+
+	// Subscriptions receive events for every athlete who authorized the
+	// app, so only act on our own activities
+	athlete, err := strava.GetAthlete(config.AccessToken)
+	if err != nil {
+		log.Fatalf("Failed to get athlete: %v", err)
+	}
+
+	d := &daemon{
+		authFlags: authFlags,
+		config:    config,
+		athleteID: athlete.ID,
+		passes:    passes,
+		dryRun:    dryRunFlags.DryRun,
+		events:    make(chan strava.WebhookEvent, 100),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", strava.NewWebhookHandler(*verifyTokenPtr, d.enqueue))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	server := &http.Server{Addr: *listenPtr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.run()
+	}()
+
+	// Shut down gracefully: stop accepting requests, then finish the
+	// queued events
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		cli.Infof("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: Failed to shut down cleanly: %v", err)
+		}
+	}()
+
+	cli.Infof("Listening on %s (webhook at /webhook, health check at /healthz)", *listenPtr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+
+	close(d.events)
+	wg.Wait()
+	cli.Infof("Stopped")
+}
+
+// daemon renames activities reported by webhook events, one at a time.
+type daemon struct {
+	authFlags *cli.AuthFlags
+	config    *auth.StravaConfig
+	athleteID int64
+	passes    []strava.RenamePass
+	dryRun    bool
+	events    chan strava.WebhookEvent
+}
+
+// enqueue queues activity creation events so the webhook request can be
+// acknowledged right away.
+func (d *daemon) enqueue(event strava.WebhookEvent) {
+	if event.ObjectType != "activity" || event.AspectType != "create" || event.OwnerID != d.athleteID {
+		return
+	}
+
+	select {
+	case d.events <- event:
+	default:
+		log.Printf("Warning: event queue is full, dropping activity ID %d", event.ObjectID)
+	}
+}
+
+func (d *daemon) run() {
+	for event := range d.events {
+		if err := d.process(event.ObjectID); err != nil {
+			log.Printf("Failed to process activity ID %d: %v", event.ObjectID, err)
+		}
+	}
+}
+
+// watch polls for activities newer than the cursor in state every
+// interval and processes them, until ctx is cancelled. On the first run
+// the cursor starts at the latest activity, so the history is left alone.
+// The cursor only advances once every new activity was processed, so a
+// failed one is retried on the next poll.
+func (d *daemon) watch(ctx context.Context, state *cli.IncrementalFlags, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	started := false
+	for {
+		if err := d.poll(state, !started); err != nil {
+			log.Printf("Failed to check for new activities: %v", err)
+		} else {
+			started = true
+		}
+
+		select {
+		case <-ctx.Done():
+			cli.Infof("Shutting down...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll processes the activities newer than the cursor. With first set and
+// no cursor recorded yet, it only records the latest activity as the
+// cursor, or an empty one when there are no activities yet.
+func (d *daemon) poll(state *cli.IncrementalFlags, first bool) error {
+	if err := d.refreshToken(); err != nil {
+		return err
+	}
+
+	if first {
+		recorded, err := state.HasWatermark()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", state.StateFile, err)
+		}
+		if !recorded {
+			latest, err := strava.GetLatestActivity(d.config.AccessToken)
+			if errors.Is(err, strava.ErrNoActivities) {
+				state.StartEmpty()
+				cli.Infof("No activities yet, watching for the first one")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			state.Advance([]strava.Activity{*latest})
+			cli.Infof("Watching for activities after ID %d '%s'", latest.ID, latest.Name)
+			return nil
+		}
+	}
+
+	activities, err := state.FetchActivities(d.config.AccessToken)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, activity := range activities {
+		if err := d.process(activity.ID); err != nil {
+			log.Printf("Failed to process activity ID %d: %v", activity.ID, err)
+			failed = true
+		}
+	}
+	if !failed {
+		state.Advance(activities)
+	}
+	return nil
+}
+
+// scratchState points state at a copy of its file in a new temporary
+// directory, which it returns for the caller to remove.
+func scratchState(state *cli.IncrementalFlags) (string, error) {
+	data, err := os.ReadFile(state.StateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "strava-watch-dry-run")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Base(state.StateFile))
+	if data != nil {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	state.StateFile = path
+	return dir, nil
+}
+
+// refreshToken ensures d.config holds a valid access token (see
+// cli.AuthFlags.Refresh).
+func (d *daemon) refreshToken() error {
+	config, err := d.authFlags.Refresh(d.config)
+	if err != nil {
+		return err
+	}
+	d.config = config
+	return nil
+}
+
+func (d *daemon) process(activityID int64) error {
+	if err := d.refreshToken(); err != nil {
+		return err
+	}
+
+	activity, err := strava.GetActivityByID(d.config.AccessToken, activityID)
+	if err != nil {
+		return err
+	}
+
+	result := strava.SimulateRenames([]strava.Activity{*activity}, d.passes)[0]
+	if !result.Changed() {
+		cli.Infof("New activity ID %d '%s' needs no changes", activity.ID, activity.Name)
+		return nil
+	}
+
+	if d.dryRun {
+		cli.Infof("Would rename activity ID %d: '%s' -> '%s' (dry run)", activity.ID, activity.Name, result.FinalName)
+		return nil
+	}
+
+	update := strava.ActivityUpdate{Name: result.FinalName}
+	if err := strava.UpdateActivity(d.config.AccessToken, activity.ID, update); err != nil {
+		return err
+	}
+	cli.Infof("Successfully renamed activity ID %d: '%s' -> '%s'", activity.ID, activity.Name, result.FinalName)
+	for _, rule := range result.Fired {
+		cli.Infof("  Rule: %s", rule)
+	}
+	return nil
+}
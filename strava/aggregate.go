@@ -0,0 +1,154 @@
+package strava
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Row is one line of an aggregated report: a key, such as a name or a
+// month, and the number of activities with it.
+type Row struct {
+	Key   string
+	Count int
+}
+
+// Aggregator collects activities one at a time and reports what it found.
+// The counter runs every selected aggregator over the same activities, so
+// a new breakdown only needs a new Aggregator.
+type Aggregator interface {
+	// Kind names the aggregator, e.g. "sport_type"; it is the CSV kind
+	// column and the flag value that selects it.
+	Kind() string
+	// Title is the heading of the aggregator's table.
+	Title() string
+	Add(activity Activity)
+	Report() []Row
+}
+
+// keyAggregator counts activities by a key derived from each activity.
+type keyAggregator struct {
+	kind   string
+	title  string
+	key    func(Activity) (string, bool)
+	byKey  bool // sort by key rather than by count
+	counts map[string]int
+}
+
+// NewKeyAggregator returns an aggregator that counts activities by key,
+// skipping those for which key reports false. Its report is sorted by
+// count (descending), then by key, so that reports from different runs
+// can be diffed.
+func NewKeyAggregator(kind, title string, key func(Activity) (string, bool)) Aggregator {
+	return &keyAggregator{kind: kind, title: title, key: key, counts: make(map[string]int)}
+}
+
+func (a *keyAggregator) Kind() string  { return a.kind }
+func (a *keyAggregator) Title() string { return a.title }
+
+func (a *keyAggregator) Add(activity Activity) {
+	key, ok := a.key(activity)
+	if !ok {
+		return
+	}
+	a.counts[key]++
+}
+
+func (a *keyAggregator) Report() []Row {
+	rows := make([]Row, 0, len(a.counts))
+	for key, count := range a.counts {
+		rows = append(rows, Row{Key: key, Count: count})
+	}
+	if a.byKey {
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Key < rows[j].Key
+		})
+		return rows
+	}
+	SortRows(rows)
+	return rows
+}
+
+// SortRows sorts rows by count (descending), then by key.
+func SortRows(rows []Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Key < rows[j].Key
+	})
+}
+
+// CountByName counts activities by their exact name.
+func CountByName() Aggregator {
+	return NewKeyAggregator("name", "Activity Name Counts", func(a Activity) (string, bool) {
+		return a.Name, true
+	})
+}
+
+// CountBySportType counts activities by sport type.
+func CountBySportType() Aggregator {
+	return NewKeyAggregator("sport_type", "Sport Type Counts", func(a Activity) (string, bool) {
+		return a.SportType, true
+	})
+}
+
+// CountByMonth counts activities by the local month they started in, as
+// e.g. "2024-03", listed in order rather than by count.
+func CountByMonth() Aggregator {
+	aggregator := NewKeyAggregator("month", "Activities per Month", func(a Activity) (string, bool) {
+		return a.StartDateLocal.Format("2006-01"), true
+	}).(*keyAggregator)
+	aggregator.byKey = true
+	return aggregator
+}
+
+// CountByGear counts the activities recorded with each piece of gear,
+// skipping those without. Activities only carry the gear ID, so the keys
+// are IDs; look the names up with GetGear.
+func CountByGear() Aggregator {
+	return NewKeyAggregator("gear", "Gear Counts", func(a Activity) (string, bool) {
+		return a.GearID, a.GearID != ""
+	})
+}
+
+// AggregatorKinds lists the built-in aggregators by kind, in the order
+// they're documented.
+var AggregatorKinds = []string{"name", "sport_type", "month", "gear"}
+
+var builtinAggregators = map[string]func() Aggregator{
+	"name":       CountByName,
+	"sport_type": CountBySportType,
+	"month":      CountByMonth,
+	"gear":       CountByGear,
+}
+
+// ParseAggregators returns new built-in aggregators for a comma-separated
+// list of kinds, such as "name,sport_type".
+func ParseAggregators(value string) ([]Aggregator, error) {
+	var result []Aggregator
+	for _, kind := range strings.Split(value, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		newAggregator, ok := builtinAggregators[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown aggregation %q, expected one of %s", kind, strings.Join(AggregatorKinds, ", "))
+		}
+		result = append(result, newAggregator())
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no aggregation given")
+	}
+	return result, nil
+}
+
+// Aggregate runs each aggregator over activities.
+func Aggregate(activities []Activity, aggregators ...Aggregator) {
+	for _, activity := range activities {
+		for _, aggregator := range aggregators {
+			aggregator.Add(activity)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package strava
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// countedActivities returns a fixed set of activities with several ties in
+// count, by name and by sport type.
+func countedActivities() []Activity {
+	var activities []Activity
+	add := func(name, sportType string, n int) {
+		for i := 0; i < n; i++ {
+			activities = append(activities, Activity{
+				ID: int64(len(activities) + 1), Name: name, SportType: sportType,
+				StartDateLocal: time.Date(2024, time.Month(1+len(activities)%3), 1, 7, 0, 0, 0, time.UTC),
+			})
+		}
+	}
+	add("Morning Run", "Run", 3)
+	add("Lunch Ride", "Ride", 2)
+	add("Evening Walk", "Walk", 2)
+	add("morning run", "Run", 2)
+	add("Yoga", "Yoga", 1)
+	add("Afternoon Swim", "Swim", 1)
+	return activities
+}
+
+func TestAggregatorsAreDeterministic(t *testing.T) {
+	want := map[string][]Row{
+		"name": {
+			{"Morning Run", 3},
+			{"Evening Walk", 2}, {"Lunch Ride", 2}, {"morning run", 2},
+			{"Afternoon Swim", 1}, {"Yoga", 1},
+		},
+		"sport_type": {
+			{"Run", 5},
+			{"Ride", 2}, {"Walk", 2},
+			{"Swim", 1}, {"Yoga", 1},
+		},
+		"month": {{"2024-01", 4}, {"2024-02", 4}, {"2024-03", 3}},
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for run := 0; run < 20; run++ {
+		activities := countedActivities()
+		random.Shuffle(len(activities), func(i, j int) {
+			activities[i], activities[j] = activities[j], activities[i]
+		})
+
+		aggregators, err := ParseAggregators("name,sport_type,month")
+		if err != nil {
+			t.Fatal(err)
+		}
+		Aggregate(activities, aggregators...)
+		for _, aggregator := range aggregators {
+			if got := aggregator.Report(); !reflect.DeepEqual(got, want[aggregator.Kind()]) {
+				t.Fatalf("run %d: %s report = %v, want %v", run, aggregator.Kind(), got, want[aggregator.Kind()])
+			}
+		}
+	}
+}
+
+func TestGroupByCaseIsDeterministic(t *testing.T) {
+	counts := map[string]int{"Morning Run": 3, "morning run": 3, "MORNING RUN": 1, "Hiit": 2, "HIIT": 2}
+	for run := 0; run < 20; run++ {
+		groups := GroupByCase(counts, nil)
+		var got [][]string
+		for _, group := range groups {
+			got = append(got, append([]string{group.Canonical}, group.Spellings()...))
+		}
+		// Ties in count go to the alphabetically first spelling
+		want := [][]string{
+			{"HIIT", "HIIT", "Hiit"},
+			{"Morning Run", "Morning Run", "morning run", "MORNING RUN"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: groups = %v, want %v", run, got, want)
+		}
+	}
+}
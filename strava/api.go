@@ -1,56 +1,122 @@
 package strava
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ErrNoActivities is returned by GetLatestActivity for an athlete who has
+// no activities yet.
+var ErrNoActivities = errors.New("no activities found")
+
+// perPage is the page size used when listing activities, the maximum
+// allowed by the Strava API.
+const perPage = 200
+
 func GetAllActivities(accessToken string) ([]Activity, error) {
-	var allActivities []Activity
-	page := 1
-	perPage := 200 // Maximum allowed by Strava API
+	return DefaultClient.GetAllActivities(accessToken)
+}
 
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+func GetActivitiesInRange(accessToken string, after, before time.Time) ([]Activity, error) {
+	return DefaultClient.GetActivitiesInRange(accessToken, after, before)
+}
 
-		url := fmt.Sprintf("https://www.strava.com/api/v3/athlete/activities?per_page=%d&page=%d", perPage, page)
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
+func GetLatestActivity(accessToken string) (*Activity, error) {
+	return DefaultClient.GetLatestActivity(accessToken)
+}
 
-		req.Header.Add("Authorization", "Bearer "+accessToken)
+func UpdateActivity(accessToken string, activityID int64, update ActivityUpdate) error {
+	return DefaultClient.UpdateActivity(accessToken, activityID, update)
+}
 
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get activities: %w", err)
-		}
+func GetActivityByID(accessToken string, activityID int64) (*Activity, error) {
+	return DefaultClient.GetActivityByID(accessToken, activityID)
+}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to get activities: %s - %s", resp.Status, string(body))
-		}
+func GetGear(accessToken, gearID string) (*Gear, error) {
+	return DefaultClient.GetGear(accessToken, gearID)
+}
+
+func GetAthlete(accessToken string) (*Athlete, error) {
+	return DefaultClient.GetAthlete(accessToken)
+}
+
+func GetAthleteStats(accessToken string, athleteID int64) (*AthleteStats, error) {
+	return DefaultClient.GetAthleteStats(accessToken, athleteID)
+}
+
+func GetHeartRateZones(accessToken string) (*HeartRateZones, error) {
+	return DefaultClient.GetHeartRateZones(accessToken)
+}
+
+func GetActivityComments(accessToken string, activityID int64) ([]Comment, error) {
+	return DefaultClient.GetActivityComments(accessToken, activityID)
+}
+
+func GetAthleteClubs(accessToken string) ([]Club, error) {
+	return DefaultClient.GetAthleteClubs(accessToken)
+}
+
+func GetActivityStreams(accessToken string, activityID int64, types []string) (*Streams, error) {
+	return DefaultClient.GetActivityStreams(accessToken, activityID, types)
+}
+
+func (c *Client) GetAllActivities(accessToken string) ([]Activity, error) {
+	return c.GetActivitiesInRange(accessToken, time.Time{}, time.Time{})
+}
 
-		var activities []Activity
-		if err := json.NewDecoder(resp.Body).Decode(&activities); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to decode activities: %w", err)
+// GetActivitiesInRange fetches every activity that started after after and
+// before before, following pagination. A zero time leaves that side of the
+// range open.
+func (c *Client) GetActivitiesInRange(accessToken string, after, before time.Time) ([]Activity, error) {
+	var rangeParams string
+	if !after.IsZero() {
+		rangeParams += fmt.Sprintf("&after=%d", after.Unix())
+	}
+	if !before.IsZero() {
+		rangeParams += fmt.Sprintf("&before=%d", before.Unix())
+	}
+
+	defer c.flushCache()
+
+	var activities []Activity
+	var err error
+	if c.FetchConcurrency > 1 {
+		activities, err = c.fetchPagesConcurrently(accessToken, rangeParams)
+	} else {
+		activities, err = c.fetchPages(accessToken, rangeParams)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if unknown := UnknownSportTypes(activities); len(unknown) > 0 {
+		c.warnf("Activities have sport types missing from the known list: %s", strings.Join(unknown, ", "))
+	}
+	return activities, nil
+}
+
+// fetchPages fetches pages one at a time until a short (or empty) page.
+func (c *Client) fetchPages(accessToken, rangeParams string) ([]Activity, error) {
+	var allActivities []Activity
+	for page := 1; ; page++ {
+		activities, err := c.fetchPage(accessToken, page, rangeParams)
+		if err != nil {
+			return nil, err
 		}
-		resp.Body.Close()
 
 		if len(activities) == 0 {
 			break
 		}
 
 		allActivities = append(allActivities, activities...)
-		page++
+		c.logf("Fetched page %d (%d total)", page, len(allActivities))
 
 		// If we got fewer activities than requested, we've reached the end
 		if len(activities) < perPage {
@@ -61,27 +127,88 @@ func GetAllActivities(accessToken string) ([]Activity, error) {
 	return allActivities, nil
 }
 
-func GetLatestActivity(accessToken string) (*Activity, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// fetchPagesConcurrently fetches pages in waves of FetchConcurrency
+// requests. Pages are reassembled in order, and fetching stops after the
+// wave containing the first short (or empty) page; later pages in that
+// wave are discarded since they can only be empty.
+func (c *Client) fetchPagesConcurrently(accessToken, rangeParams string) ([]Activity, error) {
+	var allActivities []Activity
+	for first := 1; ; first += c.FetchConcurrency {
+		pages := make([][]Activity, c.FetchConcurrency)
+		errs := make([]error, c.FetchConcurrency)
+
+		var wg sync.WaitGroup
+		for i := range pages {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				pages[i], errs[i] = c.fetchPage(accessToken, first+i, rangeParams)
+			}(i)
+		}
+		wg.Wait()
+
+		for i, activities := range pages {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+
+			allActivities = append(allActivities, activities...)
+			if len(activities) > 0 {
+				c.logf("Fetched page %d (%d total)", first+i, len(allActivities))
+			}
+			if len(activities) < perPage {
+				return allActivities, nil
+			}
+		}
+	}
+}
+
+// fetchPage fetches one page of the athlete's activity list.
+func (c *Client) fetchPage(accessToken string, page int, rangeParams string) ([]Activity, error) {
+	ctx, cancel := c.requestContext()
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET",
-		"https://www.strava.com/api/v3/athlete/activities?per_page=1", nil)
+	path := fmt.Sprintf("/athlete/activities?per_page=%d&page=%d%s", perPage, page, rangeParams)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Add("Authorization", "Bearer "+accessToken)
+	resp, err := c.doCached(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activities: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get activities: %w", newAPIError(resp))
+	}
+
+	var activities []Activity
+	if err := json.NewDecoder(resp.Body).Decode(&activities); err != nil {
+		return nil, fmt.Errorf("failed to decode activities: %w", err)
+	}
+
+	return activities, nil
+}
+
+func (c *Client) GetLatestActivity(accessToken string) (*Activity, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	req, err := c.newRequest(ctx, "GET", accessToken, "/athlete/activities?per_page=1", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get activities: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get activities: %s - %s", resp.Status, string(body))
+		return nil, fmt.Errorf("failed to get activities: %w", newAPIError(resp))
 	}
 
 	var activities []Activity
@@ -90,16 +217,53 @@ func GetLatestActivity(accessToken string) (*Activity, error) {
 	}
 
 	if len(activities) == 0 {
-		return nil, fmt.Errorf("no activities found")
+		return nil, ErrNoActivities
 	}
 
 	return &activities[0], nil
 }
 
-func UpdateActivity(accessToken string, activityID int64, update ActivityUpdate) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// GetActivityByID fetches the detailed representation of one activity, which
+// includes fields like Description that the activity list leaves empty.
+func (c *Client) GetActivityByID(accessToken string, activityID int64) (*Activity, error) {
+	ctx, cancel := c.requestContext()
 	defer cancel()
 
+	path := fmt.Sprintf("/activities/%d", activityID)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activity: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get activity: %w", newAPIError(resp))
+	}
+
+	var activity Activity
+	if err := json.NewDecoder(resp.Body).Decode(&activity); err != nil {
+		return nil, fmt.Errorf("failed to decode activity: %w", err)
+	}
+
+	return &activity, nil
+}
+
+// UpdateActivity sends every field of update in a single PUT, so they are
+// changed together. If Strava rejects the update, the *APIError lists the
+// fields it complained about in Fields.
+func (c *Client) UpdateActivity(accessToken string, activityID int64, update ActivityUpdate) error {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	if err := update.Validate(); err != nil {
+		return fmt.Errorf("invalid update: %w", err)
+	}
+
 	// Convert update to JSON
 	updateJSON, err := json.Marshal(update)
 	if err != nil {
@@ -107,27 +271,292 @@ func UpdateActivity(accessToken string, activityID int64, update ActivityUpdate)
 	}
 
 	// Create request
-	url := fmt.Sprintf("https://www.strava.com/api/v3/activities/%d", activityID)
-	req, err := http.NewRequestWithContext(ctx, "PUT", url,
+	path := fmt.Sprintf("/activities/%d", activityID)
+	req, err := c.newRequest(ctx, "PUT", accessToken, path,
 		strings.NewReader(string(updateJSON)))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Add("Authorization", "Bearer "+accessToken)
 	req.Header.Add("Content-Type", "application/json")
 
 	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to update activity: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update activity: %s - %s", resp.Status, string(body))
+		return fmt.Errorf("failed to update activity: %w", newAPIError(resp))
 	}
 
 	return nil
 }
+
+// GetGear fetches a bike or pair of shoes by the ID found in
+// Activity.GearID.
+func (c *Client) GetGear(accessToken, gearID string) (*Gear, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	path := "/gear/" + url.PathEscape(gearID)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gear: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get gear: %w", newAPIError(resp))
+	}
+
+	var gear Gear
+	if err := json.NewDecoder(resp.Body).Decode(&gear); err != nil {
+		return nil, fmt.Errorf("failed to decode gear: %w", err)
+	}
+
+	return &gear, nil
+}
+
+func (c *Client) GetAthlete(accessToken string) (*Athlete, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	req, err := c.newRequest(ctx, "GET", accessToken, "/athlete", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get athlete: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get athlete: %w", newAPIError(resp))
+	}
+
+	var athlete Athlete
+	if err := json.NewDecoder(resp.Body).Decode(&athlete); err != nil {
+		return nil, fmt.Errorf("failed to decode athlete: %w", err)
+	}
+
+	return &athlete, nil
+}
+
+// GetAthleteStats returns the recent, year-to-date and all-time totals for
+// the authenticated athlete, whose ID comes from GetAthlete.
+func (c *Client) GetAthleteStats(accessToken string, athleteID int64) (*AthleteStats, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	path := fmt.Sprintf("/athletes/%d/stats", athleteID)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get athlete stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get athlete stats: %w", newAPIError(resp))
+	}
+
+	var stats AthleteStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode athlete stats: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// GetHeartRateZones returns the authenticated athlete's heart rate zones.
+// The token needs the profile:read_all scope. Zones is empty when the
+// account has no heart rate zones configured.
+func (c *Client) GetHeartRateZones(accessToken string) (*HeartRateZones, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	req, err := c.newRequest(ctx, "GET", accessToken, "/athlete/zones", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get zones: %w", newAPIError(resp))
+	}
+
+	var zones struct {
+		HeartRate *HeartRateZones `json:"heart_rate"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&zones); err != nil {
+		return nil, fmt.Errorf("failed to decode zones: %w", err)
+	}
+	if zones.HeartRate == nil {
+		return &HeartRateZones{}, nil
+	}
+
+	return zones.HeartRate, nil
+}
+
+// GetActivityComments fetches every comment on an activity, oldest first,
+// following pagination.
+func (c *Client) GetActivityComments(accessToken string, activityID int64) ([]Comment, error) {
+	var allComments []Comment
+	for page := 1; ; page++ {
+		comments, err := c.fetchCommentsPage(accessToken, activityID, page)
+		if err != nil {
+			return nil, err
+		}
+
+		allComments = append(allComments, comments...)
+
+		// If we got fewer comments than requested, we've reached the end
+		if len(comments) < perPage {
+			break
+		}
+	}
+
+	return allComments, nil
+}
+
+// fetchCommentsPage fetches one page of an activity's comments.
+func (c *Client) fetchCommentsPage(accessToken string, activityID int64, page int) ([]Comment, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	path := fmt.Sprintf("/activities/%d/comments?per_page=%d&page=%d", activityID, perPage, page)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get comments: %w", newAPIError(resp))
+	}
+
+	var comments []Comment
+	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
+		return nil, fmt.Errorf("failed to decode comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// GetActivityStreams fetches an activity's recorded samples of the given
+// types, e.g. "latlng", "time", "altitude" and "heartrate" (see Streams).
+// Types the activity wasn't recorded with are left empty; the time stream
+// is always included.
+func (c *Client) GetActivityStreams(accessToken string, activityID int64, types []string) (*Streams, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	keys := url.QueryEscape(strings.Join(types, ","))
+	path := fmt.Sprintf("/activities/%d/streams?keys=%s&key_by_type=true", activityID, keys)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get streams: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get streams: %w", newAPIError(resp))
+	}
+
+	var payload struct {
+		Time      struct{ Data []int }        `json:"time"`
+		LatLng    struct{ Data [][2]float64 } `json:"latlng"`
+		Altitude  struct{ Data []float64 }    `json:"altitude"`
+		Heartrate struct{ Data []int }        `json:"heartrate"`
+		Distance  struct{ Data []float64 }    `json:"distance"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode streams: %w", err)
+	}
+
+	return &Streams{
+		Time:      payload.Time.Data,
+		LatLng:    payload.LatLng.Data,
+		Altitude:  payload.Altitude.Data,
+		Heartrate: payload.Heartrate.Data,
+		Distance:  payload.Distance.Data,
+	}, nil
+}
+
+// GetAthleteClubs returns the clubs the authenticated athlete is a member
+// of, or none.
+func (c *Client) GetAthleteClubs(accessToken string) ([]Club, error) {
+	allClubs := []Club{}
+	for page := 1; ; page++ {
+		clubs, err := c.fetchClubsPage(accessToken, page)
+		if err != nil {
+			return nil, err
+		}
+
+		allClubs = append(allClubs, clubs...)
+
+		// If we got fewer clubs than requested, we've reached the end
+		if len(clubs) < perPage {
+			break
+		}
+	}
+
+	return allClubs, nil
+}
+
+// fetchClubsPage fetches one page of the athlete's clubs.
+func (c *Client) fetchClubsPage(accessToken string, page int) ([]Club, error) {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	path := fmt.Sprintf("/athlete/clubs?per_page=%d&page=%d", perPage, page)
+	req, err := c.newRequest(ctx, "GET", accessToken, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clubs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get clubs: %w", newAPIError(resp))
+	}
+
+	var clubs []Club
+	if err := json.NewDecoder(resp.Body).Decode(&clubs); err != nil {
+		return nil, fmt.Errorf("failed to decode clubs: %w", err)
+	}
+
+	return clubs, nil
+}
@@ -0,0 +1,191 @@
+package strava
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CachedResponse is a stored response body together with the validators
+// used to ask the server whether it is still current.
+type CachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// ResponseCache stores list responses between requests so they can be
+// revalidated with If-None-Match/If-Modified-Since instead of refetched.
+// Keys are request URLs, which do not include the access token, so use one
+// cache per athlete.
+type ResponseCache interface {
+	Get(key string) (CachedResponse, bool)
+	Set(key string, response CachedResponse) error
+}
+
+// cacheFlushEvery is how many entries FileCache collects before rewriting
+// its file.
+const cacheFlushEvery = 10
+
+// FileCache is a ResponseCache persisted as a JSON file. Set writes the
+// file every cacheFlushEvery entries rather than on every page, so an
+// interrupted run keeps most of what it has fetched; Flush writes the
+// rest. The client flushes its cache after each activity listing.
+type FileCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]CachedResponse
+	pending int // entries set since the file was last written
+}
+
+// OpenFileCache loads the cache at path, starting empty if the file does
+// not exist yet.
+func OpenFileCache(path string) (*FileCache, error) {
+	c := &FileCache{path: path, entries: make(map[string]CachedResponse)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+	}
+	return c, nil
+}
+
+func (c *FileCache) Get(key string) (CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	response, ok := c.entries[key]
+	return response, ok
+}
+
+func (c *FileCache) Set(key string, response CachedResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = response
+	c.pending++
+	if c.pending < cacheFlushEvery {
+		return nil
+	}
+	return c.write()
+}
+
+// Flush writes the entries set since the file was last written, if any.
+func (c *FileCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == 0 {
+		return nil
+	}
+	return c.write()
+}
+
+// write replaces the file with all entries. c.mu must be held.
+func (c *FileCache) write() error {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated cache
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	c.pending = 0
+	return nil
+}
+
+// flushCache flushes c.Cache if it buffers writes, like FileCache.
+func (c *Client) flushCache() {
+	flusher, ok := c.Cache.(interface{ Flush() error })
+	if !ok {
+		return
+	}
+	if err := flusher.Flush(); err != nil {
+		c.logf("Failed to cache response: %v", err)
+	}
+}
+
+// doCached sends a GET request through c.Cache when one is set. A 304 Not
+// Modified is turned into a 200 carrying the cached body, and fresh 200
+// responses with an ETag or Last-Modified header are stored. Responses
+// without validators are passed through untouched.
+func (c *Client) doCached(req *http.Request) (*http.Response, error) {
+	if c.Cache == nil {
+		return c.do(req)
+	}
+
+	key := req.URL.String()
+	cached, ok := c.Cache.Get(key)
+	if ok {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		resp.Body.Close()
+		c.logf("Not modified, using cached response for %s", req.URL.Path)
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK (cached)"
+		resp.Body = io.NopCloser(bytes.NewReader(cached.Body))
+		return resp, nil
+
+	case resp.StatusCode == http.StatusOK:
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag == "" && lastModified == "" {
+			return resp, nil
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Only cache bodies we can store as JSON; a failed write just
+		// means the next run refetches
+		if json.Valid(body) {
+			err := c.Cache.Set(key, CachedResponse{ETag: etag, LastModified: lastModified, Body: body})
+			if err != nil {
+				c.logf("Failed to cache response: %v", err)
+			}
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,185 @@
+package strava
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryCache is a ResponseCache that keeps entries in a map.
+type memoryCache map[string]CachedResponse
+
+func (c memoryCache) Get(key string) (CachedResponse, bool) {
+	response, ok := c[key]
+	return response, ok
+}
+
+func (c memoryCache) Set(key string, response CachedResponse) error {
+	c[key] = response
+	return nil
+}
+
+// pageServer serves a one-page activity list whose body and ETag are
+// *version, answering If-None-Match with 304 while they are unchanged. It
+// counts the full and the 304 responses.
+type pageServer struct {
+	version           int
+	lastModified      string
+	full, notModified int
+}
+
+func (s *pageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	etag := fmt.Sprintf(`"v%d"`, s.version)
+	if s.lastModified != "" {
+		if r.Header.Get("If-Modified-Since") == s.lastModified {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", s.lastModified)
+	} else if s.version > 0 {
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+	s.full++
+	fmt.Fprintf(w, `[{"id": 1, "name": "Run v%d"}]`, s.version)
+}
+
+func cachedClient(t *testing.T, handler http.Handler, cache ResponseCache) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient()
+	client.BaseURL = server.URL
+	client.Cache = cache
+	return client
+}
+
+func fetchName(t *testing.T, client *Client) string {
+	t.Helper()
+	activities, err := client.GetAllActivities("access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 1 {
+		t.Fatalf("got %d activities, want 1", len(activities))
+	}
+	return activities[0].Name
+}
+
+func TestCacheRevalidatesWithETag(t *testing.T) {
+	server := &pageServer{version: 1}
+	client := cachedClient(t, server, memoryCache{})
+
+	if name := fetchName(t, client); name != "Run v1" {
+		t.Errorf("first fetch got %q, want Run v1", name)
+	}
+	if name := fetchName(t, client); name != "Run v1" {
+		t.Errorf("cached fetch got %q, want Run v1", name)
+	}
+	if server.full != 1 || server.notModified != 1 {
+		t.Errorf("got %d full and %d 304 responses, want 1 and 1", server.full, server.notModified)
+	}
+
+	// A changed page comes back in full and replaces the cached one
+	server.version = 2
+	if name := fetchName(t, client); name != "Run v2" {
+		t.Errorf("fetch after a change got %q, want Run v2", name)
+	}
+	if name := fetchName(t, client); name != "Run v2" {
+		t.Errorf("cached fetch after a change got %q, want Run v2", name)
+	}
+	if server.full != 2 || server.notModified != 2 {
+		t.Errorf("got %d full and %d 304 responses, want 2 and 2", server.full, server.notModified)
+	}
+}
+
+func TestCacheRevalidatesWithLastModified(t *testing.T) {
+	server := &pageServer{version: 1, lastModified: "Sat, 09 Mar 2024 07:30:00 GMT"}
+	client := cachedClient(t, server, memoryCache{})
+
+	fetchName(t, client)
+	if name := fetchName(t, client); name != "Run v1" {
+		t.Errorf("cached fetch got %q, want Run v1", name)
+	}
+	if server.full != 1 || server.notModified != 1 {
+		t.Errorf("got %d full and %d 304 responses, want 1 and 1", server.full, server.notModified)
+	}
+}
+
+func TestCacheSkipsResponsesWithoutValidators(t *testing.T) {
+	server := &pageServer{}
+	cache := memoryCache{}
+	client := cachedClient(t, server, cache)
+
+	fetchName(t, client)
+	fetchName(t, client)
+	if len(cache) != 0 || server.notModified != 0 {
+		t.Errorf("cached %d responses without validators, want none", len(cache))
+	}
+}
+
+func TestFileCacheWritesInBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := OpenFileCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < cacheFlushEvery-1; i++ {
+		if err := cache.Set(fmt.Sprintf("page%d", i), CachedResponse{ETag: "x", Body: []byte("[]")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("cache file written before %d entries", cacheFlushEvery)
+	}
+	cache.Set("last", CachedResponse{ETag: "x", Body: []byte("[]")})
+	if reopened, err := OpenFileCache(path); err != nil || len(reopened.entries) != cacheFlushEvery {
+		t.Fatalf("reopened cache has %d entries (%v), want %d", len(reopened.entries), err, cacheFlushEvery)
+	}
+
+	cache.Set("extra", CachedResponse{ETag: "x", Body: []byte("[]")})
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenFileCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("extra"); !ok {
+		t.Errorf("Flush didn't write the pending entry")
+	}
+}
+
+func TestListingFlushesFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache, err := OpenFileCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &pageServer{version: 1}
+	client := cachedClient(t, server, cache)
+	fetchName(t, client)
+
+	// A later run starts from the file and gets a 304
+	reopened, err := OpenFileCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Cache = reopened
+	if name := fetchName(t, client); name != "Run v1" {
+		t.Errorf("fetch from the reopened cache got %q, want Run v1", name)
+	}
+	if server.notModified != 1 {
+		t.Errorf("got %d 304 responses, want 1", server.notModified)
+	}
+}
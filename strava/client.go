@@ -0,0 +1,206 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"strava-activity-updater/auth"
+)
+
+// DefaultBaseURL is the root of the Strava v3 API.
+const DefaultBaseURL = "https://www.strava.com/api/v3"
+
+// DefaultTimeout is the default per-request timeout.
+const DefaultTimeout = 10 * time.Second
+
+// Transport is the HTTP transport shared by clients from NewClient, so that
+// they reuse connections to the API. It keeps more idle connections per
+// host than http.DefaultTransport's two, so that concurrent fetches and
+// -exec runs don't close and reopen a TLS connection for every request.
+var Transport http.RoundTripper = newTransport()
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 16
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ExpectContinueTimeout = time.Second
+	return transport
+}
+
+// ErrCallBudgetExhausted is returned (wrapped) once a client has made
+// MaxCalls requests.
+var ErrCallBudgetExhausted = errors.New("API call budget exhausted")
+
+// Client holds the settings used to talk to the Strava API. The zero value
+// is not usable; create one with NewClient. The package-level API functions
+// use DefaultClient.
+type Client struct {
+	// BaseURL is the API root that request paths are appended to. Point it
+	// at a local fake for testing or at a proxy that exposes the API
+	// under a different host.
+	BaseURL string
+
+	// HTTPClient sends the requests. NewClient sets it to a client using
+	// the shared Transport.
+	HTTPClient *http.Client
+
+	// Timeout bounds each individual HTTP request, not a whole operation:
+	// fetching every activity page by page may take many multiples of it.
+	Timeout time.Duration
+
+	// FetchConcurrency is the number of activity pages requested at once
+	// when listing activities. Values below 2 fetch pages sequentially.
+	FetchConcurrency int
+
+	// MaxCalls caps the number of requests the client will send, to avoid
+	// exhausting a daily quota shared with other integrations. Zero means
+	// no limit.
+	MaxCalls int64
+
+	// RateLimiter, when set, is acquired before every request so the
+	// client stops short of Strava's rate limits. Clients that share an
+	// application's quota should share one RateLimiter.
+	RateLimiter *RateLimiter
+
+	// Cache, when set, is used to revalidate activity list pages with
+	// conditional requests, so unchanged pages cost a 304 instead of a
+	// full download.
+	Cache ResponseCache
+
+	calls atomic.Int64
+
+	// Logf receives progress messages from long-running operations such as
+	// paginated fetches. It is nil (silent) by default; CLIs set it to
+	// log.Printf when -verbose is given.
+	Logf func(format string, args ...any)
+
+	// UserAgent is sent with every request, so Strava can tell which
+	// client is calling. See UserAgent (the function) for the default.
+	UserAgent string
+
+	// Warnf receives warnings about responses that were used but look
+	// unexpected, such as activities with a sport type missing from
+	// SportTypes. It is nil (silent) by default.
+	Warnf func(format string, args ...any)
+}
+
+// DefaultClient is the client used by the package-level API functions.
+var DefaultClient = NewClient()
+
+// NewClient returns a client for the real Strava API.
+func NewClient() *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Transport: Transport},
+		Timeout:    DefaultTimeout,
+
+		RateLimiter: NewRateLimiter(DefaultShortTermLimit, DefaultDailyLimit),
+		UserAgent:   UserAgent("", ""),
+	}
+}
+
+// Version identifies the release in the User-Agent. Release builds set it
+// with -ldflags "-X strava-activity-updater/strava.Version=1.2.0".
+var Version = "dev"
+
+// UserAgent returns the User-Agent for tool (e.g.
+// "strava-activity-renamer"), such as "strava-activity-updater/dev
+// (strava-activity-renamer; client_id 12345)". The tool and the app's
+// client ID are left out when empty.
+func UserAgent(tool, clientID string) string {
+	var details []string
+	if tool != "" {
+		details = append(details, tool)
+	}
+	if clientID != "" {
+		details = append(details, "client_id "+clientID)
+	}
+
+	userAgent := "strava-activity-updater/" + Version
+	if len(details) > 0 {
+		userAgent += " (" + strings.Join(details, "; ") + ")"
+	}
+	return userAgent
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+func (c *Client) warnf(format string, args ...any) {
+	if c.Warnf != nil {
+		c.Warnf(format, args...)
+	}
+}
+
+// Calls returns the number of API requests sent so far.
+func (c *Client) Calls() int64 {
+	return c.calls.Load()
+}
+
+// requestContext returns the context for one request, bounded by Timeout.
+// When the RateLimiter asks to slow down it first waits for the slot it
+// reserves, so that the wait doesn't count against the request's timeout.
+func (c *Client) requestContext() (context.Context, context.CancelFunc) {
+	if c.RateLimiter != nil {
+		if delay := c.RateLimiter.Reserve(); delay > 0 {
+			c.logf("Close to the rate limit, waiting %s before the next request", delay.Round(time.Second))
+			time.Sleep(delay)
+		}
+	}
+	return context.WithTimeout(context.Background(), c.Timeout)
+}
+
+// do sends req, counting it against MaxCalls and the RateLimiter, and
+// passes the usage Strava reports on to the RateLimiter.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	n := c.calls.Add(1)
+	if c.MaxCalls > 0 && n > c.MaxCalls {
+		c.calls.Add(-1)
+		return nil, ErrCallBudgetExhausted
+	}
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.Acquire(); err != nil {
+			c.calls.Add(-1)
+			return nil, err
+		}
+	}
+
+	c.logf("API call %d: %s %s", n, req.Method, req.URL.Path)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, auth.RedactError(err)
+	}
+	if usage, ok := ParseRateLimitUsage(resp.Header); ok {
+		c.logf("Rate limit usage: %s", usage)
+		if c.RateLimiter != nil {
+			c.RateLimiter.Observe(usage)
+		}
+	}
+	return resp, nil
+}
+
+// newRequest builds an authenticated request for path, which is relative to
+// BaseURL (e.g. "/athlete/activities?page=1").
+func (c *Client) newRequest(ctx context.Context, method, accessToken, path string, body io.Reader) (*http.Request, error) {
+	url := strings.TrimSuffix(c.BaseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	return req, nil
+}
@@ -0,0 +1,61 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientSendsRequestsToBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/activities/42" {
+			t.Errorf("path = %q, want /api/v3/activities/42", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer access" {
+			t.Errorf("Authorization = %q, want Bearer access", got)
+		}
+		w.Write([]byte(`{"id": 42, "name": "Morning Run", "sport_type": "Run"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.BaseURL = server.URL + "/api/v3/"
+	activity, err := client.GetActivityByID("access", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activity.ID != 42 || activity.Name != "Morning Run" {
+		t.Errorf("got activity %d %q, want 42 \"Morning Run\"", activity.ID, activity.Name)
+	}
+	if client.Calls() != 1 {
+		t.Errorf("Calls() = %d, want 1", client.Calls())
+	}
+}
+
+func TestClientHonorsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient()
+	client.BaseURL = server.URL
+	client.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.GetActivityByID("access", 42)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want a deadline exceeded error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("GetActivityByID took %s, want it to give up after the 50ms timeout", elapsed)
+	}
+}
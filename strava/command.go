@@ -0,0 +1,183 @@
+package strava
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandRule decides an activity's update by running an external
+// command, for logic too specific to express as a Rule. The command gets
+// the activity as JSON on stdin and prints an ActivityUpdate as JSON on
+// stdout; printing nothing means "no change".
+type CommandRule struct {
+	Command []string // program and arguments, run without a shell
+	Timeout time.Duration
+}
+
+// Run runs the command for one activity.
+func (r CommandRule) Run(activity Activity) (ActivityUpdate, error) {
+	input, err := json.Marshal(activity)
+	if err != nil {
+		return ActivityUpdate{}, fmt.Errorf("failed to encode activity: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.Command[0], r.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ActivityUpdate{}, fmt.Errorf("command timed out after %s", r.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return ActivityUpdate{}, fmt.Errorf("command failed: %w: %s", err, msg)
+		}
+		return ActivityUpdate{}, fmt.Errorf("command failed: %w", err)
+	}
+
+	var update ActivityUpdate
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return update, nil
+	}
+	decoder := json.NewDecoder(&stdout)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		return ActivityUpdate{}, fmt.Errorf("invalid command output: %w", err)
+	}
+	if err := update.Validate(); err != nil {
+		return ActivityUpdate{}, fmt.Errorf("invalid command output: %w", err)
+	}
+	return update, nil
+}
+
+// ApplyCommandRule runs rule for each result's activity, as changed by the
+// rules so far, and adds the fields it sets to the result's update. At
+// most concurrency commands run at once. It returns the results in order,
+// with errs[i] set (and results[i] unchanged) where the command failed.
+func ApplyCommandRule(rule CommandRule, results []RuleResult, concurrency int) ([]RuleResult, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	updated := make([]RuleResult, len(results))
+	errs := make([]error, len(results))
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, result := range results {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, result RuleResult) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			update, err := rule.Run(withUpdate(result.Activity, result.Update))
+			updated[i] = result
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if update != (ActivityUpdate{}) {
+				updated[i].Update = overlay(result.Update, update)
+				updated[i].Fired = append(append([]string{}, result.Fired...), "command")
+			}
+		}(i, result)
+	}
+	wg.Wait()
+
+	return updated, errs
+}
+
+// withUpdate returns activity as it would be after update.
+func withUpdate(activity Activity, update ActivityUpdate) Activity {
+	if update.Name != "" {
+		activity.Name = update.Name
+	}
+	if update.SportType != "" {
+		activity.SportType = update.SportType
+	}
+	if update.Type != "" {
+		activity.Type = update.Type
+	}
+	if update.Description != "" {
+		activity.Description = update.Description
+	}
+	if !update.StartDateLocal.IsZero() {
+		activity.StartDateLocal = update.StartDateLocal
+	}
+	if update.PrivateNote != nil {
+		activity.PrivateNote = *update.PrivateNote
+	}
+	if update.HideFromHome != nil {
+		activity.HideFromHome = *update.HideFromHome
+	}
+	if update.Commute != nil {
+		activity.Commute = *update.Commute
+	}
+	if update.Trainer != nil {
+		activity.Trainer = *update.Trainer
+	}
+	if update.Visibility != "" {
+		activity.Visibility = update.Visibility
+	}
+	if update.WorkoutType != nil {
+		activity.WorkoutType = update.WorkoutType
+	}
+	if update.PerceivedExertion != nil {
+		perceivedExertion := float64(*update.PerceivedExertion)
+		activity.PerceivedExertion = &perceivedExertion
+	}
+	return activity
+}
+
+// overlay returns base with every field that top sets replaced.
+func overlay(base, top ActivityUpdate) ActivityUpdate {
+	if top.Name != "" {
+		base.Name = top.Name
+	}
+	if top.SportType != "" {
+		base.SportType = top.SportType
+	}
+	if top.Type != "" {
+		base.Type = top.Type
+	}
+	if top.Description != "" {
+		base.Description = top.Description
+	}
+	if !top.StartDateLocal.IsZero() {
+		base.StartDateLocal = top.StartDateLocal
+	}
+	if top.PrivateNote != nil {
+		base.PrivateNote = top.PrivateNote
+	}
+	if top.HideFromHome != nil {
+		base.HideFromHome = top.HideFromHome
+	}
+	if top.Commute != nil {
+		base.Commute = top.Commute
+	}
+	if top.Trainer != nil {
+		base.Trainer = top.Trainer
+	}
+	if top.Visibility != "" {
+		base.Visibility = top.Visibility
+	}
+	if top.WorkoutType != nil {
+		base.WorkoutType = top.WorkoutType
+	}
+	if top.PerceivedExertion != nil {
+		base.PerceivedExertion = top.PerceivedExertion
+	}
+	return base
+}
@@ -0,0 +1,100 @@
+package strava
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DescriptionMode selects how ApplyDescription combines new text with an
+// activity's description.
+type DescriptionMode string
+
+const (
+	// DescriptionReplace discards the current description.
+	DescriptionReplace DescriptionMode = "replace"
+	// DescriptionAppend adds the text on a line of its own at the end.
+	DescriptionAppend DescriptionMode = "append"
+	// DescriptionPrepend adds the text on a line of its own at the start.
+	DescriptionPrepend DescriptionMode = "prepend"
+	// DescriptionAppendIfMissing appends the text unless the description
+	// already contains it, so running a tool again changes nothing.
+	DescriptionAppendIfMissing DescriptionMode = "append-if-missing"
+)
+
+// DescriptionModes lists the modes in the order they're documented.
+var DescriptionModes = []DescriptionMode{DescriptionReplace, DescriptionAppend, DescriptionPrepend, DescriptionAppendIfMissing}
+
+// ParseDescriptionMode parses one of DescriptionModes.
+func ParseDescriptionMode(value string) (DescriptionMode, error) {
+	for _, mode := range DescriptionModes {
+		if DescriptionMode(value) == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown description mode %q, expected replace, append, prepend or append-if-missing", value)
+}
+
+// ApplyDescription returns the description current becomes when text is
+// written to it in mode. Appended and prepended text is separated from the
+// existing description by a newline, and a blank description is simply
+// replaced. An unknown mode replaces, like DescriptionReplace.
+func ApplyDescription(current, text string, mode DescriptionMode) string {
+	if mode != DescriptionReplace && strings.TrimSpace(current) == "" {
+		return text
+	}
+
+	switch mode {
+	case DescriptionAppendIfMissing:
+		if strings.Contains(current, text) {
+			return current
+		}
+		fallthrough
+	case DescriptionAppend:
+		return strings.TrimRight(current, "\n") + "\n" + text
+	case DescriptionPrepend:
+		return text + "\n" + strings.TrimLeft(current, "\n")
+	}
+	return text
+}
+
+// HasTag reports whether description already carries tag, ignoring case.
+// The tag must stand on its own: punctuation around it, as in "Great ride
+// #commute." or "(#commute)", doesn't count, but a longer word or tag such
+// as "#commuter" does not match "#commute".
+func HasTag(description, tag string) bool {
+	description, tag = strings.ToLower(description), strings.ToLower(tag)
+	if tag == "" {
+		return false
+	}
+	for offset := 0; ; {
+		i := strings.Index(description[offset:], tag)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(tag)
+		before, _ := utf8.DecodeLastRuneInString(description[:start])
+		after, _ := utf8.DecodeRuneInString(description[end:])
+		if (start == 0 || !isTagRune(before)) && (end == len(description) || !isTagRune(after)) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// isTagRune reports whether r can be part of a tag, so that a tag next to
+// it is part of a longer one.
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '#'
+}
+
+// WithDescriptionMode returns the update with its description written to
+// current's in mode, so e.g. an appended line is sent along with the text
+// already there. An update that doesn't set a description is unchanged.
+func (u ActivityUpdate) WithDescriptionMode(current Activity, mode DescriptionMode) ActivityUpdate {
+	if u.Description != "" {
+		u.Description = ApplyDescription(current.Description, u.Description, mode)
+	}
+	return u
+}
@@ -0,0 +1,109 @@
+package strava
+
+import "testing"
+
+func TestApplyDescription(t *testing.T) {
+	tests := []struct {
+		mode          DescriptionMode
+		current, text string
+		want          string
+	}{
+		{DescriptionReplace, "Easy run", "#commute", "#commute"},
+		{DescriptionReplace, "", "#commute", "#commute"},
+		{DescriptionAppend, "Easy run", "#commute", "Easy run\n#commute"},
+		{DescriptionAppend, "Easy run\n\n", "#commute", "Easy run\n#commute"},
+		{DescriptionAppend, "Easy run\n#commute", "#commute", "Easy run\n#commute\n#commute"},
+		{DescriptionAppend, " \n", "#commute", "#commute"},
+		{DescriptionPrepend, "Easy run", "#commute", "#commute\nEasy run"},
+		{DescriptionPrepend, "\nEasy run", "#commute", "#commute\nEasy run"},
+		{DescriptionPrepend, "", "#commute", "#commute"},
+		{DescriptionAppendIfMissing, "Easy run", "#commute", "Easy run\n#commute"},
+		{DescriptionAppendIfMissing, "Easy run\n#commute", "#commute", "Easy run\n#commute"},
+		{DescriptionAppendIfMissing, "", "#commute", "#commute"},
+		{"sideways", "Easy run", "#commute", "#commute"},
+	}
+	for _, tt := range tests {
+		if got := ApplyDescription(tt.current, tt.text, tt.mode); got != tt.want {
+			t.Errorf("ApplyDescription(%q, %q, %s) = %q, want %q", tt.current, tt.text, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestAppendIfMissingIsIdempotent(t *testing.T) {
+	for _, current := range []string{"", "Easy run", "Easy run\n", "#commute\nEasy run"} {
+		once := ApplyDescription(current, "#commute", DescriptionAppendIfMissing)
+		twice := ApplyDescription(once, "#commute", DescriptionAppendIfMissing)
+		if twice != once {
+			t.Errorf("applying to %q twice gave %q, once %q", current, twice, once)
+		}
+	}
+}
+
+func TestParseDescriptionMode(t *testing.T) {
+	for _, mode := range DescriptionModes {
+		got, err := ParseDescriptionMode(string(mode))
+		if err != nil || got != mode {
+			t.Errorf("ParseDescriptionMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := ParseDescriptionMode("Append"); err == nil {
+		t.Errorf("ParseDescriptionMode(%q) succeeded, want an error", "Append")
+	}
+}
+
+func TestWithDescriptionMode(t *testing.T) {
+	current := Activity{Name: "Morning Run", Description: "Easy run"}
+
+	update := ActivityUpdate{Name: "Commute", Description: "#commute"}.WithDescriptionMode(current, DescriptionAppend)
+	if update.Description != "Easy run\n#commute" || update.Name != "Commute" {
+		t.Errorf("got %+v, want the description appended and the name kept", update)
+	}
+
+	// Without a description the update is left alone, rather than
+	// replacing the description with the existing text
+	update = ActivityUpdate{Name: "Commute"}.WithDescriptionMode(current, DescriptionAppend)
+	if update.Description != "" {
+		t.Errorf("got description %q, want none", update.Description)
+	}
+
+	update = ActivityUpdate{Description: "#commute"}.WithDescriptionMode(Activity{Description: "Easy run\n#commute"}, DescriptionAppendIfMissing)
+	if !update.IsNoop(Activity{Description: "Easy run\n#commute"}) {
+		t.Errorf("got %+v, want a no-op for a description that already has the text", update)
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	tests := []struct {
+		description, tag string
+		want             bool
+	}{
+		{"#commute", "#commute", true},
+		{"Easy run\n#commute #indoor", "#indoor", true},
+		{"Great ride #commute.", "#commute", true},
+		{"Great ride (#commute)", "#commute", true},
+		{"#commute, #indoor!", "#indoor", true},
+		{"Great ride #Commute", "#commute", true},
+		{"Great ride #commuter", "#commute", false},
+		{"Great ride ##commute", "#commute", false},
+		{"Great ride x#commute", "#commute", false},
+		{"#commuter #commute", "#commute", true},
+		{"Commute home", "#commute", false},
+		{"Great ride", "#commute", false},
+		{"", "#commute", false},
+		{"Great ride", "", false},
+	}
+	for _, tt := range tests {
+		if got := HasTag(tt.description, tt.tag); got != tt.want {
+			t.Errorf("HasTag(%q, %q) = %v, want %v", tt.description, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestTaggingIsIdempotent(t *testing.T) {
+	// Text after the tags, as a user editing the description might add,
+	// must not make a rerun append them again
+	description := ApplyDescription("Great ride", "#commute", DescriptionAppend) + "."
+	if !HasTag(description, "#commute") {
+		t.Errorf("HasTag(%q, %q) = false after tagging, want true", description, "#commute")
+	}
+}
@@ -0,0 +1,120 @@
+package strava
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"strava-activity-updater/auth"
+)
+
+// APIError is returned (wrapped) by the API functions when Strava answers
+// with a non-success status.
+type APIError struct {
+	StatusCode int
+	Status     string // e.g. "404 Not Found"
+	Message    string // Strava's error message, or the raw response body
+	URL        string
+
+	// Fields are the fields Strava said it rejected, e.g. an update with
+	// an unknown sport type.
+	Fields []FieldError
+}
+
+func (e *APIError) Error() string {
+	if len(e.Fields) > 0 {
+		fields := make([]string, len(e.Fields))
+		for i, field := range e.Fields {
+			fields[i] = field.String()
+		}
+		return fmt.Sprintf("%s - %s: %s (%s)", e.Status, e.Message, strings.Join(fields, "; "), e.URL)
+	}
+	return fmt.Sprintf("%s - %s (%s)", e.Status, e.Message, e.URL)
+}
+
+// FieldError is one entry of the errors array in a Strava error response,
+// formatted as e.g. "activity.sport_type: invalid". It is shared with the
+// token refresh in auth, whose errors have the same shape.
+type FieldError = auth.FieldError
+
+// ScopeError is returned (wrapped) when Strava rejects a request because
+// the access token was not granted a required OAuth scope. It unwraps to
+// the underlying APIError.
+type ScopeError struct {
+	Scope string // e.g. "activity:write"
+	*APIError
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("access token is missing the %s scope (%s): re-authorize the app with scope=read,activity:read_all,%s and save the new refresh token",
+		e.Scope, e.Status, e.Scope)
+}
+
+func (e *ScopeError) Unwrap() error {
+	return e.APIError
+}
+
+// newAPIError builds an error from a failed response, consuming its body.
+// It is a *ScopeError when the response says a scope is missing and an
+// *APIError otherwise.
+func newAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    auth.Redact(strings.TrimSpace(string(body))),
+	}
+	if resp.Request != nil {
+		apiErr.URL = auth.Redact(resp.Request.URL.Redacted())
+	}
+
+	payload, ok := auth.ParseErrorResponse(body)
+	if !ok {
+		return apiErr
+	}
+	if payload.Message != "" {
+		apiErr.Message = auth.Redact(payload.Message)
+	}
+
+	// Strava reports a missing scope as e.g. {"resource": "AccessToken",
+	// "field": "activity:write_permission", "code": "missing"}
+	for _, e := range payload.Errors {
+		if e.Resource == "AccessToken" && e.Code == "missing" && strings.HasSuffix(e.Field, "_permission") {
+			return &ScopeError{Scope: strings.TrimSuffix(e.Field, "_permission"), APIError: apiErr}
+		}
+	}
+	apiErr.Fields = payload.Errors
+
+	return apiErr
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// IsRateLimited reports whether err is a 429 Too Many Requests from Strava.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsUnauthorized reports whether err is a 401 Unauthorized from Strava,
+// usually meaning the access token is invalid or expired.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsMissingScope reports whether err says the access token lacks a
+// required scope. See ScopeError.
+func IsMissingScope(err error) bool {
+	var scopeErr *ScopeError
+	return errors.As(err, &scopeErr)
+}
+
+// IsNotFound reports whether err is a 404 Not Found from Strava.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
@@ -0,0 +1,113 @@
+package strava
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// failingServer answers every request with status and body.
+func failingServer(t *testing.T, status int, body string) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient()
+	client.BaseURL = server.URL
+	return client
+}
+
+func TestGetLatestActivityWithoutActivities(t *testing.T) {
+	client := failingServer(t, http.StatusOK, `[]`)
+	if _, err := client.GetLatestActivity("access"); !errors.Is(err, ErrNoActivities) {
+		t.Errorf("GetLatestActivity() error = %v, want ErrNoActivities", err)
+	}
+}
+
+func TestUpdateActivityReportsRejectedFields(t *testing.T) {
+	client := failingServer(t, http.StatusBadRequest,
+		`{"message": "Bad Request", "errors": [{"resource": "Activity", "field": "sport_type", "code": "invalid"}, {"resource": "Activity", "field": "name", "code": "too long"}]}`)
+
+	err := client.UpdateActivity("access", 42, ActivityUpdate{SportType: "Run"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an *APIError", err)
+	}
+	want := []FieldError{
+		{Resource: "Activity", Field: "sport_type", Code: "invalid"},
+		{Resource: "Activity", Field: "name", Code: "too long"},
+	}
+	if len(apiErr.Fields) != len(want) || apiErr.Fields[0] != want[0] || apiErr.Fields[1] != want[1] {
+		t.Errorf("Fields = %+v, want %+v", apiErr.Fields, want)
+	}
+	if apiErr.Message != "Bad Request" || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %d %q, want 400 \"Bad Request\"", apiErr.StatusCode, apiErr.Message)
+	}
+	if !strings.Contains(err.Error(), "Bad Request: activity.sport_type: invalid; activity.name: too long") {
+		t.Errorf("Error() = %q, want the rejected fields", err.Error())
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		check   func(error) bool
+		message string
+	}{
+		{"missing scope", http.StatusUnauthorized,
+			`{"message": "Authorization Error", "errors": [{"resource": "AccessToken", "field": "activity:write_permission", "code": "missing"}]}`,
+			IsMissingScope, "Authorization Error"},
+		{"invalid token", http.StatusUnauthorized,
+			`{"message": "Authorization Error", "errors": [{"resource": "Athlete", "field": "access_token", "code": "invalid"}]}`,
+			IsUnauthorized, "Authorization Error"},
+		{"not found", http.StatusNotFound,
+			`{"message": "Record Not Found", "errors": [{"resource": "Activity", "field": "id", "code": "not found"}]}`,
+			IsNotFound, "Record Not Found"},
+		{"rate limited", http.StatusTooManyRequests,
+			`{"message": "Rate Limit Exceeded", "errors": [{"resource": "Application", "field": "rate limit", "code": "exceeded"}]}`,
+			IsRateLimited, "Rate Limit Exceeded"},
+		{"html page", http.StatusBadGateway, "<html>Bad Gateway</html>",
+			func(err error) bool { return hasStatus(err, http.StatusBadGateway) }, "<html>Bad Gateway</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := failingServer(t, tt.status, tt.body)
+			_, err := client.GetActivityByID("access", 42)
+			if !tt.check(err) {
+				t.Fatalf("err = %v, not recognized", err)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want it to unwrap to an *APIError", err)
+			}
+			if apiErr.Message != tt.message {
+				t.Errorf("Message = %q, want %q", apiErr.Message, tt.message)
+			}
+			if !strings.HasSuffix(apiErr.URL, "/activities/42") {
+				t.Errorf("URL = %q, want the request URL", apiErr.URL)
+			}
+		})
+	}
+}
+
+func TestMissingScopeError(t *testing.T) {
+	client := failingServer(t, http.StatusUnauthorized,
+		`{"message": "Authorization Error", "errors": [{"resource": "AccessToken", "field": "activity:write_permission", "code": "missing"}]}`)
+
+	err := client.UpdateActivity("access", 42, ActivityUpdate{Name: "Run"})
+	var scopeErr *ScopeError
+	if !errors.As(err, &scopeErr) {
+		t.Fatalf("err = %v, want a *ScopeError", err)
+	}
+	if scopeErr.Scope != "activity:write" {
+		t.Errorf("Scope = %q, want activity:write", scopeErr.Scope)
+	}
+}
@@ -0,0 +1,230 @@
+package strava
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WriteActivitiesJSON writes activities to w using the same field names as
+// the Strava API. In pretty mode the whole list is written as one indented
+// JSON array; otherwise each activity is written as a single line
+// (newline-delimited JSON) so the output can be streamed into tools like jq.
+func WriteActivitiesJSON(w io.Writer, activities []Activity, pretty bool) error {
+	encoder := json.NewEncoder(w)
+
+	if pretty {
+		encoder.SetIndent("", "  ")
+		if activities == nil {
+			activities = []Activity{}
+		}
+		if err := encoder.Encode(activities); err != nil {
+			return fmt.Errorf("failed to encode activities: %w", err)
+		}
+		return nil
+	}
+
+	for _, activity := range activities {
+		if err := encoder.Encode(activity); err != nil {
+			return fmt.Errorf("failed to encode activity %d: %w", activity.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// ActivityComments are the comments on one activity, as written by
+// WriteCommentsJSON.
+type ActivityComments struct {
+	ActivityID     int64     `json:"activity_id"`
+	Name           string    `json:"name"`
+	StartDateLocal time.Time `json:"start_date_local"`
+	Comments       []Comment `json:"comments"`
+}
+
+// WriteCommentsJSON writes the comments of each activity to w, as one
+// indented array in pretty mode or one activity per line otherwise, like
+// WriteActivitiesJSON.
+func WriteCommentsJSON(w io.Writer, comments []ActivityComments, pretty bool) error {
+	encoder := json.NewEncoder(w)
+
+	if pretty {
+		encoder.SetIndent("", "  ")
+		if comments == nil {
+			comments = []ActivityComments{}
+		}
+		if err := encoder.Encode(comments); err != nil {
+			return fmt.Errorf("failed to encode comments: %w", err)
+		}
+		return nil
+	}
+
+	for _, activity := range comments {
+		if err := encoder.Encode(activity); err != nil {
+			return fmt.Errorf("failed to encode comments of activity %d: %w", activity.ActivityID, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadActivitiesJSON reads activities written by WriteActivitiesJSON, in
+// either the indented array or the newline-delimited form.
+func ReadActivitiesJSON(r io.Reader) ([]Activity, error) {
+	reader := bufio.NewReader(r)
+	decoder := json.NewDecoder(reader)
+
+	// Peek at the first non-space byte to tell the two forms apart
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read activities: %w", err)
+		}
+		if b[0] == '[' {
+			var activities []Activity
+			if err := decoder.Decode(&activities); err != nil {
+				return nil, fmt.Errorf("failed to decode activities: %w", err)
+			}
+			return activities, nil
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+		reader.ReadByte()
+	}
+
+	var activities []Activity
+	for {
+		var activity Activity
+		err := decoder.Decode(&activity)
+		if err == io.EOF {
+			return activities, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode activity %d: %w", len(activities)+1, err)
+		}
+		activities = append(activities, activity)
+	}
+}
+
+// csvColumns are the columns written by WriteActivitiesCSV. Of these,
+// ReadActivityEditsCSV reads back id, name and sport_type.
+var csvColumns = []string{"id", "start_date_local", "sport_type", "name", "distance"}
+
+// WriteActivitiesCSV writes activities to w as CSV with a header row, for
+// editing in a spreadsheet and feeding back through ReadActivityEditsCSV.
+// Distances are in meters and start times are local wall-clock times.
+func WriteActivitiesCSV(w io.Writer, activities []Activity) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, activity := range activities {
+		record := []string{
+			strconv.FormatInt(activity.ID, 10),
+			activity.StartDateLocal.Format("2006-01-02 15:04:05"),
+			activity.SportType,
+			activity.Name,
+			strconv.FormatFloat(activity.Distance, 'f', 1, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write activity %d: %w", activity.ID, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ActivityEdit is one row of an edits CSV: the desired values for an
+// activity's fields.
+type ActivityEdit struct {
+	Line   int // line in the CSV, for error messages
+	ID     int64
+	Update ActivityUpdate
+}
+
+// ReadActivityEditsCSV reads a CSV with a header row and an id column, plus
+// any of the name, sport_type and description columns. Other columns, like
+// the extra ones WriteActivitiesCSV writes, are ignored, as are empty cells,
+// so a row only sets the fields that have a value.
+func ReadActivityEditsCSV(r io.Reader) ([]ActivityEdit, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		// Spreadsheets often save with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idColumn, ok := columns["id"]
+	if !ok {
+		return nil, fmt.Errorf("missing id column")
+	}
+
+	cell := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var edits []ActivityEdit
+	seen := make(map[int64]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return edits, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read edits: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if idColumn >= len(record) || strings.TrimSpace(record[idColumn]) == "" {
+			return nil, fmt.Errorf("line %d: missing id", line)
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(record[idColumn]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid id %q", line, record[idColumn])
+		}
+		if previous, ok := seen[id]; ok {
+			return nil, fmt.Errorf("line %d: activity %d already edited on line %d", line, id, previous)
+		}
+		seen[id] = line
+
+		edit := ActivityEdit{
+			Line: line,
+			ID:   id,
+			Update: ActivityUpdate{
+				Name:        cell(record, "name"),
+				SportType:   cell(record, "sport_type"),
+				Description: cell(record, "description"),
+			},
+		}
+		if err := edit.Update.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		edits = append(edits, edit)
+	}
+}
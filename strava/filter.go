@@ -0,0 +1,178 @@
+package strava
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter reports whether an activity should be included in an operation.
+type Filter func(Activity) bool
+
+// FilterActivities returns the activities that match every filter, in their
+// original order. With no filters, all activities are returned.
+func FilterActivities(activities []Activity, filters ...Filter) []Activity {
+	match := All(filters...)
+
+	var matched []Activity
+	for _, activity := range activities {
+		if match(activity) {
+			matched = append(matched, activity)
+		}
+	}
+	return matched
+}
+
+// All combines filters into one that matches only when every filter does.
+func All(filters ...Filter) Filter {
+	return func(a Activity) bool {
+		for _, filter := range filters {
+			if !filter(a) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ByMinDistance matches activities at least the given distance in meters.
+func ByMinDistance(meters float64) Filter {
+	return func(a Activity) bool {
+		return a.Distance >= meters
+	}
+}
+
+// ByMaxDistance matches activities at most the given distance in meters.
+func ByMaxDistance(meters float64) Filter {
+	return func(a Activity) bool {
+		return a.Distance <= meters
+	}
+}
+
+// ByName matches activities with exactly the given name.
+func ByName(name string) Filter {
+	return func(a Activity) bool {
+		return a.Name == name
+	}
+}
+
+// ByNameContains matches activities whose name contains substr, ignoring
+// case if ignoreCase is set.
+func ByNameContains(substr string, ignoreCase bool) Filter {
+	if ignoreCase {
+		substr = strings.ToLower(substr)
+	}
+	return func(a Activity) bool {
+		name := a.Name
+		if ignoreCase {
+			name = strings.ToLower(name)
+		}
+		return strings.Contains(name, substr)
+	}
+}
+
+// ByNameMatching matches activities whose name matches re.
+func ByNameMatching(re *regexp.Regexp) Filter {
+	return func(a Activity) bool {
+		return re.MatchString(a.Name)
+	}
+}
+
+// ByIDs matches activities with one of the given IDs.
+func ByIDs(ids ...int64) Filter {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return func(a Activity) bool {
+		return set[a.ID]
+	}
+}
+
+// BySportType matches activities with the given sport type.
+func BySportType(sportType string) Filter {
+	return func(a Activity) bool {
+		return a.SportType == sportType
+	}
+}
+
+// ByNotSportType matches activities whose sport type is none of
+// sportTypes.
+func ByNotSportType(sportTypes ...string) Filter {
+	return func(a Activity) bool {
+		for _, sportType := range sportTypes {
+			if a.SportType == sportType {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ByStartedAfter matches activities that started at or after t.
+func ByStartedAfter(t time.Time) Filter {
+	return func(a Activity) bool {
+		return !a.StartDate.Before(t)
+	}
+}
+
+// ByStartedBefore matches activities that started before t.
+func ByStartedBefore(t time.Time) Filter {
+	return func(a Activity) bool {
+		return a.StartDate.Before(t)
+	}
+}
+
+// ByLocalStartAfter matches activities whose local start time
+// (StartDateLocal) is at or after the wall-clock time t. Give t in UTC,
+// the way StartDateLocal is encoded.
+func ByLocalStartAfter(t time.Time) Filter {
+	return func(a Activity) bool {
+		return !a.StartDateLocal.Before(t)
+	}
+}
+
+// ByLocalStartBefore matches activities whose local start time is before
+// the wall-clock time t, given in UTC like for ByLocalStartAfter.
+func ByLocalStartBefore(t time.Time) Filter {
+	return func(a Activity) bool {
+		return a.StartDateLocal.Before(t)
+	}
+}
+
+// ByTrainer matches activities whose trainer flag equals trainer.
+func ByTrainer(trainer bool) Filter {
+	return func(a Activity) bool {
+		return a.Trainer == trainer
+	}
+}
+
+// ByCommute matches activities whose commute flag equals commute.
+func ByCommute(commute bool) Filter {
+	return func(a Activity) bool {
+		return a.Commute == commute
+	}
+}
+
+// ByManual matches activities whose manual flag equals manual.
+func ByManual(manual bool) Filter {
+	return func(a Activity) bool {
+		return a.Manual == manual
+	}
+}
+
+// ByVisibility matches activities with the given visibility, one of
+// Visibilities.
+func ByVisibility(visibility string) Filter {
+	return func(a Activity) bool {
+		return a.ActivityVisibility() == visibility
+	}
+}
+
+// ByHasPhotos matches activities with at least one photo when has is true,
+// or with none when it is false.
+func ByHasPhotos(has bool) Filter {
+	return func(a Activity) bool {
+		return (a.TotalPhotoCount > 0) == has
+	}
+}
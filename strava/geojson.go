@@ -0,0 +1,85 @@
+package strava
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// geoJSONFeature is one activity in a GeoJSON FeatureCollection. Geometry
+// is a LineString, or null for an activity without a route.
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   *geoJSONLine      `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONLine struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"` // longitude, latitude
+}
+
+type geoJSONProperties struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	SportType          string    `json:"sport_type"`
+	StartDateLocal     time.Time `json:"start_date_local"`
+	Distance           float64   `json:"distance"`
+	TotalElevationGain float64   `json:"total_elevation_gain"`
+	ElevHigh           float64   `json:"elev_high"`
+	ElevLow            float64   `json:"elev_low"`
+}
+
+// WriteActivitiesGeoJSON writes activities to w as a GeoJSON
+// FeatureCollection, with each activity's summary route as a LineString
+// and its name, sport type, start time and distances as properties.
+// Activities without a route get a null geometry, so every activity is
+// still listed. It returns the IDs of activities whose polyline could not
+// be decoded, which are written with a null geometry too.
+func WriteActivitiesGeoJSON(w io.Writer, activities []Activity, pretty bool) ([]int64, error) {
+	collection := struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+
+	var malformed []int64
+	for _, activity := range activities {
+		feature := geoJSONFeature{
+			Type: "Feature",
+			Properties: geoJSONProperties{
+				ID:                 activity.ID,
+				Name:               activity.Name,
+				SportType:          activity.SportType,
+				StartDateLocal:     activity.StartDateLocal,
+				Distance:           activity.Distance,
+				TotalElevationGain: activity.TotalElevationGain,
+				ElevHigh:           activity.ElevHigh,
+				ElevLow:            activity.ElevLow,
+			},
+		}
+
+		if activity.Map.SummaryPolyline != "" {
+			points, err := DecodePolyline(activity.Map.SummaryPolyline)
+			if err != nil {
+				malformed = append(malformed, activity.ID)
+			} else {
+				line := &geoJSONLine{Type: "LineString", Coordinates: make([][2]float64, len(points))}
+				for i, point := range points {
+					line.Coordinates[i] = [2]float64{point[1], point[0]}
+				}
+				feature.Geometry = line
+			}
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(collection); err != nil {
+		return malformed, fmt.Errorf("failed to encode activities: %w", err)
+	}
+	return malformed, nil
+}
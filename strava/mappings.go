@@ -0,0 +1,160 @@
+package strava
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MappingSeparator separates the current and new name on each line of a
+// name mappings file.
+const MappingSeparator = " => "
+
+// NameMapping is one rename rule. A rule with a SportType only applies to
+// activities of that sport type.
+type NameMapping struct {
+	SportType string
+	From      string
+	To        string
+}
+
+// NameMappings is a set of rename rules. Sport-scoped rules take precedence
+// over generic ones: an activity is renamed by the rule for its sport type
+// and name if there is one, and by the generic rule for its name otherwise.
+type NameMappings struct {
+	Generic map[string]string            // name -> new name
+	BySport map[string]map[string]string // sport type -> name -> new name
+}
+
+// Lookup returns the new name for an activity with the given sport type
+// and name, and whether any rule matched.
+func (m NameMappings) Lookup(sportType, name string) (string, bool) {
+	if to, ok := m.BySport[sportType][name]; ok {
+		return to, true
+	}
+	to, ok := m.Generic[name]
+	return to, ok
+}
+
+// Rules returns every rule, generic ones first, each group sorted by name.
+func (m NameMappings) Rules() []NameMapping {
+	var rules []NameMapping
+	for from, to := range m.Generic {
+		rules = append(rules, NameMapping{From: from, To: to})
+	}
+	for sportType, mappings := range m.BySport {
+		for from, to := range mappings {
+			rules = append(rules, NameMapping{SportType: sportType, From: from, To: to})
+		}
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].SportType != rules[j].SportType {
+			return rules[i].SportType < rules[j].SportType
+		}
+		return rules[i].From < rules[j].From
+	})
+	return rules
+}
+
+// add adds a rule, reporting a conflicting rule for the same name.
+func (m *NameMappings) add(rule NameMapping) error {
+	mappings := m.Generic
+	if rule.SportType != "" {
+		if m.BySport == nil {
+			m.BySport = make(map[string]map[string]string)
+		}
+		if m.BySport[rule.SportType] == nil {
+			m.BySport[rule.SportType] = make(map[string]string)
+		}
+		mappings = m.BySport[rule.SportType]
+	} else if mappings == nil {
+		m.Generic = make(map[string]string)
+		mappings = m.Generic
+	}
+
+	if previous, exists := mappings[rule.From]; exists && previous != rule.To {
+		return fmt.Errorf("%q is already mapped to %q", rule.From, previous)
+	}
+	mappings[rule.From] = rule.To
+	return nil
+}
+
+// LoadCanonicalNames reads a list of canonical activity names, one per
+// line, for fuzzy renaming with NearestName. Blank lines and lines starting
+// with "#" are ignored, and surrounding spaces are trimmed.
+func LoadCanonicalNames(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// LoadNameMappings reads a name mappings file, as used by the renamer. Each
+// line has the form "current name => new name"; blank lines and lines
+// starting with "#" are ignored. Names are taken verbatim, including any
+// leading or trailing spaces, so that messy names can be matched exactly.
+//
+// A line starting with "@" and a sport type scopes the rule to that sport
+// type, e.g. "@Ride Morning Workout => Morning Ride". See NameMappings for
+// the precedence between scoped and generic rules. There is no escape for
+// this prefix, so names starting with "@" can't be mapped: a line like
+// "@home run => Home Run" is rejected as an unknown sport type "home".
+func LoadNameMappings(filename string) (NameMappings, error) {
+	var mappings NameMappings
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return mappings, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule NameMapping
+		if sportType, rest, ok := strings.Cut(line, " "); ok && strings.HasPrefix(sportType, "@") {
+			rule.SportType = sportType[1:]
+			if !IsValidSportType(rule.SportType) {
+				return mappings, fmt.Errorf("line %d: unknown sport type %q", lineNo, rule.SportType)
+			}
+			line = rest
+		}
+
+		from, to, ok := strings.Cut(line, MappingSeparator)
+		if !ok || from == "" || to == "" {
+			return mappings, fmt.Errorf("line %d: expected \"[@SportType ]current name%snew name\"", lineNo, MappingSeparator)
+		}
+		rule.From, rule.To = from, to
+		if err := mappings.add(rule); err != nil {
+			return mappings, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return mappings, err
+	}
+
+	return mappings, nil
+}
@@ -0,0 +1,170 @@
+package strava
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeMappings writes a name mappings file with the given lines.
+func writeMappings(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mappings.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNameMappingsLookup(t *testing.T) {
+	mappings, err := LoadNameMappings(writeMappings(t,
+		"# Generic rules apply to every sport type",
+		"Morning Workout => Morning Run",
+		"",
+		"@Ride Morning Workout => Morning Ride",
+		"  Lunch  => Lunch Run",
+		"Evening Run => Evening Jog\r",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		sportType, input string
+		want             string
+		wantOK           bool
+	}{
+		{"scoped rule beats the generic one", "Ride", "Morning Workout", "Morning Ride", true},
+		{"generic rule for other sport types", "Run", "Morning Workout", "Morning Run", true},
+		{"scope is the exact sport type", "GravelRide", "Morning Workout", "Morning Run", true},
+		{"names are verbatim", "Run", "  Lunch ", "Lunch Run", true},
+		{"surrounding spaces must match", "Run", "Lunch", "", false},
+		{"carriage return is not part of the name", "Run", "Evening Run", "Evening Jog", true},
+		{"no rule", "Run", "Afternoon Run", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mappings.Lookup(tt.sportType, tt.input)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Lookup(%q, %q) = %q, %v; want %q, %v", tt.sportType, tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoadNameMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []NameMapping
+		wantErr string
+	}{
+		{
+			name:  "sport scope",
+			lines: []string{"@Ride Morning Workout => Morning Ride", "Morning Workout => Morning Run"},
+			want: []NameMapping{
+				{From: "Morning Workout", To: "Morning Run"},
+				{SportType: "Ride", From: "Morning Workout", To: "Morning Ride"},
+			},
+		},
+		{
+			name:  "verbatim names",
+			lines: []string{" Morning Run  =>  Morning Run"},
+			want:  []NameMapping{{From: " Morning Run ", To: " Morning Run"}},
+		},
+		{
+			name:  "identical duplicates",
+			lines: []string{"Run => Morning Run", "Run => Morning Run"},
+			want:  []NameMapping{{From: "Run", To: "Morning Run"}},
+		},
+		{
+			name:  "same name in different scopes",
+			lines: []string{"@Ride Workout => Ride", "@Run Workout => Run"},
+			want: []NameMapping{
+				{SportType: "Ride", From: "Workout", To: "Ride"},
+				{SportType: "Run", From: "Workout", To: "Run"},
+			},
+		},
+		{
+			name:    "unknown sport type",
+			lines:   []string{"# Typo", "@Rdie Morning Workout => Morning Ride"},
+			wantErr: `line 2: unknown sport type "Rdie"`,
+		},
+		{
+			name:    "name starting with @",
+			lines:   []string{"@home run => Home Run"},
+			wantErr: `line 1: unknown sport type "home"`,
+		},
+		{
+			name:    "conflicting duplicates",
+			lines:   []string{"Run => Morning Run", "Run => Evening Run"},
+			wantErr: `line 2: "Run" is already mapped to "Morning Run"`,
+		},
+		{
+			name:    "conflicting scoped duplicates",
+			lines:   []string{"@Ride Workout => Ride", "@Ride Workout => Spin"},
+			wantErr: `line 2: "Workout" is already mapped to "Ride"`,
+		},
+		{
+			name:    "missing separator",
+			lines:   []string{"Morning Run -> Evening Run"},
+			wantErr: "line 1: expected",
+		},
+		{
+			name:    "scope without a rule",
+			lines:   []string{"@Ride"},
+			wantErr: "line 1: expected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings, err := LoadNameMappings(writeMappings(t, tt.lines...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadNameMappings() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mappings.Rules(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMappingPass(t *testing.T) {
+	mappings, err := LoadNameMappings(writeMappings(t,
+		"Morning Workout => Morning Run",
+		"@Ride Morning Workout => Morning Ride",
+		"Morning Run => Morning Run",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := SimulateRenames([]Activity{
+		{ID: 1, SportType: "Ride", Name: "Morning Workout"},
+		{ID: 2, SportType: "Run", Name: "Morning Workout"},
+		{ID: 3, SportType: "Run", Name: "Morning Run"},
+	}, []RenamePass{MappingPass("mappings.txt", mappings)})
+
+	want := []struct {
+		name  string
+		fired []string
+	}{
+		{"Morning Ride", []string{"mappings.txt: Ride 'Morning Workout' -> 'Morning Ride'"}},
+		{"Morning Run", []string{"mappings.txt: 'Morning Workout' -> 'Morning Run'"}},
+		{"Morning Run", nil},
+	}
+	for i, result := range results {
+		if result.FinalName != want[i].name || !reflect.DeepEqual(result.Fired, want[i].fired) {
+			t.Errorf("activity %d: got %q fired by %q, want %q fired by %q", result.Activity.ID, result.FinalName, result.Fired, want[i].name, want[i].fired)
+		}
+	}
+}
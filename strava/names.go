@@ -0,0 +1,400 @@
+package strava
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EditDistance returns the Levenshtein distance between a and b: the
+// number of single-rune insertions, deletions or substitutions needed to
+// turn one into the other.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// CaseGroup is a set of names that differ only by letter case.
+type CaseGroup struct {
+	// Canonical is the dominant spelling: the most used one (ties broken
+	// alphabetically), with exception words restored to their casing.
+	Canonical string
+	// Counts maps each spelling in the group to its number of activities.
+	Counts map[string]int
+}
+
+// GroupByCase finds names (given with their activity counts) that differ
+// only by case and picks a canonical spelling for each group. Names that
+// are already consistent are not returned. Exceptions are words whose
+// capitalization is intentional, such as brand names or acronyms ("HIIT",
+// "CrossFit"); they keep their given casing in the canonical form.
+func GroupByCase(nameCounts map[string]int, exceptions []string) []CaseGroup {
+	byKey := make(map[string]map[string]int)
+	for name, count := range nameCounts {
+		key := strings.ToLower(name)
+		if byKey[key] == nil {
+			byKey[key] = make(map[string]int)
+		}
+		byKey[key][name] += count
+	}
+
+	var groups []CaseGroup
+	for _, counts := range byKey {
+		canonical := dominantSpelling(counts)
+		canonical = applyCaseExceptions(canonical, exceptions)
+
+		// A lone spelling only needs attention if an exception changes it
+		if len(counts) < 2 && counts[canonical] > 0 {
+			continue
+		}
+		groups = append(groups, CaseGroup{Canonical: canonical, Counts: counts})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Canonical < groups[j].Canonical
+	})
+	return groups
+}
+
+// Spellings returns the group's spellings, most used first.
+func (g CaseGroup) Spellings() []string {
+	return byCount(g.Counts)
+}
+
+// byCount returns the names in counts, most used first and alphabetically
+// among equals.
+func byCount(counts map[string]int) []string {
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func dominantSpelling(counts map[string]int) string {
+	var best string
+	for name, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && name < best) {
+			best = name
+		}
+	}
+	return best
+}
+
+// applyCaseExceptions rewrites every whole-word, case-insensitive match of
+// an exception in name to the exception's casing.
+func applyCaseExceptions(name string, exceptions []string) string {
+	for _, exception := range exceptions {
+		if exception == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(exception) + `\b`)
+		name = re.ReplaceAllLiteralString(name, exception)
+	}
+	return name
+}
+
+// NameCluster is a set of similar names, such as typos and casing variants
+// of the same activity name.
+type NameCluster struct {
+	// Canonical is the most used name in the cluster (ties broken
+	// alphabetically).
+	Canonical string
+	// Counts maps each name in the cluster to its number of activities.
+	Counts map[string]int
+}
+
+// ClusterNames groups names (given with their activity counts) that are
+// within maxDistance edits of each other, ignoring case and surrounding
+// whitespace. Similarity is transitive, so a chain of close names ends up
+// in one cluster. To keep short names like "Run" and "Ride" apart, two
+// names are only linked when the distance is at most a quarter of the
+// shorter name's length. Names with no similar name are not returned.
+func ClusterNames(nameCounts map[string]int, maxDistance int) []NameCluster {
+	names := make([]string, 0, len(nameCounts))
+	for name := range nameCounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+
+	// Union-find over name indexes
+	parent := make([]int, len(names))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			shorter := min(len([]rune(keys[i])), len([]rune(keys[j])))
+			if d := EditDistance(keys[i], keys[j]); d <= maxDistance && d*4 <= shorter {
+				parent[root(j)] = root(i)
+			}
+		}
+	}
+
+	byRoot := make(map[int]map[string]int)
+	for i, name := range names {
+		r := root(i)
+		if byRoot[r] == nil {
+			byRoot[r] = make(map[string]int)
+		}
+		byRoot[r][name] = nameCounts[name]
+	}
+
+	var clusters []NameCluster
+	for _, counts := range byRoot {
+		if len(counts) < 2 {
+			continue
+		}
+		clusters = append(clusters, NameCluster{Canonical: dominantSpelling(counts), Counts: counts})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Canonical < clusters[j].Canonical
+	})
+	return clusters
+}
+
+// Variants returns the cluster's names other than Canonical, most used
+// first.
+func (c NameCluster) Variants() []string {
+	var variants []string
+	for _, name := range byCount(c.Counts) {
+		if name != c.Canonical {
+			variants = append(variants, name)
+		}
+	}
+	return variants
+}
+
+// FuzzyMatch is the canonical name nearest to an activity name.
+type FuzzyMatch struct {
+	Canonical string
+	Distance  int     // edit distance, ignoring case
+	Score     float64 // 1 minus Distance over the longer name's length
+}
+
+// NearestName returns the canonical name closest to name, ignoring case,
+// if it is within maxDistance edits and scores at least minScore. When two
+// canonical names are equally close there is no telling which was meant,
+// so nothing matches. A name that already is one of canonical matches
+// itself.
+func NearestName(name string, canonical []string, maxDistance int, minScore float64) (FuzzyMatch, bool) {
+	lower := strings.ToLower(name)
+
+	var best FuzzyMatch
+	found, tied := false, false
+	for _, candidate := range canonical {
+		if candidate == name {
+			return FuzzyMatch{Canonical: candidate, Score: 1}, true
+		}
+
+		distance := EditDistance(lower, strings.ToLower(candidate))
+		if distance > maxDistance {
+			continue
+		}
+		longest := max(len([]rune(name)), len([]rune(candidate)))
+		score := 1 - float64(distance)/float64(longest)
+		if score < minScore {
+			continue
+		}
+
+		switch {
+		case !found || distance < best.Distance:
+			best = FuzzyMatch{Canonical: candidate, Distance: distance, Score: score}
+			found, tied = true, false
+		case distance == best.Distance && candidate != best.Canonical:
+			tied = true
+		}
+	}
+
+	return best, found && !tied
+}
+
+// DefaultPlaceholderNames are names that say nothing about the activity:
+// Strava's generic defaults for activities whose type it couldn't name.
+// Activities with an empty name are always treated as placeholders.
+var DefaultPlaceholderNames = []string{
+	"Activity", "Untitled", "Morning Activity", "Lunch Activity",
+	"Afternoon Activity", "Evening Activity", "Night Activity",
+}
+
+// DayPeriod is a part of the day, from Start (an hour, 0-23) until the
+// next period's start.
+type DayPeriod struct {
+	Name  string
+	Start int
+}
+
+// DayPeriods divides the day into named periods, ordered by start hour.
+// The last period wraps around midnight to the first.
+type DayPeriods []DayPeriod
+
+// DefaultDayStarts are the hours Strava's own time-of-day names start at.
+var DefaultDayStarts = []int{4, 11, 14, 18, 22}
+
+// DayPeriodNames holds the time-of-day words for each supported locale, in
+// the order of DefaultDayStarts.
+var DayPeriodNames = map[string][]string{
+	"en": {"Morning", "Lunch", "Afternoon", "Evening", "Night"},
+	"de": {"Morgen", "Mittag", "Nachmittag", "Abend", "Nacht"},
+	"fr": {"Matin", "Midi", "Après-midi", "Soir", "Nuit"},
+	"nl": {"Ochtend", "Lunch", "Middag", "Avond", "Nacht"},
+}
+
+// DefaultDayPeriods are the periods Strava names new activities after.
+var DefaultDayPeriods, _ = NewDayPeriods(DayPeriodNames["en"], DefaultDayStarts)
+
+// NewDayPeriods pairs names with start hours, which must be increasing
+// hours of the day.
+func NewDayPeriods(names []string, starts []int) (DayPeriods, error) {
+	if len(names) != len(starts) {
+		return nil, fmt.Errorf("%d names for %d start hours", len(names), len(starts))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no periods")
+	}
+
+	periods := make(DayPeriods, len(names))
+	for i, name := range names {
+		if starts[i] < 0 || starts[i] > 23 {
+			return nil, fmt.Errorf("start hour %d is not an hour of the day", starts[i])
+		}
+		if i > 0 && starts[i] <= starts[i-1] {
+			return nil, fmt.Errorf("start hours must increase, got %d after %d", starts[i], starts[i-1])
+		}
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("period %d has no name", i+1)
+		}
+		periods[i] = DayPeriod{Name: name, Start: starts[i]}
+	}
+	return periods, nil
+}
+
+// At names the period t falls in. Pass a local wall-clock time such as
+// Activity.StartDateLocal.
+func (p DayPeriods) At(t time.Time) string {
+	hour := t.Hour()
+	name := p[len(p)-1].Name // before the first start, it's still the last period
+	for _, period := range p {
+		if hour >= period.Start {
+			name = period.Name
+		}
+	}
+	return name
+}
+
+// Prefix returns the period word name starts with (ignoring case), if any.
+// The word must be followed by a space or end the name, so "Nightshade"
+// has no prefix.
+func (p DayPeriods) Prefix(name string) (string, bool) {
+	for _, period := range p {
+		if len(name) < len(period.Name) || !strings.EqualFold(name[:len(period.Name)], period.Name) {
+			continue
+		}
+		if rest := name[len(period.Name):]; rest == "" || rest[0] == ' ' {
+			return name[:len(period.Name)], true
+		}
+	}
+	return "", false
+}
+
+// FixName corrects the time-of-day word at the start of name for an
+// activity started at t, e.g. "Morning Run" at 19:00 becomes "Evening
+// Run". Names without one are prefixed when add is true and returned
+// unchanged otherwise.
+func (p DayPeriods) FixName(name string, t time.Time, add bool) string {
+	want := p.At(t)
+	if prefix, ok := p.Prefix(name); ok {
+		if strings.EqualFold(prefix, want) {
+			return name
+		}
+		return want + name[len(prefix):]
+	}
+	if add && strings.TrimSpace(name) != "" {
+		return want + " " + name
+	}
+	return name
+}
+
+// TimeOfDay names the part of the day t falls in, the way Strava names new
+// activities ("Morning", "Lunch", "Afternoon", "Evening" or "Night"). Pass
+// a local wall-clock time such as Activity.StartDateLocal.
+func TimeOfDay(t time.Time) string {
+	return DefaultDayPeriods.At(t)
+}
+
+// sportLabels holds the sport types whose label isn't just the type split
+// into words.
+var sportLabels = map[string]string{
+	"HighIntensityIntervalTraining": "HIIT",
+	"EBikeRide":                     "E-Bike Ride",
+	"EMountainBikeRide":             "E-Mountain Bike Ride",
+}
+
+// SportLabel returns a human-readable label for a sport type, e.g. "Trail
+// Run" for "TrailRun".
+func SportLabel(sportType string) string {
+	if label, ok := sportLabels[sportType]; ok {
+		return label
+	}
+
+	var label strings.Builder
+	for i, r := range sportType {
+		if i > 0 && unicode.IsUpper(r) {
+			label.WriteRune(' ')
+		}
+		label.WriteRune(r)
+	}
+	return label.String()
+}
+
+// DefaultName proposes a name from an activity's start time and sport
+// type, e.g. "Afternoon Ride".
+func DefaultName(activity Activity) string {
+	label := SportLabel(activity.SportType)
+	if label == "" {
+		label = "Activity"
+	}
+	return TimeOfDay(activity.StartDateLocal) + " " + label
+}
@@ -0,0 +1,55 @@
+package strava
+
+import "fmt"
+
+// polylinePrecision is the scale of the coordinates in an encoded
+// polyline: Strava, like Google, rounds them to five decimal places.
+const polylinePrecision = 1e5
+
+// DecodePolyline decodes a route in Google's encoded polyline format, as
+// used by Map.SummaryPolyline, into latitude, longitude pairs. Each pair is
+// stored as the difference from the previous one, as a variable-length
+// sequence of 5-bit chunks offset into printable ASCII. An empty polyline
+// decodes to no points; one that ends mid-value, contains characters
+// outside the format's range or encodes an implausibly large value is
+// rejected.
+func DecodePolyline(encoded string) ([][2]float64, error) {
+	var points [][2]float64
+	var lat, lng int64
+	for i := 0; i < len(encoded); {
+		var deltas [2]int64
+		for j := range deltas {
+			var result int64
+			var shift uint
+			for {
+				if i >= len(encoded) {
+					return nil, fmt.Errorf("polyline ends in the middle of a value at offset %d", i)
+				}
+				c := encoded[i]
+				if c < 63 || c > 126 {
+					return nil, fmt.Errorf("invalid character %q in polyline at offset %d", c, i)
+				}
+				// Latitudes and longitudes fit in 32 bits, i.e. 7 chunks
+				if shift > 30 {
+					return nil, fmt.Errorf("value too large in polyline at offset %d", i)
+				}
+				b := int64(c) - 63
+				i++
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[j] = ^(result >> 1)
+			} else {
+				deltas[j] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lng += deltas[1]
+		points = append(points, [2]float64{float64(lat) / polylinePrecision, float64(lng) / polylinePrecision})
+	}
+	return points, nil
+}
@@ -0,0 +1,52 @@
+package strava
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodePolyline(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    [][2]float64
+	}{
+		// The worked example from Google's polyline algorithm documentation
+		{"google example", "_p~iF~ps|U_ulLnnqC_mqNvxq`@", [][2]float64{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}},
+		// Google's example of encoding a single value, -179.9832104
+		{"negative value", "`~oia@`~oia@", [][2]float64{{-179.98321, -179.98321}}},
+		{"origin", "??", [][2]float64{{0, 0}}},
+		{"small values", "_ibE_seK", [][2]float64{{1, 2}}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePolyline(tt.encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if math.Abs(got[i][0]-tt.want[i][0]) > 1e-9 || math.Abs(got[i][1]-tt.want[i][1]) > 1e-9 {
+					t.Errorf("point %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodePolylineRejectsMalformedInput(t *testing.T) {
+	for _, encoded := range []string{
+		"_p~iF",               // a latitude without its longitude
+		"_p~iF~ps|U_ulL",      // the last pair cut short
+		"_p~i",                // ends in the middle of a value
+		"_p~iF ~ps|U",         // a character below the format's range
+		"~~~~~~~~~~~~~~~~~~?", // a value too large to be a coordinate
+	} {
+		if points, err := DecodePolyline(encoded); err == nil {
+			t.Errorf("DecodePolyline(%q) = %v, want an error", encoded, points)
+		}
+	}
+}
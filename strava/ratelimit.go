@@ -0,0 +1,268 @@
+package strava
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strava's default limits for an application, counted across every
+// request it makes.
+const (
+	DefaultShortTermLimit = 200  // requests per 15 minutes
+	DefaultDailyLimit     = 2000 // requests per day
+)
+
+// shortTermWindow is the length of Strava's short-term rate limit window.
+// Windows start on the quarter hour.
+const shortTermWindow = 15 * time.Minute
+
+// RateLimitError is returned (wrapped) when a request would exceed one of
+// the RateLimiter's windows. It wraps ErrCallBudgetExhausted, so callers
+// that stop on an exhausted budget stop on it too.
+type RateLimitError struct {
+	Window string    // "15-minute" or "daily"
+	Reset  time.Time // when the window starts over
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit reached, resets at %s", e.Window, e.Reset.Local().Format("15:04"))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrCallBudgetExhausted
+}
+
+// RateLimiter tracks requests against Strava's 15-minute and daily windows
+// so that a process stops before the API starts answering 429. It is safe
+// for concurrent use; share one between clients that draw on the same
+// application's quota.
+//
+// Usage is counted for requests made through the limiter and, once a
+// response reports it (see Observe), taken from Strava, so that requests by
+// other processes using the same application are accounted for too.
+type RateLimiter struct {
+	// ShortTermLimit and DailyLimit are the number of requests allowed per
+	// 15-minute window and per day (UTC). Zero disables that limit.
+	ShortTermLimit int
+	DailyLimit     int
+
+	// SlowdownAt is the fraction (e.g. 0.8) of either of Strava's limits
+	// past which Delay spreads the remaining requests over the rest of the
+	// window, rather than running into a 429. Zero disables slowing down.
+	SlowdownAt float64
+
+	// usage is the latest usage Strava reported, and usageAt when.
+	usage   RateLimitUsage
+	usageAt time.Time
+
+	// lastSlot is when the latest request reserved with Reserve may go.
+	lastSlot time.Time
+
+	mu         sync.Mutex
+	shortStart time.Time
+	dayStart   time.Time
+	shortUsed  int
+	dailyUsed  int
+}
+
+// NewRateLimiter returns a limiter with the given limits.
+func NewRateLimiter(shortTermLimit, dailyLimit int) *RateLimiter {
+	return &RateLimiter{
+		ShortTermLimit: shortTermLimit,
+		DailyLimit:     dailyLimit,
+	}
+}
+
+// Acquire takes one request from both windows, or returns a
+// *RateLimitError without taking anything if either is used up.
+func (l *RateLimiter) Acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.roll(time.Now())
+	if l.DailyLimit > 0 && l.dailyUsed >= l.DailyLimit {
+		return &RateLimitError{Window: "daily", Reset: l.dayStart.AddDate(0, 0, 1)}
+	}
+	if l.ShortTermLimit > 0 && l.shortUsed >= l.ShortTermLimit {
+		return &RateLimitError{Window: "15-minute", Reset: l.shortStart.Add(shortTermWindow)}
+	}
+
+	l.shortUsed++
+	l.dailyUsed++
+	return nil
+}
+
+// Remaining returns the number of requests left in the current 15-minute
+// window and day. A disabled limit reports -1.
+func (l *RateLimiter) Remaining() (shortTerm, daily int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.roll(time.Now())
+	shortTerm, daily = -1, -1
+	if l.ShortTermLimit > 0 {
+		shortTerm = l.ShortTermLimit - l.shortUsed
+	}
+	if l.DailyLimit > 0 {
+		daily = l.DailyLimit - l.dailyUsed
+	}
+	return shortTerm, daily
+}
+
+// RateLimitUsage is the usage Strava reports with every response, in the
+// X-RateLimit-Usage and X-RateLimit-Limit headers.
+type RateLimitUsage struct {
+	ShortTermUsage int
+	ShortTermLimit int
+	DailyUsage     int
+	DailyLimit     int
+}
+
+func (u RateLimitUsage) String() string {
+	return fmt.Sprintf("%d/%d in this 15-minute window, %d/%d today",
+		u.ShortTermUsage, u.ShortTermLimit, u.DailyUsage, u.DailyLimit)
+}
+
+// ParseRateLimitUsage reads the rate limit headers of a response, which
+// hold "short,daily" pairs such as "34,300". It reports false when they are
+// missing or malformed.
+func ParseRateLimitUsage(header http.Header) (RateLimitUsage, bool) {
+	pair := func(name string) (int, int, bool) {
+		short, daily, ok := strings.Cut(header.Get(name), ",")
+		if !ok {
+			return 0, 0, false
+		}
+		s, err1 := strconv.Atoi(strings.TrimSpace(short))
+		d, err2 := strconv.Atoi(strings.TrimSpace(daily))
+		return s, d, err1 == nil && err2 == nil
+	}
+
+	var usage RateLimitUsage
+	var usageOK, limitOK bool
+	usage.ShortTermUsage, usage.DailyUsage, usageOK = pair("X-RateLimit-Usage")
+	usage.ShortTermLimit, usage.DailyLimit, limitOK = pair("X-RateLimit-Limit")
+	return usage, usageOK && limitOK
+}
+
+// Observe records the usage Strava reported. Where it is higher than the
+// limiter's own count, as when other processes share the application, the
+// limiter catches up to it.
+func (l *RateLimiter) Observe(usage RateLimitUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.roll(now)
+	l.usage = usage
+	l.usageAt = now
+	l.shortUsed = max(l.shortUsed, usage.ShortTermUsage)
+	l.dailyUsed = max(l.dailyUsed, usage.DailyUsage)
+}
+
+// Delay returns how far apart requests should be sent. Once the usage
+// Strava last reported is past SlowdownAt of a limit, the requests left in
+// that window are spread evenly over the time left in it; otherwise, or
+// when the report is from an earlier window, it is zero.
+func (l *RateLimiter) Delay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.delay(time.Now())
+}
+
+// Reserve books a slot for the next request and returns how long to wait
+// for it. Slots are handed out one at a time, each at least Delay after the
+// one before, so concurrent callers are spaced out rather than all waiting
+// the same Delay and then sending their requests at once.
+func (l *RateLimiter) Reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	slot := l.lastSlot.Add(l.delay(now))
+	if slot.Before(now) {
+		slot = now
+	}
+	l.lastSlot = slot
+	return slot.Sub(now)
+}
+
+// delay is Delay at now. The caller holds l.mu.
+func (l *RateLimiter) delay(now time.Time) time.Duration {
+	if l.SlowdownAt <= 0 || l.usageAt.IsZero() {
+		return 0
+	}
+	now = now.UTC()
+	spread := func(used, limit int, reset time.Time) time.Duration {
+		if limit <= 0 || float64(used) < l.SlowdownAt*float64(limit) {
+			return 0
+		}
+		return reset.Sub(now) / time.Duration(max(limit-used, 1))
+	}
+
+	var delay time.Duration
+	shortStart := now.Truncate(shortTermWindow)
+	if !l.usageAt.Before(shortStart) {
+		delay = spread(l.usage.ShortTermUsage, l.usage.ShortTermLimit, shortStart.Add(shortTermWindow))
+	}
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !l.usageAt.Before(dayStart) {
+		delay = max(delay, spread(l.usage.DailyUsage, l.usage.DailyLimit, dayStart.AddDate(0, 0, 1)))
+	}
+	return delay
+}
+
+// roll starts new windows once now has moved past the current ones.
+func (l *RateLimiter) roll(now time.Time) {
+	now = now.UTC()
+	if shortStart := now.Truncate(shortTermWindow); !shortStart.Equal(l.shortStart) {
+		l.shortStart = shortStart
+		l.shortUsed = 0
+	}
+	if dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !dayStart.Equal(l.dayStart) {
+		l.dayStart = dayStart
+		l.dailyUsed = 0
+	}
+}
+
+// CallEstimate is how a number of requests would fit in the rate limits.
+type CallEstimate struct {
+	Calls int
+
+	// Windows is the number of 15-minute windows the requests span,
+	// counting the current one; 1 means they fit in what is left of it.
+	Windows int
+	// Finish is the earliest time the last request could be sent, when
+	// each window is used up before moving on to the next.
+	Finish time.Time
+
+	// ExceedsDaily reports whether the requests don't fit in what is left
+	// of today's limit.
+	ExceedsDaily bool
+}
+
+// Estimate works out how calls more requests would fit in the windows,
+// given the requests already made through the limiter.
+func (l *RateLimiter) Estimate(calls int) CallEstimate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.roll(now)
+	estimate := CallEstimate{Calls: calls, Windows: 1, Finish: now}
+	if l.DailyLimit > 0 && l.dailyUsed+calls > l.DailyLimit {
+		estimate.ExceedsDaily = true
+	}
+	if l.ShortTermLimit > 0 {
+		if overflow := l.shortUsed + calls - l.ShortTermLimit; overflow > 0 {
+			more := (overflow + l.ShortTermLimit - 1) / l.ShortTermLimit
+			estimate.Windows += more
+			estimate.Finish = l.shortStart.Add(time.Duration(more) * shortTermWindow)
+		}
+	}
+	return estimate
+}
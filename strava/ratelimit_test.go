@@ -0,0 +1,69 @@
+package strava
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReserveSpacesOutConcurrentRequests(t *testing.T) {
+	limiter := NewRateLimiter(200, 2000)
+	limiter.SlowdownAt = 0.8
+	limiter.Observe(RateLimitUsage{ShortTermUsage: 190, ShortTermLimit: 200, DailyUsage: 190, DailyLimit: 2000})
+
+	delay := limiter.Delay()
+	if delay < 10*time.Millisecond {
+		t.Skip("too close to the end of the 15-minute window")
+	}
+
+	// Like a wave of fetchPagesConcurrently's goroutines, all asking at once
+	const callers = 8
+	waits := make([]time.Duration, callers)
+	var wg sync.WaitGroup
+	for i := range waits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			waits[i] = limiter.Reserve()
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(waits)
+	for i := 1; i < callers; i++ {
+		if gap := waits[i] - waits[i-1]; gap < delay-time.Millisecond {
+			t.Errorf("requests %d and %d are %s apart, want at least %s (waits %v)", i-1, i, gap, delay, waits)
+		}
+	}
+}
+
+func TestReserveWithoutSlowdown(t *testing.T) {
+	limiter := NewRateLimiter(200, 2000)
+	limiter.SlowdownAt = 0.8
+	limiter.Observe(RateLimitUsage{ShortTermUsage: 10, ShortTermLimit: 200, DailyUsage: 10, DailyLimit: 2000})
+
+	for range 3 {
+		if wait := limiter.Reserve(); wait != 0 {
+			t.Fatalf("Reserve() = %s below the slowdown threshold, want 0", wait)
+		}
+	}
+}
+
+func TestReserveCountsFromThePreviousSlot(t *testing.T) {
+	limiter := NewRateLimiter(200, 2000)
+	limiter.SlowdownAt = 0.8
+	limiter.Observe(RateLimitUsage{ShortTermUsage: 10, ShortTermLimit: 200, DailyUsage: 10, DailyLimit: 2000})
+	limiter.Reserve()
+
+	// The request just reserved counts, so the first slowed down one still
+	// waits
+	limiter.Observe(RateLimitUsage{ShortTermUsage: 190, ShortTermLimit: 200, DailyUsage: 190, DailyLimit: 2000})
+	delay := limiter.Delay()
+	if delay < 10*time.Millisecond {
+		t.Skip("too close to the end of the 15-minute window")
+	}
+	if wait := limiter.Reserve(); wait < delay-time.Millisecond || wait > delay {
+		t.Errorf("Reserve() = %s, want about %s", wait, delay)
+	}
+}
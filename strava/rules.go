@@ -0,0 +1,192 @@
+package strava
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Rule is one step of a rules file: a transformation of an activity's
+// name or sport type. Rules are applied in order, each seeing the result of
+// the ones before it.
+type Rule struct {
+	// Op is the transformation:
+	//   - "trim" removes leading and trailing whitespace
+	//   - "regex" replaces matches of Pattern with Replace ($1 etc. expand)
+	//   - "rename" renames activities named exactly From to To
+	//   - "sport" changes the sport type to To, for activities whose name
+	//     matches Pattern (when given)
+	//   - "titlecase" capitalizes the first letter of each word
+	Op string `json:"op"`
+
+	// SportType, when set, limits the rule to activities of that sport
+	// type, as changed by earlier rules.
+	SportType string `json:"sport_type,omitempty"`
+
+	Pattern string `json:"pattern,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// LoadRules reads a JSON array of rules and checks that each is complete.
+func LoadRules(filename string) ([]Rule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// compile validates the rule and compiles its pattern.
+func (r *Rule) compile() error {
+	if r.SportType != "" && !IsValidSportType(r.SportType) {
+		return fmt.Errorf("unknown sport type %q", r.SportType)
+	}
+
+	switch r.Op {
+	case "trim", "titlecase":
+	case "regex":
+		if r.Pattern == "" {
+			return fmt.Errorf("regex rule needs a pattern")
+		}
+	case "rename":
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf("rename rule needs from and to")
+		}
+	case "sport":
+		if !IsValidSportType(r.To) {
+			return fmt.Errorf("unknown sport type %q", r.To)
+		}
+	default:
+		return fmt.Errorf("unknown op %q, expected trim, regex, rename, sport or titlecase", r.Op)
+	}
+
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// Label is how the rule, at index i of its rules file, is listed in
+// RuleResult.Fired.
+func (r *Rule) Label(i int) string {
+	return fmt.Sprintf("rule %d (%s)", i+1, r.Op)
+}
+
+// Description says what the rule does, for summaries of a run.
+func (r *Rule) Description() string {
+	var description string
+	switch r.Op {
+	case "trim":
+		description = "Trimmed whitespace"
+	case "regex":
+		description = fmt.Sprintf("Replaced /%s/ with %q", r.Pattern, r.Replace)
+	case "rename":
+		description = fmt.Sprintf("Renamed %q to %q", r.From, r.To)
+	case "sport":
+		description = "Sport type changed to " + r.To
+		if r.Pattern != "" {
+			description += fmt.Sprintf(" for names matching /%s/", r.Pattern)
+		}
+	case "titlecase":
+		description = "Capitalized words"
+	}
+	if r.SportType != "" {
+		description += fmt.Sprintf(" (%s only)", r.SportType)
+	}
+	return description
+}
+
+// apply returns the activity's name and sport type after the rule, and
+// whether it changed either.
+func (r *Rule) apply(name, sportType string) (string, string, bool) {
+	if r.SportType != "" && r.SportType != sportType {
+		return name, sportType, false
+	}
+
+	newName, newSportType := name, sportType
+	switch r.Op {
+	case "trim":
+		newName = strings.TrimSpace(name)
+	case "regex":
+		newName = r.re.ReplaceAllString(name, r.Replace)
+	case "rename":
+		if name == r.From {
+			newName = r.To
+		}
+	case "sport":
+		if r.re == nil || r.re.MatchString(name) {
+			newSportType = r.To
+		}
+	case "titlecase":
+		newName = titleCase(name)
+	}
+	return newName, newSportType, newName != name || newSportType != sportType
+}
+
+// titleCase capitalizes the first letter of each word, leaving the rest
+// alone so that acronyms like "HIIT" survive.
+func titleCase(s string) string {
+	var b strings.Builder
+	atWordStart := true
+	for _, r := range s {
+		if atWordStart && unicode.IsLetter(r) {
+			r = unicode.ToUpper(r)
+		}
+		atWordStart = unicode.IsSpace(r)
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// RuleResult is what a set of rules would do to one activity.
+type RuleResult struct {
+	Activity Activity
+	Update   ActivityUpdate // empty when nothing changed
+	Fired    []string       // "rule N (op)" for each rule that changed something
+}
+
+// ApplyRules runs rules over activity in order and combines everything they
+// change into a single update, so the activity needs one API call however
+// many rules fire. A name that the rules would leave empty is not changed,
+// since the API rejects it.
+func ApplyRules(activity Activity, rules []Rule) RuleResult {
+	result := RuleResult{Activity: activity}
+
+	name, sportType := activity.Name, activity.SportType
+	for i := range rules {
+		var changed bool
+		name, sportType, changed = rules[i].apply(name, sportType)
+		if changed {
+			result.Fired = append(result.Fired, rules[i].Label(i))
+		}
+	}
+
+	if name != activity.Name && strings.TrimSpace(name) != "" {
+		result.Update.Name = name
+	}
+	if sportType != activity.SportType {
+		result.Update.SportType = sportType
+	}
+	return result
+}
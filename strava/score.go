@@ -0,0 +1,93 @@
+package strava
+
+import "strings"
+
+// NamingScore measures how consistently activities are named. Each count
+// is a number of activities; an activity can have several problems but is
+// only counted once in Messy.
+type NamingScore struct {
+	Total int
+
+	Whitespace   int // leading or trailing spaces
+	Unicode      int // changed by UnicodeTransforms, e.g. mojibake or curly quotes
+	Placeholders int // empty or one of DefaultPlaceholderNames
+
+	// CaseGroups and Clusters are the groups of names that differ only by
+	// case, or are within a few edits of each other (see ClusterNames).
+	// The counts are of activities using a name other than the group's
+	// canonical one.
+	CaseGroups   []CaseGroup
+	CaseVariants int
+	Clusters     []NameCluster
+	Variants     int
+
+	Messy int
+}
+
+// Score is the percentage of activities without any naming problem.
+func (s NamingScore) Score() float64 {
+	if s.Total == 0 {
+		return 100
+	}
+	return 100 * float64(s.Total-s.Messy) / float64(s.Total)
+}
+
+// ScoreNaming checks every activity name for the problems the cleaner and
+// renamer fix. clusterDistance is passed to ClusterNames, and
+// caseExceptions to GroupByCase.
+func ScoreNaming(activities []Activity, clusterDistance int, caseExceptions []string) NamingScore {
+	score := NamingScore{Total: len(activities)}
+
+	nameCounts := make(map[string]int)
+	for _, activity := range activities {
+		nameCounts[activity.Name]++
+	}
+
+	placeholders := make(map[string]bool)
+	for _, name := range DefaultPlaceholderNames {
+		placeholders[strings.ToLower(name)] = true
+	}
+
+	// Names that aren't the canonical one of their case group or cluster
+	variant := make(map[string]bool)
+	score.CaseGroups = GroupByCase(nameCounts, caseExceptions)
+	for _, group := range score.CaseGroups {
+		for name, count := range group.Counts {
+			if name != group.Canonical {
+				score.CaseVariants += count
+				variant[name] = true
+			}
+		}
+	}
+	score.Clusters = ClusterNames(nameCounts, clusterDistance)
+	for _, cluster := range score.Clusters {
+		for _, name := range cluster.Variants() {
+			score.Variants += cluster.Counts[name]
+			variant[name] = true
+		}
+	}
+
+	for name, count := range nameCounts {
+		messy := variant[name]
+		if name != strings.TrimSpace(name) {
+			score.Whitespace += count
+			messy = true
+		}
+		for _, transform := range UnicodeTransforms {
+			if transform.Apply(name) != name {
+				score.Unicode += count
+				messy = true
+				break
+			}
+		}
+		if trimmed := strings.TrimSpace(name); trimmed == "" || placeholders[strings.ToLower(trimmed)] {
+			score.Placeholders += count
+			messy = true
+		}
+		if messy {
+			score.Messy += count
+		}
+	}
+
+	return score
+}
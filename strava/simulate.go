@@ -0,0 +1,83 @@
+package strava
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenamePass is one step of an offline rename simulation, such as applying
+// a mappings file or trimming whitespace.
+type RenamePass struct {
+	// Name identifies the pass in results, e.g. the mappings file it was
+	// loaded from.
+	Name string
+
+	// Rename returns the new name for an activity of sportType currently
+	// named name, and a description of the rule that fired, or ok=false
+	// when the pass leaves the name alone.
+	Rename func(sportType, name string) (newName, rule string, ok bool)
+}
+
+// MappingPass renames names that exactly match a rule in mappings, like
+// the renamer does.
+func MappingPass(name string, mappings NameMappings) RenamePass {
+	return RenamePass{
+		Name: name,
+		Rename: func(sportType, current string) (string, string, bool) {
+			to, ok := mappings.Lookup(sportType, current)
+			if !ok || to == current {
+				return current, "", false
+			}
+			if _, scoped := mappings.BySport[sportType][current]; scoped {
+				return to, fmt.Sprintf("%s '%s' -> '%s'", sportType, current, to), true
+			}
+			return to, fmt.Sprintf("'%s' -> '%s'", current, to), true
+		},
+	}
+}
+
+// TrimPass removes leading and trailing whitespace, like the cleaner does.
+func TrimPass() RenamePass {
+	return RenamePass{
+		Name: "trim",
+		Rename: func(_, current string) (string, string, bool) {
+			trimmed := strings.TrimSpace(current)
+			if trimmed == current {
+				return current, "", false
+			}
+			return trimmed, "trim whitespace", true
+		},
+	}
+}
+
+// SimulationResult is what a rename simulation did to one activity.
+type SimulationResult struct {
+	Activity  Activity // as it was before the simulation
+	FinalName string
+	Fired     []string // "pass: rule" for each rule that fired, in order
+}
+
+// Changed reports whether the simulation changed the activity's name.
+func (r SimulationResult) Changed() bool {
+	return r.FinalName != r.Activity.Name
+}
+
+// SimulateRenames applies passes in order to the name of each activity,
+// each pass seeing the result of the previous ones, and reports the final
+// names and the rules that fired. Nothing is sent to the API.
+func SimulateRenames(activities []Activity, passes []RenamePass) []SimulationResult {
+	results := make([]SimulationResult, len(activities))
+	for i, activity := range activities {
+		result := SimulationResult{Activity: activity, FinalName: activity.Name}
+		for _, pass := range passes {
+			newName, rule, ok := pass.Rename(activity.SportType, result.FinalName)
+			if !ok {
+				continue
+			}
+			result.FinalName = newName
+			result.Fired = append(result.Fired, pass.Name+": "+rule)
+		}
+		results[i] = result
+	}
+	return results
+}
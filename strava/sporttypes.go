@@ -0,0 +1,146 @@
+package strava
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SportTypes lists the sport_type values accepted by the Strava API. It
+// starts as the embedded sporttypes.txt and can be extended with
+// LoadSportTypes when Strava adds types before a release catches up.
+var SportTypes = parseSportTypes(embeddedSportTypes)
+
+//go:embed sporttypes.txt
+var embeddedSportTypes string
+
+// parseSportTypes returns the types listed one per line in data, ignoring
+// blank lines and # comments.
+func parseSportTypes(data string) []string {
+	var sportTypes []string
+	for _, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			sportTypes = append(sportTypes, line)
+		}
+	}
+	return sportTypes
+}
+
+// LoadSportTypes changes SportTypes by the file's lines: a type on its own
+// line is added, and a type prefixed with "-" is removed. Blank lines and
+// # comments are ignored. It is meant to be called once at startup, before
+// any validation.
+func LoadSportTypes(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, sportType := range SportTypes {
+		known[sportType] = true
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sportType, remove := strings.CutPrefix(line, "-")
+		if strings.ContainsAny(sportType, " \t,") || sportType == "" {
+			return fmt.Errorf("line %d: invalid sport type %q", i+1, line)
+		}
+		known[sportType] = !remove
+	}
+
+	SportTypes = SportTypes[:0:0]
+	for sportType, ok := range known {
+		if ok {
+			SportTypes = append(SportTypes, sportType)
+		}
+	}
+	sort.Strings(SportTypes)
+	return nil
+}
+
+// UnknownSportTypes returns the sport types of activities that are not in
+// SportTypes, sorted, so new types Strava has introduced can be noticed.
+func UnknownSportTypes(activities []Activity) []string {
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, activity := range activities {
+		if activity.SportType == "" || seen[activity.SportType] {
+			continue
+		}
+		seen[activity.SportType] = true
+		if !IsValidSportType(activity.SportType) {
+			unknown = append(unknown, activity.SportType)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// IsValidSportType reports whether sportType is one of SportTypes.
+func IsValidSportType(sportType string) bool {
+	for _, known := range SportTypes {
+		if known == sportType {
+			return true
+		}
+	}
+	return false
+}
+
+// ActivityTypes lists the values of the legacy type field, which predates
+// sport_type and is still read by some third-party clients.
+var ActivityTypes = []string{
+	"AlpineSki", "BackcountrySki", "Canoeing", "Crossfit", "EBikeRide",
+	"Elliptical", "Golf", "Handcycle", "Hike", "IceSkate", "InlineSkate",
+	"Kayaking", "Kitesurf", "NordicSki", "Ride", "RockClimbing", "RollerSki",
+	"Rowing", "Run", "Sail", "Skateboard", "Snowboard", "Snowshoe", "Soccer",
+	"StairStepper", "StandUpPaddling", "Surfing", "Swim", "Velomobile",
+	"VirtualRide", "VirtualRun", "Walk", "WeightTraining", "Wheelchair",
+	"Windsurf", "Workout", "Yoga",
+}
+
+// legacyTypes maps the sport types that have no legacy type of the same
+// name to the closest one.
+var legacyTypes = map[string]string{
+	"EMountainBikeRide":             "EBikeRide",
+	"GravelRide":                    "Ride",
+	"MountainBikeRide":              "Ride",
+	"TrailRun":                      "Run",
+	"VirtualRow":                    "Rowing",
+	"Badminton":                     "Workout",
+	"HighIntensityIntervalTraining": "Workout",
+	"Pickleball":                    "Workout",
+	"Pilates":                       "Workout",
+	"Racquetball":                   "Workout",
+	"Squash":                        "Workout",
+	"TableTennis":                   "Workout",
+	"Tennis":                        "Workout",
+}
+
+// LegacyType returns the legacy type corresponding to sportType, or "" if
+// sportType is not a known sport type.
+func LegacyType(sportType string) string {
+	if legacy, ok := legacyTypes[sportType]; ok {
+		return legacy
+	}
+	if IsValidActivityType(sportType) {
+		return sportType
+	}
+	return ""
+}
+
+// IsValidActivityType reports whether activityType is one of ActivityTypes.
+func IsValidActivityType(activityType string) bool {
+	for _, known := range ActivityTypes {
+		if known == activityType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package strava
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLiteSchema creates the table WriteActivitiesSQLite fills, one row per
+// activity keyed by its Strava ID. Times are ISO 8601 text, as SQLite's
+// date functions expect (start_date in UTC, start_date_local as shown on
+// Strava), and booleans are 0 or 1. Distances and elevations are meters,
+// times seconds and speeds meters per second, as returned by the API;
+// workout_type and perceived_exertion are NULL when unset, and the sensor
+// averages are 0 without such data. For example:
+//
+//	SELECT strftime('%Y', start_date_local) AS year, sport_type,
+//	       COUNT(*), ROUND(SUM(distance) / 1000) AS km
+//	FROM activities GROUP BY year, sport_type ORDER BY year;
+const SQLiteSchema = `CREATE TABLE IF NOT EXISTS activities (
+	id                   INTEGER PRIMARY KEY,
+	name                 TEXT NOT NULL,
+	sport_type           TEXT NOT NULL,
+	start_date           TEXT NOT NULL,
+	start_date_local     TEXT NOT NULL,
+	description          TEXT NOT NULL,
+	distance             REAL NOT NULL,
+	moving_time          INTEGER NOT NULL,
+	total_elevation_gain REAL NOT NULL,
+	elev_high            REAL NOT NULL,
+	elev_low             REAL NOT NULL,
+	average_speed        REAL NOT NULL,
+	average_heartrate    REAL NOT NULL,
+	max_heartrate        REAL NOT NULL,
+	average_watts        REAL NOT NULL,
+	kudos_count          INTEGER NOT NULL,
+	comment_count        INTEGER NOT NULL,
+	photo_count          INTEGER NOT NULL,
+	trainer              INTEGER NOT NULL,
+	commute              INTEGER NOT NULL,
+	manual               INTEGER NOT NULL,
+	hide_from_home       INTEGER NOT NULL,
+	visibility           TEXT NOT NULL,
+	gear_id              TEXT NOT NULL,
+	workout_type         INTEGER,
+	perceived_exertion   REAL,
+	summary_polyline     TEXT NOT NULL
+)`
+
+// sqliteColumns are the columns of SQLiteSchema, in the order
+// sqliteValues returns them.
+var sqliteColumns = []string{
+	"id", "name", "sport_type", "start_date", "start_date_local", "description",
+	"distance", "moving_time", "total_elevation_gain", "elev_high", "elev_low",
+	"average_speed", "average_heartrate", "max_heartrate", "average_watts",
+	"kudos_count", "comment_count", "photo_count",
+	"trainer", "commute", "manual", "hide_from_home", "visibility", "gear_id",
+	"workout_type", "perceived_exertion", "summary_polyline",
+}
+
+func sqliteValues(a Activity) []any {
+	var workoutType, perceivedExertion any
+	if a.WorkoutType != nil {
+		workoutType = *a.WorkoutType
+	}
+	if a.PerceivedExertion != nil {
+		perceivedExertion = *a.PerceivedExertion
+	}
+	return []any{
+		a.ID, a.Name, a.SportType,
+		a.StartDate.UTC().Format(time.RFC3339),
+		// Strava sends the local time with a Z suffix; keep just the
+		// wall-clock time so it isn't mistaken for UTC
+		a.StartDateLocal.Format("2006-01-02T15:04:05"),
+		a.Description,
+		a.Distance, a.MovingTime, a.TotalElevationGain, a.ElevHigh, a.ElevLow,
+		a.AverageSpeed, a.AverageHeartrate, a.MaxHeartrate, a.AverageWatts,
+		a.KudosCount, a.CommentCount, a.TotalPhotoCount,
+		sqliteBool(a.Trainer), sqliteBool(a.Commute), sqliteBool(a.Manual), sqliteBool(a.HideFromHome),
+		a.ActivityVisibility(), a.GearID,
+		workoutType, perceivedExertion, a.Map.SummaryPolyline,
+	}
+}
+
+func sqliteBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WriteActivitiesSQLite creates the activities table in db if it doesn't
+// exist (see SQLiteSchema) and inserts activities into it, replacing the
+// rows of those already there, so exporting again brings the database up
+// to date. Rows of activities not in the list are kept. It only uses
+// database/sql; the caller opens db with a SQLite driver.
+func WriteActivitiesSQLite(db *sql.DB, activities []Activity) error {
+	if _, err := db.Exec(SQLiteSchema); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(sqliteColumns)), ", ")
+	var updates []string
+	for _, column := range sqliteColumns[1:] {
+		updates = append(updates, column+" = excluded."+column)
+	}
+	upsert := fmt.Sprintf("INSERT INTO activities (%s) VALUES (%s) ON CONFLICT(id) DO UPDATE SET %s",
+		strings.Join(sqliteColumns, ", "), placeholders, strings.Join(updates, ", "))
+
+	// One transaction, so an interrupted export leaves the database as it
+	// was, and so SQLite doesn't sync to disk after every row
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(upsert)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, activity := range activities {
+		if _, err := stmt.Exec(sqliteValues(activity)...); err != nil {
+			return fmt.Errorf("failed to write activity %d: %w", activity.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
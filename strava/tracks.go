@@ -0,0 +1,186 @@
+package strava
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TrackStreamTypes are the streams WriteGPX and WriteTCX use.
+var TrackStreamTypes = []string{"time", "latlng", "altitude", "heartrate", "distance"}
+
+// ErrNoPositions is returned by WriteGPX for an activity recorded without
+// GPS, which GPX can't represent; use WriteTCX instead.
+var ErrNoPositions = errors.New("activity has no GPS positions")
+
+type gpxFile struct {
+	XMLName  xml.Name    `xml:"gpx"`
+	Version  string      `xml:"version,attr"`
+	Creator  string      `xml:"creator,attr"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	XMLNSTPX string      `xml:"xmlns:gpxtpx,attr"`
+	Metadata gpxMetadata `xml:"metadata"`
+	Track    gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Type    string     `xml:"type"`
+	Segment []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+type gpxPoint struct {
+	Lat        float64        `xml:"lat,attr"`
+	Lon        float64        `xml:"lon,attr"`
+	Elevation  *float64       `xml:"ele,omitempty"`
+	Time       string         `xml:"time,omitempty"`
+	Extensions *gpxExtensions `xml:"extensions,omitempty"`
+}
+
+type gpxExtensions struct {
+	Heartrate int `xml:"gpxtpx:TrackPointExtension>gpxtpx:hr"`
+}
+
+// WriteGPX writes an activity's track to w as GPX 1.1, with elevation and
+// heart rate (as a Garmin TrackPointExtension) where they were recorded.
+func WriteGPX(w io.Writer, activity Activity, streams *Streams) error {
+	if len(streams.LatLng) == 0 {
+		return ErrNoPositions
+	}
+
+	file := gpxFile{
+		Version:  "1.1",
+		Creator:  "strava-activity-updater",
+		XMLNS:    "http://www.topografix.com/GPX/1/1",
+		XMLNSTPX: "http://www.garmin.com/xmlschemas/TrackPointExtension/v1",
+		Metadata: gpxMetadata{Name: activity.Name, Time: activity.StartDate.UTC().Format(time.RFC3339)},
+		Track:    gpxTrack{Name: activity.Name, Type: activity.SportType},
+	}
+	for i, latlng := range streams.LatLng {
+		point := gpxPoint{Lat: latlng[0], Lon: latlng[1], Time: sampleTime(activity, streams, i)}
+		if i < len(streams.Altitude) {
+			point.Elevation = &streams.Altitude[i]
+		}
+		if i < len(streams.Heartrate) {
+			point.Extensions = &gpxExtensions{Heartrate: streams.Heartrate[i]}
+		}
+		file.Track.Segment = append(file.Track.Segment, point)
+	}
+	return writeXML(w, file)
+}
+
+type tcxFile struct {
+	XMLName    xml.Name      `xml:"TrainingCenterDatabase"`
+	XMLNS      string        `xml:"xmlns,attr"`
+	Activities []tcxActivity `xml:"Activities>Activity"`
+}
+
+type tcxActivity struct {
+	Sport string `xml:"Sport,attr"`
+	ID    string `xml:"Id"`
+	Lap   tcxLap `xml:"Lap"`
+	Notes string `xml:"Notes,omitempty"`
+}
+
+// tcxLap has its elements in the order the schema requires.
+type tcxLap struct {
+	StartTime        string     `xml:"StartTime,attr"`
+	TotalTimeSeconds float64    `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64    `xml:"DistanceMeters"`
+	Calories         int        `xml:"Calories"`
+	Intensity        string     `xml:"Intensity"`
+	TriggerMethod    string     `xml:"TriggerMethod"`
+	Track            []tcxPoint `xml:"Track>Trackpoint"`
+}
+
+type tcxPoint struct {
+	Time           string       `xml:"Time"`
+	Position       *tcxPosition `xml:"Position,omitempty"`
+	AltitudeMeters *float64     `xml:"AltitudeMeters,omitempty"`
+	DistanceMeters *float64     `xml:"DistanceMeters,omitempty"`
+	Heartrate      *int         `xml:"HeartRateBpm>Value,omitempty"`
+}
+
+type tcxPosition struct {
+	Latitude  float64 `xml:"LatitudeDegrees"`
+	Longitude float64 `xml:"LongitudeDegrees"`
+}
+
+// tcxSports maps legacy types to TCX's sports; everything else is Other.
+var tcxSports = map[string]string{
+	"Run":  "Running",
+	"Ride": "Biking",
+}
+
+// WriteTCX writes an activity to w as a single-lap TCX file. Unlike GPX it
+// doesn't need positions, so indoor activities are written with just
+// their times, distances and heart rate.
+func WriteTCX(w io.Writer, activity Activity, streams *Streams) error {
+	sport, ok := tcxSports[LegacyType(activity.SportType)]
+	if !ok {
+		sport = "Other"
+	}
+
+	start := activity.StartDate.UTC().Format(time.RFC3339)
+	lap := tcxLap{
+		StartTime:        start,
+		TotalTimeSeconds: float64(activity.MovingTime),
+		DistanceMeters:   activity.Distance,
+		Intensity:        "Active",
+		TriggerMethod:    "Manual",
+	}
+	if n := len(streams.Time); n > 0 {
+		lap.TotalTimeSeconds = float64(streams.Time[n-1])
+	}
+	for i := range streams.Time {
+		point := tcxPoint{Time: sampleTime(activity, streams, i)}
+		if i < len(streams.LatLng) {
+			point.Position = &tcxPosition{Latitude: streams.LatLng[i][0], Longitude: streams.LatLng[i][1]}
+		}
+		if i < len(streams.Altitude) {
+			point.AltitudeMeters = &streams.Altitude[i]
+		}
+		if i < len(streams.Distance) {
+			point.DistanceMeters = &streams.Distance[i]
+		}
+		if i < len(streams.Heartrate) {
+			point.Heartrate = &streams.Heartrate[i]
+		}
+		lap.Track = append(lap.Track, point)
+	}
+
+	file := tcxFile{
+		XMLNS:      "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2",
+		Activities: []tcxActivity{{Sport: sport, ID: start, Lap: lap, Notes: activity.Name}},
+	}
+	return writeXML(w, file)
+}
+
+// sampleTime returns the time of sample i, or "" without a time stream.
+func sampleTime(activity Activity, streams *Streams, i int) string {
+	if i >= len(streams.Time) {
+		return ""
+	}
+	t := activity.StartDate.Add(time.Duration(streams.Time[i]) * time.Second)
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode track: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
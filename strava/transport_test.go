@@ -0,0 +1,56 @@
+package strava
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// BenchmarkFetchActivity compares concurrent requests over a new TLS
+// connection each time, over http.DefaultTransport's settings (two idle
+// connections per host) and over the shared Transport.
+func BenchmarkFetchActivity(b *testing.B) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 42, "name": "Morning Run"}`))
+	}))
+	defer server.Close()
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	transports := []struct {
+		name      string
+		transport func() *http.Transport
+	}{
+		{"new-connections", func() *http.Transport {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.DisableKeepAlives = true
+			return t
+		}},
+		{"default-transport", func() *http.Transport {
+			return http.DefaultTransport.(*http.Transport).Clone()
+		}},
+		{"shared-transport", newTransport},
+	}
+	for _, tt := range transports {
+		b.Run(tt.name, func(b *testing.B) {
+			transport := tt.transport()
+			transport.TLSClientConfig = tlsConfig
+			defer transport.CloseIdleConnections()
+
+			client := NewClient()
+			client.BaseURL = server.URL
+			client.HTTPClient = &http.Client{Transport: transport}
+			client.RateLimiter = nil
+
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := client.GetActivityByID("access", 42); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
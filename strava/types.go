@@ -1,17 +1,336 @@
 package strava
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
 
+// Activity is an activity as returned by the API. It has two start times:
+// StartDate is the exact instant (UTC), for sorting and comparing
+// activities, while StartDateLocal is the wall-clock time where the
+// activity took place, for anything shown to the athlete or reasoned about
+// as a time of day. Strava encodes StartDateLocal with a "Z" suffix even
+// though it isn't UTC, so only ever read its date and clock fields.
 type Activity struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	SportType   string    `json:"sport_type"`
-	StartDate   time.Time `json:"start_date"`
-	Description string    `json:"description"`
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	SportType          string    `json:"sport_type"`
+	Type               string    `json:"type"` // legacy, see ActivityTypes
+	StartDate          time.Time `json:"start_date"`
+	StartDateLocal     time.Time `json:"start_date_local"`
+	Description        string    `json:"description"`
+	KudosCount         int       `json:"kudos_count"`
+	CommentCount       int       `json:"comment_count"`
+	Distance           float64   `json:"distance"`             // meters
+	TotalElevationGain float64   `json:"total_elevation_gain"` // meters
+	ElevHigh           float64   `json:"elev_high"`            // meters, zero without elevation data
+	ElevLow            float64   `json:"elev_low"`             // meters
+	MovingTime         int       `json:"moving_time"`          // seconds
+	Trainer            bool      `json:"trainer"`              // recorded on an indoor trainer
+	Commute            bool      `json:"commute"`
+	Manual             bool      `json:"manual"`       // entered by hand rather than recorded
+	PrivateNote        string    `json:"private_note"` // only in the detailed representation
+	TotalPhotoCount    int       `json:"total_photo_count"`
+	HideFromHome       bool      `json:"hide_from_home"`     // muted from followers' feeds
+	GearID             string    `json:"gear_id"`            // shoe or bike, empty if none
+	WorkoutType        *int      `json:"workout_type"`       // race, long run, ...; see WorkoutType
+	PerceivedExertion  *float64  `json:"perceived_exertion"` // 1 to 10, only in the detailed representation
+	Private            bool      `json:"private"`            // only visible to the athlete
+	Visibility         string    `json:"visibility"`         // see Visibilities; empty in older payloads
+	Athlete            Athlete   `json:"athlete"`            // the owner; only the ID is set
+	Map                Map       `json:"map"`
+
+	// Sensor averages, zero when the activity has no such data
+	AverageSpeed     float64 `json:"average_speed"` // meters per second
+	AverageHeartrate float64 `json:"average_heartrate"`
+	MaxHeartrate     float64 `json:"max_heartrate"`
+	AverageWatts     float64 `json:"average_watts"`
+}
+
+// Map is an activity's route. SummaryPolyline is a simplified version of
+// the route in Google's encoded polyline format; it is empty for activities
+// without GPS, such as manual entries and indoor workouts.
+type Map struct {
+	ID              string `json:"id"`
+	SummaryPolyline string `json:"summary_polyline"`
+}
+
+// Streams are an activity's recorded samples, as returned by
+// GetActivityStreams. The slices that are present have one entry per
+// sample; the others are empty.
+type Streams struct {
+	Time      []int        // seconds since the start
+	LatLng    [][2]float64 // latitude, longitude
+	Altitude  []float64    // meters
+	Heartrate []int        // bpm
+	Distance  []float64    // meters from the start
+}
+
+// Visibilities lists the values of Activity.Visibility, from most to least
+// visible.
+var Visibilities = []string{"everyone", "followers_only", "only_me"}
+
+// IsValidVisibility reports whether visibility is one of Visibilities.
+func IsValidVisibility(visibility string) bool {
+	for _, known := range Visibilities {
+		if known == visibility {
+			return true
+		}
+	}
+	return false
+}
+
+// ActivityVisibility returns who can see the activity. Payloads without a
+// visibility field only say whether the activity is private.
+func (a Activity) ActivityVisibility() string {
+	if a.Visibility != "" {
+		return a.Visibility
+	}
+	if a.Private {
+		return "only_me"
+	}
+	return "everyone"
 }
 
 type ActivityUpdate struct {
 	Name        string `json:"name,omitempty"`
 	SportType   string `json:"sport_type,omitempty"`
 	Description string `json:"description,omitempty"`
+
+	// Type is the legacy activity type. Set it alongside SportType (see
+	// LegacyType) for clients that still read the old field.
+	Type string `json:"type,omitempty"`
+
+	// StartDateLocal is the wall-clock start time in the activity's own
+	// time zone. Strava encodes it with a "Z" suffix even though it isn't
+	// UTC, so derive it from Activity.StartDateLocal rather than StartDate.
+	StartDateLocal time.Time `json:"start_date_local,omitzero"`
+
+	// PrivateNote is a pointer so that an empty note ("clear it") can be
+	// told apart from leaving the note unchanged.
+	PrivateNote *string `json:"private_note,omitempty"`
+
+	// HideFromHome mutes the activity from followers' home feeds. Like
+	// PrivateNote it is a pointer, so that false (unmute) can be sent.
+	HideFromHome *bool `json:"hide_from_home,omitempty"`
+
+	// Commute and Trainer set the commute and indoor trainer flags. They
+	// are pointers like HideFromHome, so that false can be sent.
+	Commute *bool `json:"commute,omitempty"`
+	Trainer *bool `json:"trainer,omitempty"`
+
+	// Visibility is who can see the activity, one of Visibilities. It
+	// isn't among the fields Strava documents for updates, so check a few
+	// activities on strava.com before relying on it for a large batch.
+	Visibility string `json:"visibility,omitempty"`
+
+	// WorkoutType is a pointer because 0 (the default for runs) is a
+	// value that can be sent. Resolve it with WorkoutType, since the
+	// allowed values depend on the sport.
+	WorkoutType *int `json:"workout_type,omitempty"`
+
+	// PerceivedExertion is the athlete's rating of the effort, from
+	// MinPerceivedExertion (easy) to MaxPerceivedExertion (max effort).
+	PerceivedExertion *int `json:"perceived_exertion,omitempty"`
+}
+
+// The range of perceived exertion ratings the API accepts.
+const (
+	MinPerceivedExertion = 1
+	MaxPerceivedExertion = 10
+)
+
+// IsNoop reports whether applying the update to current would leave it
+// unchanged, i.e. every field the update sets already has that value.
+func (u ActivityUpdate) IsNoop(current Activity) bool {
+	if u.Name != "" && u.Name != current.Name {
+		return false
+	}
+	if u.SportType != "" && u.SportType != current.SportType {
+		return false
+	}
+	if u.Type != "" && u.Type != current.Type {
+		return false
+	}
+	if u.Description != "" && u.Description != current.Description {
+		return false
+	}
+	if !u.StartDateLocal.IsZero() && !u.StartDateLocal.Equal(current.StartDateLocal) {
+		return false
+	}
+	if u.PrivateNote != nil && *u.PrivateNote != current.PrivateNote {
+		return false
+	}
+	if u.HideFromHome != nil && *u.HideFromHome != current.HideFromHome {
+		return false
+	}
+	if u.Commute != nil && *u.Commute != current.Commute {
+		return false
+	}
+	if u.Trainer != nil && *u.Trainer != current.Trainer {
+		return false
+	}
+	if u.Visibility != "" && u.Visibility != current.ActivityVisibility() {
+		return false
+	}
+	if u.WorkoutType != nil && (current.WorkoutType == nil || *u.WorkoutType != *current.WorkoutType) {
+		return false
+	}
+	if u.PerceivedExertion != nil && (current.PerceivedExertion == nil || float64(*u.PerceivedExertion) != *current.PerceivedExertion) {
+		return false
+	}
+	return true
+}
+
+// BuildUpdate returns the update that turns current into desired, with only
+// the fields that differ set, and whether there are any. The API can't
+// clear a name, sport type, legacy type or description, so an empty value
+// in desired leaves those alone; an empty private note clears it, so
+// compare detailed representations (see GetActivityByID), which are the
+// only ones with the note.
+func BuildUpdate(current, desired Activity) (ActivityUpdate, bool) {
+	var update ActivityUpdate
+	if desired.Name != "" && desired.Name != current.Name {
+		update.Name = desired.Name
+	}
+	if desired.SportType != "" && desired.SportType != current.SportType {
+		update.SportType = desired.SportType
+	}
+	if desired.Type != "" && desired.Type != current.Type {
+		update.Type = desired.Type
+	}
+	if desired.Description != "" && desired.Description != current.Description {
+		update.Description = desired.Description
+	}
+	if !desired.StartDateLocal.IsZero() && !desired.StartDateLocal.Equal(current.StartDateLocal) {
+		update.StartDateLocal = desired.StartDateLocal
+	}
+	if desired.PrivateNote != current.PrivateNote {
+		update.PrivateNote = &desired.PrivateNote
+	}
+	if desired.HideFromHome != current.HideFromHome {
+		update.HideFromHome = &desired.HideFromHome
+	}
+	if desired.Commute != current.Commute {
+		update.Commute = &desired.Commute
+	}
+	if desired.Trainer != current.Trainer {
+		update.Trainer = &desired.Trainer
+	}
+	if desired.Visibility != "" && desired.ActivityVisibility() != current.ActivityVisibility() {
+		update.Visibility = desired.ActivityVisibility()
+	}
+	if desired.WorkoutType != nil && (current.WorkoutType == nil || *desired.WorkoutType != *current.WorkoutType) {
+		update.WorkoutType = desired.WorkoutType
+	}
+	if desired.PerceivedExertion != nil && (current.PerceivedExertion == nil || *desired.PerceivedExertion != *current.PerceivedExertion) {
+		perceivedExertion := int(math.Round(*desired.PerceivedExertion))
+		update.PerceivedExertion = &perceivedExertion
+	}
+	return update, update != (ActivityUpdate{})
+}
+
+// Validate checks that the sport type, legacy type and perceived exertion,
+// when set, are values the API accepts.
+func (u ActivityUpdate) Validate() error {
+	if u.SportType != "" && !IsValidSportType(u.SportType) {
+		return fmt.Errorf("unknown sport type %q", u.SportType)
+	}
+	if u.Type != "" && !IsValidActivityType(u.Type) {
+		return fmt.Errorf("unknown activity type %q", u.Type)
+	}
+	if u.Visibility != "" && !IsValidVisibility(u.Visibility) {
+		return fmt.Errorf("unknown visibility %q", u.Visibility)
+	}
+	if u.PerceivedExertion != nil && (*u.PerceivedExertion < MinPerceivedExertion || *u.PerceivedExertion > MaxPerceivedExertion) {
+		return fmt.Errorf("perceived exertion %d is out of range, expected %d to %d", *u.PerceivedExertion, MinPerceivedExertion, MaxPerceivedExertion)
+	}
+	return nil
+}
+
+// Gear is a bike or pair of shoes, referenced by Activity.GearID.
+type Gear struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Distance float64 `json:"distance"` // meters, as tracked by Strava
+	Retired  bool    `json:"retired"`
+}
+
+// ZoneRange is one zone, in bpm. The top zone has a Max of -1.
+type ZoneRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// HeartRateZones are the athlete's heart rate zones, lowest first.
+type HeartRateZones struct {
+	CustomZones bool        `json:"custom_zones"` // set by the athlete rather than derived from age
+	Zones       []ZoneRange `json:"zones"`
+}
+
+// Zone returns the 1-based zone that heartrate falls in, or 0 if it is
+// below every zone or there are no zones.
+func (z HeartRateZones) Zone(heartrate float64) int {
+	for i := len(z.Zones) - 1; i >= 0; i-- {
+		if heartrate >= float64(z.Zones[i].Min) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Comment is a comment on an activity.
+type Comment struct {
+	ID         int64     `json:"id"`
+	ActivityID int64     `json:"activity_id"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+	Athlete    Athlete   `json:"athlete"` // the commenter; only the names are set
+}
+
+// AuthorName returns the commenter's full name.
+func (c Comment) AuthorName() string {
+	return strings.TrimSpace(c.Athlete.Firstname + " " + c.Athlete.Lastname)
+}
+
+type Athlete struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+}
+
+// Club is a club the athlete is a member of.
+type Club struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	SportType   string `json:"sport_type"` // "cycling", "running", "triathlon" or "other"
+	MemberCount int    `json:"member_count"`
+	City        string `json:"city"`
+	Country     string `json:"country"`
+	Private     bool   `json:"private"`
+	URL         string `json:"url"` // vanity name in strava.com/clubs/<url>
+}
+
+// ActivityTotal is one of the rolled-up totals in AthleteStats.
+type ActivityTotal struct {
+	Count         int     `json:"count"`
+	Distance      float64 `json:"distance"`       // meters
+	MovingTime    int     `json:"moving_time"`    // seconds
+	ElapsedTime   int     `json:"elapsed_time"`   // seconds
+	ElevationGain float64 `json:"elevation_gain"` // meters
+}
+
+type AthleteStats struct {
+	RecentRideTotals ActivityTotal `json:"recent_ride_totals"` // last four weeks
+	RecentRunTotals  ActivityTotal `json:"recent_run_totals"`
+	RecentSwimTotals ActivityTotal `json:"recent_swim_totals"`
+	YTDRideTotals    ActivityTotal `json:"ytd_ride_totals"`
+	YTDRunTotals     ActivityTotal `json:"ytd_run_totals"`
+	YTDSwimTotals    ActivityTotal `json:"ytd_swim_totals"`
+	AllRideTotals    ActivityTotal `json:"all_ride_totals"`
+	AllRunTotals     ActivityTotal `json:"all_run_totals"`
+	AllSwimTotals    ActivityTotal `json:"all_swim_totals"`
 }
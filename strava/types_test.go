@@ -0,0 +1,163 @@
+package strava
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestActivityUpdateJSON(t *testing.T) {
+	empty, off, on := "", false, true
+	zero, five := 0, 5
+	start := time.Date(2024, 3, 9, 7, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		update ActivityUpdate
+		want   string
+	}{
+		{"empty", ActivityUpdate{}, `{}`},
+		{"name and sport type", ActivityUpdate{Name: "Morning Run", SportType: "Run", Type: "Run"},
+			`{"name":"Morning Run","sport_type":"Run","type":"Run"}`},
+		{"start time", ActivityUpdate{StartDateLocal: start}, `{"start_date_local":"2024-03-09T07:30:00Z"}`},
+		{"cleared note", ActivityUpdate{PrivateNote: &empty}, `{"private_note":""}`},
+		{"false flags", ActivityUpdate{HideFromHome: &off, Commute: &off, Trainer: &off},
+			`{"hide_from_home":false,"commute":false,"trainer":false}`},
+		{"true flags", ActivityUpdate{HideFromHome: &on, Commute: &on, Trainer: &on},
+			`{"hide_from_home":true,"commute":true,"trainer":true}`},
+		{"zero workout type", ActivityUpdate{WorkoutType: &zero}, `{"workout_type":0}`},
+		{"perceived exertion and visibility", ActivityUpdate{PerceivedExertion: &five, Visibility: "only_me"},
+			`{"visibility":"only_me","perceived_exertion":5}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildUpdateJSON(t *testing.T) {
+	start := time.Date(2024, 3, 9, 7, 30, 0, 0, time.UTC)
+	race := 1
+	current := Activity{Name: "Morning Run", SportType: "Run", StartDateLocal: start, PrivateNote: "note", Commute: true}
+
+	tests := []struct {
+		name    string
+		desired Activity
+		want    string
+	}{
+		{"unchanged", current, `{}`},
+		{"renamed", Activity{Name: "Parkrun", SportType: "Run", StartDateLocal: start, PrivateNote: "note", Commute: true},
+			`{"name":"Parkrun"}`},
+		{"flags and note cleared", Activity{Name: "Morning Run", SportType: "Run", StartDateLocal: start},
+			`{"private_note":"","commute":false}`},
+		{"moved and raced", Activity{Name: "Morning Run", SportType: "Run", StartDateLocal: start.Add(time.Hour), PrivateNote: "note", Commute: true, WorkoutType: &race},
+			`{"start_date_local":"2024-03-09T08:30:00Z","workout_type":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, changed := BuildUpdate(current, tt.desired)
+			data, err := json.Marshal(update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+			if changed != (tt.want != `{}`) {
+				t.Errorf("changed = %v for %s", changed, data)
+			}
+		})
+	}
+}
+
+func TestUpdateActivitySendsPayload(t *testing.T) {
+	var method, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(data)
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.BaseURL = server.URL
+	off := false
+	if err := client.UpdateActivity("access", 42, ActivityUpdate{Name: "Parkrun", Trainer: &off}); err != nil {
+		t.Fatal(err)
+	}
+	if method != "PUT" || contentType != "application/json" {
+		t.Errorf("got %s with Content-Type %q, want a JSON PUT", method, contentType)
+	}
+	if want := `{"name":"Parkrun","trainer":false}`; body != want {
+		t.Errorf("sent %s, want %s", body, want)
+	}
+}
+
+func TestIsNoop(t *testing.T) {
+	start := time.Date(2024, 3, 9, 7, 30, 0, 0, time.UTC)
+	race, long := 1, 2
+	exertion := 6.0
+	current := Activity{
+		Name: "Morning Run", SportType: "Run", Type: "Run", Description: "Easy",
+		StartDateLocal: start, PrivateNote: "note", HideFromHome: true,
+		Commute: false, Trainer: true, Visibility: "followers_only",
+		WorkoutType: &race, PerceivedExertion: &exertion,
+	}
+	str := func(s string) *string { return &s }
+	boolean := func(b bool) *bool { return &b }
+	integer := func(i int) *int { return &i }
+
+	tests := []struct {
+		field     string
+		unchanged ActivityUpdate
+		changed   ActivityUpdate
+	}{
+		{"name", ActivityUpdate{Name: "Morning Run"}, ActivityUpdate{Name: "Parkrun"}},
+		{"sport_type", ActivityUpdate{SportType: "Run"}, ActivityUpdate{SportType: "TrailRun"}},
+		{"type", ActivityUpdate{Type: "Run"}, ActivityUpdate{Type: "Ride"}},
+		{"description", ActivityUpdate{Description: "Easy"}, ActivityUpdate{Description: "Hard"}},
+		{"start_date_local", ActivityUpdate{StartDateLocal: start}, ActivityUpdate{StartDateLocal: start.Add(time.Minute)}},
+		{"private_note", ActivityUpdate{PrivateNote: str("note")}, ActivityUpdate{PrivateNote: str("")}},
+		{"hide_from_home", ActivityUpdate{HideFromHome: boolean(true)}, ActivityUpdate{HideFromHome: boolean(false)}},
+		{"commute", ActivityUpdate{Commute: boolean(false)}, ActivityUpdate{Commute: boolean(true)}},
+		{"trainer", ActivityUpdate{Trainer: boolean(true)}, ActivityUpdate{Trainer: boolean(false)}},
+		{"visibility", ActivityUpdate{Visibility: "followers_only"}, ActivityUpdate{Visibility: "only_me"}},
+		{"workout_type", ActivityUpdate{WorkoutType: &race}, ActivityUpdate{WorkoutType: &long}},
+		{"perceived_exertion", ActivityUpdate{PerceivedExertion: integer(6)}, ActivityUpdate{PerceivedExertion: integer(7)}},
+	}
+	if !(ActivityUpdate{}).IsNoop(current) {
+		t.Errorf("an empty update isn't a no-op")
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if !tt.unchanged.IsNoop(current) {
+				t.Errorf("%+v changes nothing but isn't a no-op", tt.unchanged)
+			}
+			if tt.changed.IsNoop(current) {
+				t.Errorf("%+v changes %s but is a no-op", tt.changed, tt.field)
+			}
+		})
+	}
+
+	// Setting a value the activity doesn't have yet is a change
+	bare := Activity{Name: "Morning Run"}
+	for _, update := range []ActivityUpdate{{WorkoutType: integer(0)}, {PerceivedExertion: integer(5)}, {Visibility: "only_me"}} {
+		if update.IsNoop(bare) {
+			t.Errorf("%+v on an activity without the field is a no-op", update)
+		}
+	}
+	// Older payloads without a visibility fall back to the private flag
+	if !(ActivityUpdate{Visibility: "everyone"}).IsNoop(bare) {
+		t.Errorf("everyone on a public activity without a visibility isn't a no-op")
+	}
+}
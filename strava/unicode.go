@@ -0,0 +1,112 @@
+package strava
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NameTransform is a named rewrite of an activity name, such as a Unicode
+// cleanup applied by the cleaner.
+type NameTransform struct {
+	Name  string
+	Apply func(name string) string
+}
+
+// UnicodeTransforms lists the Unicode cleanups for activity names, in the
+// order they should be applied.
+var UnicodeTransforms = []NameTransform{
+	{"mojibake", FixMojibake},
+	{"nfc", ComposeNFC},
+	{"nbsp", ReplaceNonBreakingSpaces},
+	{"quotes", StraightenQuotes},
+}
+
+// cp1252 maps the characters Windows-1252 puts in 0x80-0x9F to their
+// bytes. Mojibake is usually UTF-8 decoded as Windows-1252 rather than
+// strict Latin-1, e.g. "’" (E2 80 99) shows up as "â€™".
+var cp1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86,
+	'‡': 0x87, 'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c,
+	'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// FixMojibake repairs UTF-8 that was decoded as Latin-1 or Windows-1252,
+// such as "CafÃ©" for "Café", including text that was mangled more than
+// once. It is deliberately conservative: the name is only changed when
+// every character maps back to a single byte and those bytes are valid
+// UTF-8 with at least one multi-byte character. A correctly accented name
+// like "Café" never qualifies, since a lone "é" byte is not valid UTF-8.
+func FixMojibake(name string) string {
+	for range 3 {
+		repaired, ok := unmojibake(name)
+		if !ok {
+			break
+		}
+		name = repaired
+	}
+	return name
+}
+
+// unmojibake undoes one round of mis-decoding, reporting false when name
+// doesn't look like mojibake.
+func unmojibake(name string) (string, bool) {
+	raw := make([]byte, 0, len(name))
+	multibyte := false
+	for _, r := range name {
+		switch b, ok := cp1252[r]; {
+		case ok:
+			raw = append(raw, b)
+		case r <= 0xff:
+			raw = append(raw, byte(r))
+		default:
+			// Not something a single-byte decoding produces
+			return name, false
+		}
+		multibyte = multibyte || r >= 0x80
+	}
+	if !multibyte || !utf8.Valid(raw) {
+		return name, false
+	}
+
+	repaired := string(raw)
+	for _, r := range repaired {
+		if r >= 0x80 && r < 0xa0 {
+			// C1 control characters are not real text
+			return name, false
+		}
+	}
+	return repaired, true
+}
+
+// ComposeNFC normalizes name to Unicode NFC, so that a name typed with
+// decomposed accents, as iOS and macOS often send them (e.g. "e" + U+0301),
+// compares equal to the same name typed precomposed ("é").
+func ComposeNFC(name string) string {
+	return norm.NFC.String(name)
+}
+
+var nonBreakingSpaces = strings.NewReplacer(
+	"\u00a0", " ", // no-break space
+	"\u2007", " ", // figure space
+	"\u202f", " ", // narrow no-break space
+)
+
+// ReplaceNonBreakingSpaces turns non-breaking spaces into regular spaces.
+func ReplaceNonBreakingSpaces(name string) string {
+	return nonBreakingSpaces.Replace(name)
+}
+
+var curlyQuotes = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201b", "'",
+	"\u201c", `"`, "\u201d", `"`, "\u201e", `"`, "\u201f", `"`,
+)
+
+// StraightenQuotes replaces curly single and double quotes with straight
+// ones.
+func StraightenQuotes(name string) string {
+	return curlyQuotes.Replace(name)
+}
@@ -0,0 +1,49 @@
+package strava
+
+import "testing"
+
+func TestComposeNFC(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"single mark", "Cafe\u0301", "Caf\u00e9"},
+		{"already composed", "Caf\u00e9 \u1ec6", "Caf\u00e9 \u1ec6"},
+		{"stacked marks", "Vie\u0323\u0302t", "Vi\u1ec7t"},
+		{"reordered marks", "Vie\u0302\u0323t", "Vi\u1ec7t"},
+		{"reordered after a composed letter", "Vi\u00ea\u0323t", "Vi\u1ec7t"},
+		{"reordered horn", "u\u0301\u031b", "\u1ee9"},
+		{"greek reordered", "\u03b1\u0345\u0313\u0301", "\u1f84"},
+		{"no letter with both marks", "Vi\u00e9\u0323t", "Vi\u1eb9\u0301t"},
+		{"same class keeps its order", "a\u0308\u0301", "\u00e4\u0301"},
+		{"same class keeps its order reversed", "a\u0301\u0308", "\u00e1\u0308"},
+		{"same class blocks", "a\u0483\u0301", "a\u0483\u0301"},
+		{"hangul jamo", "\u1112\u1161\u11ab", "\ud55c"},
+		{"kana with dakuten", "\u304b\u3099", "\u304c"},
+		{"angstrom sign", "\u212b", "\u00c5"},
+		{"ohm sign", "\u2126", "\u03a9"},
+		{"devanagari nukta", "\u0915\u093c", "\u0915\u093c"},
+		{"hebrew points reordered", "\u05d1\u05bc\u05b8", "\u05d1\u05b8\u05bc"},
+		{"mark without base", "\u0301e", "\u0301e"},
+		{"ascii", "Morning Run", "Morning Run"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComposeNFC(tt.in); got != tt.want {
+				t.Errorf("ComposeNFC(%+q) = %+q, want %+q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposeNFCMatchesPrecomposedNames(t *testing.T) {
+	// The same names as typed on iOS (decomposed) and elsewhere
+	for _, pair := range [][2]string{
+		{"Cafe\u0301 Ride", "Caf\u00e9 Ride"},
+		{"\u1112\u1161\u11ab\u1100\u1161\u11bc \u1105\u1161\u1112\u1175\u11bc", "\ud55c\uac15 \ub77c\ud79d"},
+		{"\u304b\u3099\u3063\u3053\u3046", "\u304c\u3063\u3053\u3046"},
+	} {
+		if got := ComposeNFC(pair[0]); got != ComposeNFC(pair[1]) || got != pair[1] {
+			t.Errorf("ComposeNFC(%+q) = %+q, want %+q", pair[0], got, pair[1])
+		}
+	}
+}
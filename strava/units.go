@@ -0,0 +1,69 @@
+package strava
+
+import (
+	"fmt"
+	"math"
+)
+
+// Units selects how distances, speeds and paces are formatted.
+type Units string
+
+const (
+	Metric   Units = "metric"
+	Imperial Units = "imperial"
+)
+
+const (
+	metersPerMile = 1609.344
+	metersPerYard = 0.9144
+)
+
+// ParseUnits parses "metric" or "imperial".
+func ParseUnits(value string) (Units, error) {
+	switch units := Units(value); units {
+	case Metric, Imperial:
+		return units, nil
+	}
+	return "", fmt.Errorf("unknown units %q, expected %q or %q", value, Metric, Imperial)
+}
+
+// FormatDistance formats meters as kilometers or miles.
+func (u Units) FormatDistance(meters float64) string {
+	if u == Imperial {
+		return fmt.Sprintf("%.1f mi", meters/metersPerMile)
+	}
+	return fmt.Sprintf("%.1f km", meters/1000)
+}
+
+// FormatSpeed formats an average speed the way athletes of sportType talk
+// about it: pace per kilometer or mile on foot, pace per 100 meters or
+// yards in the water, and speed for everything else.
+func (u Units) FormatSpeed(sportType string, metersPerSecond float64) string {
+	if metersPerSecond <= 0 {
+		return "-"
+	}
+
+	switch sportType {
+	case "Run", "TrailRun", "VirtualRun", "Walk", "Hike", "Snowshoe":
+		if u == Imperial {
+			return formatPace(metersPerMile/metersPerSecond) + " /mi"
+		}
+		return formatPace(1000/metersPerSecond) + " /km"
+	case "Swim":
+		if u == Imperial {
+			return formatPace(100*metersPerYard/metersPerSecond) + " /100yd"
+		}
+		return formatPace(100/metersPerSecond) + " /100m"
+	}
+
+	if u == Imperial {
+		return fmt.Sprintf("%.1f mph", metersPerSecond*3600/metersPerMile)
+	}
+	return fmt.Sprintf("%.1f km/h", metersPerSecond*3.6)
+}
+
+// formatPace formats seconds as minutes and seconds, e.g. "5:07".
+func formatPace(seconds float64) string {
+	total := int(math.Round(seconds))
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
@@ -0,0 +1,49 @@
+package strava
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WebhookEvent is a push notification from a Strava webhook subscription.
+type WebhookEvent struct {
+	ObjectType     string            `json:"object_type"` // "activity" or "athlete"
+	ObjectID       int64             `json:"object_id"`
+	AspectType     string            `json:"aspect_type"` // "create", "update" or "delete"
+	Updates        map[string]string `json:"updates"`
+	OwnerID        int64             `json:"owner_id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	EventTime      int64             `json:"event_time"`
+}
+
+// NewWebhookHandler returns a handler for a webhook subscription's
+// callback URL. It answers Strava's subscription validation request when
+// the verify token matches, and passes every event to handle. Strava
+// expects events to be acknowledged within two seconds, so handle should
+// queue slow work rather than do it inline.
+func NewWebhookHandler(verifyToken string, handle func(WebhookEvent)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			query := r.URL.Query()
+			if query.Get("hub.mode") != "subscribe" || query.Get("hub.verify_token") != verifyToken {
+				http.Error(w, "invalid verification request", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"hub.challenge": query.Get("hub.challenge")})
+
+		case http.MethodPost:
+			var event WebhookEvent
+			if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+				http.Error(w, "invalid event", http.StatusBadRequest)
+				return
+			}
+			handle(event)
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
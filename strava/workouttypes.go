@@ -0,0 +1,76 @@
+package strava
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Workout types are sport specific: runs and rides use different numbers
+// for the same kind of workout, and other sports have none.
+var (
+	runWorkoutTypes = map[string]int{
+		"default":  0,
+		"race":     1,
+		"long-run": 2,
+		"workout":  3,
+	}
+	rideWorkoutTypes = map[string]int{
+		"default": 10,
+		"race":    11,
+		"workout": 12,
+	}
+)
+
+// workoutTypesBySport maps each sport type that supports workout_type to
+// the names of its allowed values.
+var workoutTypesBySport = map[string]map[string]int{
+	"Run":               runWorkoutTypes,
+	"TrailRun":          runWorkoutTypes,
+	"VirtualRun":        runWorkoutTypes,
+	"Ride":              rideWorkoutTypes,
+	"GravelRide":        rideWorkoutTypes,
+	"MountainBikeRide":  rideWorkoutTypes,
+	"EBikeRide":         rideWorkoutTypes,
+	"EMountainBikeRide": rideWorkoutTypes,
+	"VirtualRide":       rideWorkoutTypes,
+}
+
+// WorkoutType resolves value to the workout_type for sportType. value is
+// either a name ("default", "race", "long-run" or "workout") or the number
+// itself; either way it must be allowed for the sport.
+func WorkoutType(sportType, value string) (int, error) {
+	allowed, ok := workoutTypesBySport[sportType]
+	if !ok {
+		return 0, fmt.Errorf("%s activities have no workout type", sportType)
+	}
+
+	if workoutType, ok := allowed[value]; ok {
+		return workoutType, nil
+	}
+	if workoutType, err := strconv.Atoi(value); err == nil {
+		for _, known := range allowed {
+			if known == workoutType {
+				return workoutType, nil
+			}
+		}
+	}
+
+	names := make([]string, 0, len(allowed))
+	for name := range allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("invalid workout type %q for %s, expected one of %v", value, sportType, names)
+}
+
+// WorkoutTypeName returns the name of workoutType for sportType, or the
+// number itself if it has no name.
+func WorkoutTypeName(sportType string, workoutType int) string {
+	for name, known := range workoutTypesBySport[sportType] {
+		if known == workoutType {
+			return name
+		}
+	}
+	return strconv.Itoa(workoutType)
+}